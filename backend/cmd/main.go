@@ -2,28 +2,71 @@ package main
 
 import (
 	"log"
+	"log/slog"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/medibridge/config"
+	"github.com/medibridge/controllers"
+	"github.com/medibridge/middleware"
 	"github.com/medibridge/models"
 	"github.com/medibridge/routes"
+	"github.com/medibridge/utils"
 	"golang.org/x/crypto/bcrypt"
 )
 
 func main() {
+	// Route standard library and application logs through the redacting logger
+	slog.SetDefault(utils.NewLogger(os.Stdout))
+
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
 		log.Println("Warning: .env file not found")
 	}
 
-	// Set default JWT secret if not in environment
-	if os.Getenv("JWT_SECRET") == "" {
-		os.Setenv("JWT_SECRET", "medibridge-secret-key-2024")
-		log.Println("Using default JWT secret")
+	// Only fall back to the default JWT secret in dev mode
+	if os.Getenv("JWT_KEYRING_FILE") == "" {
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" || secret == utils.DefaultJWTSecret {
+			if !config.IsDevMode() {
+				log.Fatal("JWT_SECRET or JWT_KEYRING_FILE must be set to a non-default value outside dev mode (APP_ENV=development)")
+			}
+			os.Setenv("JWT_SECRET", utils.DefaultJWTSecret)
+			log.Println("Using default JWT secret (dev mode)")
+		}
+	}
+
+	// Load signing keys
+	keyRing, err := utils.LoadKeyRing()
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	utils.SetKeyRing(keyRing)
+	log.Printf("JWT signing key %q active", keyRing.ActiveKeyID())
+
+	// Load password policy
+	passwordPolicy, err := utils.LoadPasswordPolicy()
+	if err != nil {
+		log.Fatalf("Failed to load password policy: %v", err)
+	}
+	utils.SetPasswordPolicy(passwordPolicy)
+
+	// Load role permissions
+	if err := config.LoadPermissions(); err != nil {
+		log.Fatalf("Failed to load permissions: %v", err)
+	}
+
+	// Load the ICD-10 code catalogue
+	icd10, err := utils.LoadICD10Catalogue()
+	if err != nil {
+		log.Fatalf("Failed to load ICD-10 catalogue: %v", err)
 	}
+	utils.SetICD10Catalogue(icd10)
+	log.Printf("Loaded %d ICD-10 codes", icd10.Len())
 
 	// Initialize database
 	log.Println("Initializing database connection...")
@@ -32,18 +75,80 @@ func main() {
 
 	// Auto migrate the schema
 	log.Println("Running database migrations...")
-	config.DB.AutoMigrate(&models.User{}, &models.Patient{})
+	config.DB.AutoMigrate(&models.User{}, &models.Patient{}, &models.Session{}, &models.RefreshToken{}, &models.RecoveryCode{}, &models.LoginAttempt{}, &models.PasswordResetToken{}, &models.CareTeamMember{}, &models.EmergencyAccess{}, &models.EmergencyAccessAction{}, &models.AuditEvent{}, &models.PatientRevision{}, &models.PatientIdentifier{}, &models.MRNSequence{}, &models.PatientMerge{}, &models.Allergy{}, &models.Diagnosis{}, &models.Encounter{}, &models.ClinicalNote{}, &models.NoteAddendum{}, &models.VitalSign{})
+	if err := config.ProtectAuditLog(config.DB); err != nil {
+		log.Fatalf("Failed to protect audit log: %v", err)
+	}
+	if err := config.ProtectSignedNotes(config.DB); err != nil {
+		log.Fatalf("Failed to protect signed notes: %v", err)
+	}
+	// Patient email uniqueness now ignores soft-deleted rows; the partial
+	// index replaces the old table-wide constraint
+	if err := config.DB.Exec("ALTER TABLE patients DROP CONSTRAINT IF EXISTS patients_email_key").Error; err != nil {
+		log.Fatalf("Failed to drop old patient email constraint: %v", err)
+	}
+	// Email is optional; the unique index now also ignores empty emails
+	if err := config.DB.Exec("DROP INDEX IF EXISTS idx_patients_email_active").Error; err != nil {
+		log.Fatalf("Failed to drop old patient email index: %v", err)
+	}
+	if err := config.EnablePatientSearch(config.DB); err != nil {
+		log.Fatalf("Failed to set up patient search: %v", err)
+	}
+	if err := config.EnforceClinicalUniqueness(config.DB); err != nil {
+		log.Fatalf("Failed to create clinical record indexes: %v", err)
+	}
+	if count, err := controllers.AssignMissingMRNs(config.DB); err != nil {
+		log.Fatalf("Failed to assign MRNs: %v", err)
+	} else if count > 0 {
+		log.Printf("Assigned MRNs to %d existing patients", count)
+	}
+	if count, err := controllers.ConvertFreeTextAllergies(config.DB); err != nil {
+		log.Fatalf("Failed to convert free-text allergies: %v", err)
+	} else if count > 0 {
+		log.Printf("Converted free-text allergies of %d patients", count)
+	}
+	if count, err := controllers.ConvertFreeTextDiagnoses(config.DB); err != nil {
+		log.Fatalf("Failed to convert free-text diagnoses: %v", err)
+	} else if count > 0 {
+		log.Printf("Converted free-text diagnoses of %d patients", count)
+	}
+	if count, err := controllers.ConvertFreeTextNotes(config.DB); err != nil {
+		log.Fatalf("Failed to convert free-text notes: %v", err)
+	} else if count > 0 {
+		log.Printf("Converted free-text notes of %d patients", count)
+	}
+	if count, err := controllers.ConvertEncounterNotes(config.DB); err != nil {
+		log.Fatalf("Failed to convert encounter notes: %v", err)
+	} else if count > 0 {
+		log.Printf("Converted notes of %d encounters", count)
+	}
+	if count, err := controllers.ConvertEncounterVitals(config.DB); err != nil {
+		log.Fatalf("Failed to convert encounter vitals: %v", err)
+	} else if count > 0 {
+		log.Printf("Recorded the vitals of %d encounters as observations", count)
+	}
 	log.Println("Database migrations completed")
 
-	// Seed initial users if they don't exist
-	log.Println("Checking for default users...")
-	seedUsers()
+	// Share login lockout counters between instances when requested
+	if os.Getenv("LOCKOUT_STORE") == "database" {
+		controllers.SetLoginGuard(utils.NewLoginGuard(utils.NewDBAttemptStore(config.DB), utils.DefaultAccountPolicy, utils.DefaultIPPolicy))
+		log.Println("Using database store for login lockouts")
+	}
+
+	// Permanently remove patients whose trash retention has expired
+	go purgeDeletedPatients()
+
+	// Create the first admin account if none exists
+	log.Println("Checking for admin user...")
+	bootstrapAdmin()
 
-	// Initialize Gin router
-	r := gin.Default()
+	// Initialize Gin router. gin.Default is not used because its logger
+	// writes raw URLs, which can contain patient search terms.
+	r := gin.New()
 
 	// Add logging middleware
-	r.Use(gin.Logger())
+	r.Use(middleware.RequestID())
+	r.Use(middleware.RequestLogger())
 	r.Use(gin.Recovery())
 
 	// Configure CORS
@@ -51,8 +156,8 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173", "https://medi-bridge.netlify.app"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "If-Match", "X-Request-ID"},
+		ExposeHeaders:    []string{"Content-Length", "ETag", "X-Request-ID", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           12 * 60 * 60, // 12 hours
 	}))
@@ -73,66 +178,66 @@ func main() {
 	}
 }
 
-func seedUsers() {
-	// Create doctor
-	doctorPassword, _ := bcrypt.GenerateFromPassword([]byte("doctor@#123"), bcrypt.DefaultCost)
-	doctor := models.User{
-		Name:         "Dr. John Doe",
-		Email:        "doctor@medibridge.com",
-		PasswordHash: string(doctorPassword),
-		Role:         models.RoleDoctor,
-	}
-
-	// Create receptionist
-	receptionistPassword, _ := bcrypt.GenerateFromPassword([]byte("reception@#123"), bcrypt.DefaultCost)
-	receptionist := models.User{
-		Name:         "Jane Smith",
-		Email:        "receptionist@medibridge.com",
-		PasswordHash: string(receptionistPassword),
-		Role:         models.RoleReceptionist,
-	}
-
-	// Create Lokesh
-	lokeshPassword, _ := bcrypt.GenerateFromPassword([]byte("lokesh@#123"), bcrypt.DefaultCost)
-	lokesh := models.User{
-		Name:         "Lokesh Suwalka",
-		Email:        "lokesh@medibridge.com",
-		PasswordHash: string(lokeshPassword),
-		Role:         models.RoleDoctor,
-	}
-
-	// Check if users already exist
-	var existingDoctor models.User
-	if err := config.DB.Where("email = ?", doctor.Email).First(&existingDoctor).Error; err != nil {
-		if err := config.DB.Create(&doctor).Error; err != nil {
-			log.Printf("Error creating doctor: %v", err)
-		} else {
-			log.Println("Doctor user created successfully")
-		}
-	} else {
-		log.Println("Doctor user already exists")
+// bootstrapAdmin creates the first admin account from BOOTSTRAP_ADMIN_EMAIL
+// and BOOTSTRAP_ADMIN_PASSWORD. It does nothing once any admin exists, so the
+// variables can be removed after the first start.
+func bootstrapAdmin() {
+	var admins int64
+	if err := config.DB.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&admins).Error; err != nil {
+		log.Printf("Error checking for admin users: %v", err)
+		return
+	}
+	if admins > 0 {
+		log.Println("Admin user already exists")
+		return
 	}
 
-	var existingReceptionist models.User
-	if err := config.DB.Where("email = ?", receptionist.Email).First(&existingReceptionist).Error; err != nil {
-		if err := config.DB.Create(&receptionist).Error; err != nil {
-			log.Printf("Error creating receptionist: %v", err)
-		} else {
-			log.Println("Receptionist user created successfully")
-		}
-	} else {
-		log.Println("Receptionist user already exists")
+	email := strings.ToLower(strings.TrimSpace(os.Getenv("BOOTSTRAP_ADMIN_EMAIL")))
+	password := os.Getenv("BOOTSTRAP_ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		log.Println("Warning: no admin user exists. Set BOOTSTRAP_ADMIN_EMAIL and BOOTSTRAP_ADMIN_PASSWORD to create one")
+		return
+	}
+
+	name := os.Getenv("BOOTSTRAP_ADMIN_NAME")
+	if name == "" {
+		name = "Administrator"
 	}
 
-	var existingLokesh models.User
-	if err := config.DB.Where("email = ?", lokesh.Email).First(&existingLokesh).Error; err != nil {
-		if err := config.DB.Create(&lokesh).Error; err != nil {
-			log.Printf("Error creating Lokesh: %v", err)
-		} else {
-			log.Println("Lokesh user created successfully")
+	if err := utils.CurrentPasswordPolicy().Validate(password, name, email); err != nil {
+		log.Fatalf("BOOTSTRAP_ADMIN_PASSWORD rejected: %v", err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Fatalf("Failed to hash bootstrap admin password: %v", err)
+	}
+
+	admin := models.User{
+		Name:         name,
+		Email:        email,
+		PasswordHash: string(hash),
+		Role:         models.RoleAdmin,
+	}
+	if err := config.DB.Create(&admin).Error; err != nil {
+		log.Fatalf("Failed to create bootstrap admin: %v", err)
+	}
+	log.Println("Bootstrap admin user created successfully")
+}
+
+// purgeDeletedPatients hard-deletes patients that have been in the trash
+// longer than PATIENT_TRASH_RETENTION, at start-up and then every
+// PATIENT_PURGE_INTERVAL (default 24h)
+func purgeDeletedPatients() {
+	interval := utils.DurationFromEnv("PATIENT_PURGE_INTERVAL", 24*time.Hour)
+
+	for {
+		purged, err := controllers.PurgeDeletedPatients(config.DB, controllers.TrashRetention())
+		if err != nil {
+			slog.Error("failed to purge deleted patients", "error", err)
+		} else if purged > 0 {
+			slog.Info("purged deleted patients", "count", purged)
 		}
-	} else {
-		log.Println("Lokesh user already exists")
+		time.Sleep(interval)
 	}
-} 
- 
\ No newline at end of file
+}
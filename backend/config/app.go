@@ -0,0 +1,27 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// IsDevMode reports whether the server runs in local development mode
+// (APP_ENV=development). Insecure defaults are only allowed in dev mode.
+func IsDevMode() bool {
+	switch strings.ToLower(os.Getenv("APP_ENV")) {
+	case "dev", "development", "local":
+		return true
+	}
+	return false
+}
+
+// MFARequired reports whether users with the given role must use two-factor
+// authentication. Roles are listed in MFA_REQUIRED_ROLES, e.g. "doctor".
+func MFARequired(role string) bool {
+	for _, r := range strings.Split(os.Getenv("MFA_REQUIRED_ROLES"), ",") {
+		if strings.TrimSpace(r) == role && role != "" {
+			return true
+		}
+	}
+	return false
+}
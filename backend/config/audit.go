@@ -0,0 +1,26 @@
+package config
+
+import (
+	"gorm.io/gorm"
+)
+
+// ProtectAuditLog installs a trigger that rejects UPDATE and DELETE on the
+// audit_events table so the log stays append-only even for direct SQL
+func ProtectAuditLog(db *gorm.DB) error {
+	if err := db.Exec(`
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql`).Error; err != nil {
+		return err
+	}
+
+	if err := db.Exec(`DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events`).Error; err != nil {
+		return err
+	}
+	return db.Exec(`
+CREATE TRIGGER audit_events_append_only
+	BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_events
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only()`).Error
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	// AllergyActiveIndex is the unique index that allows one active allergy
+	// per substance and patient
+	AllergyActiveIndex = "idx_allergies_active_substance"
+	// EncounterOpenIndex is the unique index that allows a doctor one open
+	// encounter per patient
+	EncounterOpenIndex = "idx_encounters_open_doctor"
+)
+
+// EnforceClinicalUniqueness installs the partial unique indexes of the
+// clinical records, so that concurrent requests cannot record an active
+// allergy to the same substance twice or open two encounters
+func EnforceClinicalUniqueness(db *gorm.DB) error {
+	if err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS ` + AllergyActiveIndex + `
+	ON allergies (patient_id, lower(substance)) WHERE status = 'active'`).Error; err != nil {
+		return err
+	}
+	return db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS ` + EncounterOpenIndex + `
+	ON encounters (patient_id, doctor_id) WHERE status = 'open'`).Error
+}
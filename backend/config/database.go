@@ -3,10 +3,13 @@ package config
 import (
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
@@ -20,7 +23,15 @@ func InitDB() {
 		os.Getenv("DB_PORT"),
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// Log SQL with placeholders only so patient data never reaches the logs
+	dbLogger := logger.New(slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn), logger.Config{
+		SlowThreshold:             200 * time.Millisecond,
+		LogLevel:                  logger.Warn,
+		IgnoreRecordNotFoundError: true,
+		ParameterizedQueries:      true,
+	})
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: dbLogger})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
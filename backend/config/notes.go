@@ -0,0 +1,97 @@
+package config
+
+import (
+	"gorm.io/gorm"
+)
+
+// ProtectSignedNotes installs triggers that keep the content of signed and
+// legacy clinical notes and their addenda unchanged even for direct SQL.
+// Signed notes may still move between patients when duplicates are merged,
+// and a note awaiting a co-signature may only become signed. Only drafts can
+// be deleted, except while patients are purged (see AllowNotePurge).
+func ProtectSignedNotes(db *gorm.DB) error {
+	if err := db.Exec(`
+CREATE OR REPLACE FUNCTION clinical_notes_signed_immutable() RETURNS trigger AS $$
+BEGIN
+	IF OLD.status <> 'draft' AND (
+		NEW.title IS DISTINCT FROM OLD.title OR
+		NEW.subjective IS DISTINCT FROM OLD.subjective OR
+		NEW.objective IS DISTINCT FROM OLD.objective OR
+		NEW.assessment IS DISTINCT FROM OLD.assessment OR
+		NEW.plan IS DISTINCT FROM OLD.plan OR
+		NEW.author_id IS DISTINCT FROM OLD.author_id OR
+		NEW.encounter_id IS DISTINCT FROM OLD.encounter_id OR
+		NEW.signed_at IS DISTINCT FROM OLD.signed_at OR
+		(NEW.status IS DISTINCT FROM OLD.status AND NOT (OLD.status = 'awaiting_cosign' AND NEW.status = 'signed')) OR
+		((NEW.cosigner_id IS DISTINCT FROM OLD.cosigner_id OR NEW.cosigned_at IS DISTINCT FROM OLD.cosigned_at) AND
+			NOT (OLD.status = 'awaiting_cosign' AND NEW.status = 'signed'))
+	) THEN
+		RAISE EXCEPTION 'signed clinical notes cannot be changed';
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql`).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(`DROP TRIGGER IF EXISTS clinical_notes_signed_immutable ON clinical_notes`).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(`
+CREATE TRIGGER clinical_notes_signed_immutable
+	BEFORE UPDATE ON clinical_notes
+	FOR EACH ROW EXECUTE FUNCTION clinical_notes_signed_immutable()`).Error; err != nil {
+		return err
+	}
+
+	if err := db.Exec(`
+CREATE OR REPLACE FUNCTION clinical_notes_signed_undeletable() RETURNS trigger AS $$
+BEGIN
+	IF OLD.status <> 'draft' AND current_setting('medibridge.purge', true) IS DISTINCT FROM 'on' THEN
+		RAISE EXCEPTION 'signed clinical notes cannot be deleted';
+	END IF;
+	RETURN OLD;
+END;
+$$ LANGUAGE plpgsql`).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(`DROP TRIGGER IF EXISTS clinical_notes_signed_undeletable ON clinical_notes`).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(`
+CREATE TRIGGER clinical_notes_signed_undeletable
+	BEFORE DELETE ON clinical_notes
+	FOR EACH ROW EXECUTE FUNCTION clinical_notes_signed_undeletable()`).Error; err != nil {
+		return err
+	}
+
+	if err := db.Exec(`
+CREATE OR REPLACE FUNCTION note_addenda_immutable() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		IF current_setting('medibridge.purge', true) IS DISTINCT FROM 'on' THEN
+			RAISE EXCEPTION 'note addenda cannot be deleted';
+		END IF;
+		RETURN OLD;
+	END IF;
+	IF NEW.text IS DISTINCT FROM OLD.text OR NEW.note_id IS DISTINCT FROM OLD.note_id OR NEW.author_id IS DISTINCT FROM OLD.author_id THEN
+		RAISE EXCEPTION 'note addenda cannot be changed';
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql`).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(`DROP TRIGGER IF EXISTS note_addenda_immutable ON note_addenda`).Error; err != nil {
+		return err
+	}
+	return db.Exec(`
+CREATE TRIGGER note_addenda_immutable
+	BEFORE UPDATE OR DELETE ON note_addenda
+	FOR EACH ROW EXECUTE FUNCTION note_addenda_immutable()`).Error
+}
+
+// AllowNotePurge lets the rest of transaction tx delete signed notes and
+// addenda. Only the purge of patients past their trash retention uses it.
+func AllowNotePurge(tx *gorm.DB) error {
+	return tx.Exec("SELECT set_config('medibridge.purge', 'on', true)").Error
+}
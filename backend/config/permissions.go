@@ -0,0 +1,122 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/medibridge/models"
+)
+
+// defaultRolePermissions is used unless PERMISSIONS_FILE overrides it.
+// Clinical records (diagnoses, encounters, notes and vital signs) are
+// written with patient:write_clinical, so only doctors write them unless
+// the file grants it to another role.
+var defaultRolePermissions = map[models.UserRole][]models.Permission{
+	models.RoleReceptionist: {
+		models.PermPatientRead,
+		models.PermPatientCreate,
+		models.PermPatientWriteDemographics,
+		models.PermPatientDelete,
+		models.PermPatientAccessAll,
+		models.PermPatientMerge,
+		models.PermCareTeamManage,
+	},
+	models.RoleDoctor: {
+		models.PermPatientRead,
+		models.PermPatientReadClinical,
+		models.PermPatientWriteClinical,
+		models.PermPatientBreakGlass,
+	},
+	models.RoleAdmin: {
+		models.PermUserManage,
+		models.PermCareTeamManage,
+		models.PermEmergencyReview,
+		models.PermAuditRead,
+		models.PermPatientTrash,
+	},
+}
+
+var (
+	permissionsMu   sync.RWMutex
+	rolePermissions map[models.UserRole]map[models.Permission]bool
+)
+
+func buildPermissions(mapping map[models.UserRole][]models.Permission) (map[models.UserRole]map[models.Permission]bool, error) {
+	built := make(map[models.UserRole]map[models.Permission]bool, len(mapping))
+	for role, perms := range mapping {
+		if !role.Valid() {
+			return nil, fmt.Errorf("unknown role %q", role)
+		}
+		built[role] = make(map[models.Permission]bool, len(perms))
+		for _, perm := range perms {
+			if !perm.Valid() {
+				return nil, fmt.Errorf("role %q: unknown permission %q", role, perm)
+			}
+			built[role][perm] = true
+		}
+	}
+	return built, nil
+}
+
+// LoadPermissions reads the role to permission mapping from PERMISSIONS_FILE,
+// a JSON object such as {"doctor": ["patient:read"]}. Without the variable
+// the built-in defaults are used.
+func LoadPermissions() error {
+	mapping := defaultRolePermissions
+	if path := os.Getenv("PERMISSIONS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading permissions: %w", err)
+		}
+		mapping = map[models.UserRole][]models.Permission{}
+		if err := json.Unmarshal(data, &mapping); err != nil {
+			return fmt.Errorf("parsing permissions: %w", err)
+		}
+	}
+
+	built, err := buildPermissions(mapping)
+	if err != nil {
+		return err
+	}
+
+	permissionsMu.Lock()
+	rolePermissions = built
+	permissionsMu.Unlock()
+	return nil
+}
+
+func currentPermissions() map[models.UserRole]map[models.Permission]bool {
+	permissionsMu.RLock()
+	perms := rolePermissions
+	permissionsMu.RUnlock()
+	if perms != nil {
+		return perms
+	}
+
+	built, _ := buildPermissions(defaultRolePermissions)
+	permissionsMu.Lock()
+	if rolePermissions == nil {
+		rolePermissions = built
+	}
+	perms = rolePermissions
+	permissionsMu.Unlock()
+	return perms
+}
+
+// HasPermission reports whether the role grants the permission
+func HasPermission(role models.UserRole, perm models.Permission) bool {
+	return currentPermissions()[role][perm]
+}
+
+// PermissionsFor returns the permissions granted to a role in a stable order
+func PermissionsFor(role models.UserRole) []models.Permission {
+	perms := []models.Permission{}
+	for perm := range currentPermissions()[role] {
+		perms = append(perms, perm)
+	}
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms
+}
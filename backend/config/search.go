@@ -0,0 +1,19 @@
+package config
+
+import (
+	"gorm.io/gorm"
+)
+
+// PatientNameExpr is the indexed expression patient name searches match
+// against
+const PatientNameExpr = "lower(patients.first_name || ' ' || patients.last_name)"
+
+// EnablePatientSearch installs the pg_trgm extension and the trigram index
+// used for typo-tolerant patient name search
+func EnablePatientSearch(db *gorm.DB) error {
+	if err := db.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`).Error; err != nil {
+		return err
+	}
+	return db.Exec(`CREATE INDEX IF NOT EXISTS idx_patients_name_trgm
+	ON patients USING gin ((lower(first_name || ' ' || last_name)) gin_trgm_ops)`).Error
+}
@@ -0,0 +1,383 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"gorm.io/gorm"
+)
+
+type AllergyRequest struct {
+	Substance string                 `json:"substance" binding:"required,max=200" redact:"phi"`
+	Category  models.AllergyCategory `json:"category" binding:"required"`
+	Reaction  string                 `json:"reaction" binding:"max=500" redact:"phi"`
+	Severity  models.AllergySeverity `json:"severity"`
+	Status    models.AllergyStatus   `json:"status"`
+	Onset     string                 `json:"onset"`
+}
+
+// AllergyUpdateRequest changes the fields that are set and leaves the rest
+type AllergyUpdateRequest struct {
+	Substance string                 `json:"substance" binding:"max=200" redact:"phi"`
+	Category  models.AllergyCategory `json:"category"`
+	Reaction  string                 `json:"reaction" binding:"max=500" redact:"phi"`
+	Severity  models.AllergySeverity `json:"severity"`
+	Status    models.AllergyStatus   `json:"status"`
+	Onset     string                 `json:"onset"`
+}
+
+var errAllergyExists = errors.New("allergy already recorded")
+
+// canWriteAllergies reports whether the user may record allergies. Both the
+// front desk (at intake) and clinicians record them.
+func canWriteAllergies(c *gin.Context) bool {
+	return hasPermission(c, models.PermPatientWriteDemographics) || hasPermission(c, models.PermPatientWriteClinical)
+}
+
+// validateAllergy checks the coded fields of an allergy and parses its
+// onset date. Unstructured entries converted from free text may keep their
+// category until a clinician codes them. It reports whether it responded.
+func validateAllergy(c *gin.Context, allergy *models.Allergy, onset string) bool {
+	unstructured := allergy.ID != 0 && allergy.Category == models.AllergyUnstructured
+	if !allergy.Category.Valid() && !unstructured {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category. Use drug, food or environment"})
+		return true
+	}
+	if !allergy.Severity.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid severity. Use mild, moderate or severe"})
+		return true
+	}
+	if !allergy.Status.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Use active, inactive, resolved or entered_in_error"})
+		return true
+	}
+	if onset != "" {
+		date, err := time.Parse("2006-01-02", onset)
+		if err != nil || date.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid onset. Use a past date in YYYY-MM-DD format"})
+			return true
+		}
+		allergy.Onset = &date
+	}
+	return false
+}
+
+// saveAllergy creates or updates an allergy and records the audit entry. A
+// second active entry for the same substance is refused by the unique
+// index.
+func saveAllergy(c *gin.Context, allergy *models.Allergy) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(allergy).Error; err != nil {
+			if isUniqueViolation(err, config.AllergyActiveIndex) {
+				return errAllergyExists
+			}
+			return err
+		}
+		return recordAudit(c, tx, models.AuditUpdate, []string{"allergies"}, allergy.PatientID)
+	})
+}
+
+// noKnownAllergies are the free-text entries that state the patient has no
+// known allergies, compared in lower case without trailing punctuation
+var noKnownAllergies = map[string]bool{
+	"none": true, "nil": true, "no": true, "n/a": true, "na": true, "-": true,
+	"nka": true, "nkda": true, "no known allergies": true, "no known drug allergies": true,
+}
+
+// freeTextAllergy turns text written to the legacy allergies field into an
+// unstructured allergy entry and clears the field. It returns nil when the
+// field is empty or says there are no known allergies, which is not an
+// allergy to record.
+func freeTextAllergy(patient *models.Patient, userID uint) *models.Allergy {
+	text := strings.TrimSpace(patient.Allergies)
+	patient.Allergies = ""
+	if text == "" || noKnownAllergies[strings.TrimRight(strings.ToLower(text), ".!")] {
+		return nil
+	}
+	return &models.Allergy{
+		PatientID:  patient.ID,
+		Substance:  text,
+		Category:   models.AllergyUnstructured,
+		Status:     models.AllergyActive,
+		RecordedBy: userID,
+		UpdatedBy:  userID,
+	}
+}
+
+// ConvertFreeTextAllergies moves the free-text allergies of every patient,
+// including those in the trash, into unstructured allergy entries
+func ConvertFreeTextAllergies(db *gorm.DB) (int, error) {
+	var patients []models.Patient
+	if err := db.Unscoped().Where("allergies <> ''").Find(&patients).Error; err != nil {
+		return 0, err
+	}
+
+	converted := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := range patients {
+			if allergy := freeTextAllergy(&patients[i], patients[i].UpdatedBy); allergy != nil {
+				if err := tx.Create(allergy).Error; err != nil {
+					return err
+				}
+				converted++
+			}
+			if err := tx.Unscoped().Model(&models.Patient{}).Where("id = ?", patients[i].ID).UpdateColumn("allergies", "").Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return converted, nil
+}
+
+// loadAllergyBanner summarises a patient's active allergies
+func loadAllergyBanner(patient *models.Patient) (models.AllergyBanner, error) {
+	var allergies []models.Allergy
+	if err := config.DB.Where("patient_id = ? AND status = ?", patient.ID, models.AllergyActive).Order("id").Find(&allergies).Error; err != nil {
+		return models.AllergyBanner{}, err
+	}
+	return models.NewAllergyBanner(allergies), nil
+}
+
+// loadAllergyBanners summarises the active allergies of several patients
+func loadAllergyBanners(patientIDs []uint) (map[uint]models.AllergyBanner, error) {
+	var allergies []models.Allergy
+	if err := config.DB.Where("patient_id IN ? AND status = ?", patientIDs, models.AllergyActive).Order("id").Find(&allergies).Error; err != nil {
+		return nil, err
+	}
+	byPatient := make(map[uint][]models.Allergy)
+	for _, allergy := range allergies {
+		byPatient[allergy.PatientID] = append(byPatient[allergy.PatientID], allergy)
+	}
+
+	banners := make(map[uint]models.AllergyBanner, len(patientIDs))
+	for _, id := range patientIDs {
+		banners[id] = models.NewAllergyBanner(byPatient[id])
+	}
+	return banners, nil
+}
+
+// findPatientAllergy loads one allergy of a patient the user may access. It
+// reports whether it found it, and responds otherwise.
+func findPatientAllergy(c *gin.Context, patient *models.Patient, allergy *models.Allergy) bool {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return false
+	}
+	allergyID, err := strconv.ParseUint(c.Param("allergyId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid allergy ID"})
+		return false
+	}
+	if !findScopedPatient(c, patientID, patient) {
+		return false
+	}
+	if err := config.DB.Where("patient_id = ?", patient.ID).First(allergy, allergyID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Allergy not found"})
+		return false
+	}
+	return true
+}
+
+// GetAllergies lists a patient's allergies. Supports status to filter, e.g.
+// status=active.
+func GetAllergies(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Model(&models.Allergy{})
+	if value := c.Query("status"); value != "" {
+		status := models.AllergyStatus(value)
+		if !status.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Use active, inactive, resolved or entered_in_error"})
+			return
+		}
+		query = query.Where("status = ?", status)
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+
+	var allergies []models.Allergy
+	if err := query.Where("patient_id = ?", patient.ID).Order("id").Find(&allergies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch allergies"})
+		return
+	}
+	if err := recordAudit(c, config.DB, models.AuditRead, []string{"allergies"}, patient.ID); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch allergies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": allergies})
+}
+
+// CreateAllergy records an allergy. A substance can only have one active
+// entry per patient.
+func CreateAllergy(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+	if !canWriteAllergies(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	var req AllergyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	allergy := models.Allergy{
+		Substance:  strings.TrimSpace(req.Substance),
+		Category:   req.Category,
+		Reaction:   strings.TrimSpace(req.Reaction),
+		Severity:   req.Severity,
+		Status:     req.Status,
+		RecordedBy: userID,
+		UpdatedBy:  userID,
+	}
+	if allergy.Status == "" {
+		allergy.Status = models.AllergyActive
+	}
+	if validateAllergy(c, &allergy, req.Onset) {
+		return
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+	allergy.PatientID = patient.ID
+
+	err := saveAllergy(c, &allergy)
+	if errors.Is(err, errAllergyExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An active allergy to this substance is already recorded"})
+		return
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to record allergy", "patientId", patient.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record allergy"})
+		return
+	}
+
+	utils.Logger(c).Info("allergy recorded", "patientId", patient.ID, "allergyId", allergy.ID)
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    allergy,
+		"message": "Allergy recorded successfully",
+	})
+}
+
+// UpdateAllergy changes the fields of an allergy that are set in the
+// request. Unstructured entries stay unstructured until a category is set,
+// so that their status can be changed on its own.
+func UpdateAllergy(c *gin.Context) {
+	if !canWriteAllergies(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	var req AllergyUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var patient models.Patient
+	var allergy models.Allergy
+	if !findPatientAllergy(c, &patient, &allergy) {
+		return
+	}
+
+	if req.Substance != "" {
+		allergy.Substance = strings.TrimSpace(req.Substance)
+	}
+	if req.Category != "" {
+		if !req.Category.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category. Use drug, food or environment"})
+			return
+		}
+		allergy.Category = req.Category
+	}
+	if req.Reaction != "" {
+		allergy.Reaction = strings.TrimSpace(req.Reaction)
+	}
+	if req.Severity != "" {
+		allergy.Severity = req.Severity
+	}
+	if req.Status != "" {
+		allergy.Status = req.Status
+	}
+	allergy.UpdatedBy = c.GetUint("userID")
+	if validateAllergy(c, &allergy, req.Onset) {
+		return
+	}
+
+	err := saveAllergy(c, &allergy)
+	if errors.Is(err, errAllergyExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An active allergy to this substance is already recorded"})
+		return
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to update allergy", "patientId", patient.ID, "allergyId", allergy.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update allergy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    allergy,
+		"message": "Allergy updated successfully",
+	})
+}
+
+// DeleteAllergy removes an allergy recorded by mistake. Allergies that no
+// longer apply should be marked inactive or resolved instead.
+func DeleteAllergy(c *gin.Context) {
+	if !canWriteAllergies(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	var patient models.Patient
+	var allergy models.Allergy
+	if !findPatientAllergy(c, &patient, &allergy) {
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&allergy).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditUpdate, []string{"allergies"}, patient.ID)
+	})
+	if err != nil {
+		utils.Logger(c).Error("failed to delete allergy", "patientId", patient.ID, "allergyId", allergy.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete allergy"})
+		return
+	}
+
+	utils.Logger(c).Info("allergy deleted", "patientId", patient.ID, "allergyId", allergy.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Allergy deleted successfully",
+	})
+}
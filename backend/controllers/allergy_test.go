@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreeTextAllergy(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "Penicillin, peanuts", want: "Penicillin, peanuts"},
+		{text: "  ", want: ""},
+		{text: "None", want: ""},
+		{text: "NKDA", want: ""},
+		{text: "No known allergies.", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			patient := models.Patient{ID: 1, Allergies: tt.text}
+			allergy := freeTextAllergy(&patient, 2)
+			assert.Empty(t, patient.Allergies)
+			if tt.want == "" {
+				assert.Nil(t, allergy)
+				return
+			}
+			require.NotNil(t, allergy)
+			assert.Equal(t, tt.want, allergy.Substance)
+			assert.Equal(t, models.AllergyUnstructured, allergy.Category)
+		})
+	}
+}
+
+// allergyParams are the route parameters of an allergy
+func allergyParams(patientID, allergyID uint) gin.Params {
+	return patientParams(patientID, gin.Param{Key: "allergyId", Value: fmt.Sprint(allergyID)})
+}
+
+func TestUpdateUnstructuredAllergy(t *testing.T) {
+	setupTestDB(t)
+
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, doctor.ID, time.Date(1950, 6, 20, 0, 0, 0, 0, time.UTC))
+	assignTestCareTeam(t, &patient, &doctor)
+	allergy := models.Allergy{PatientID: patient.ID, Substance: "penicillin rash", Category: models.AllergyUnstructured, Status: models.AllergyActive, RecordedBy: doctor.ID, UpdatedBy: doctor.ID}
+	require.NoError(t, config.DB.Create(&allergy).Error)
+
+	tests := []struct {
+		name       string
+		req        AllergyUpdateRequest
+		wantStatus int
+	}{
+		{name: "status only", req: AllergyUpdateRequest{Status: models.AllergyInactive}, wantStatus: http.StatusOK},
+		{name: "cannot be made unstructured", req: AllergyUpdateRequest{Category: models.AllergyUnstructured}, wantStatus: http.StatusBadRequest},
+		{name: "coded", req: AllergyUpdateRequest{Substance: "Penicillin", Category: models.AllergyDrug}, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newTestContext(http.MethodPut, "/patients/1/allergies/1", tt.req, &doctor, allergyParams(patient.ID, allergy.ID))
+			UpdateAllergy(c)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	require.NoError(t, config.DB.First(&allergy, allergy.ID).Error)
+	assert.Equal(t, models.AllergyDrug, allergy.Category)
+	assert.Equal(t, models.AllergyInactive, allergy.Status)
+}
+
+func TestCreateAllergyDuplicate(t *testing.T) {
+	setupTestDB(t)
+
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, doctor.ID, time.Date(1988, 9, 9, 0, 0, 0, 0, time.UTC))
+	assignTestCareTeam(t, &patient, &doctor)
+
+	tests := []struct {
+		name       string
+		req        AllergyRequest
+		wantStatus int
+	}{
+		{name: "first entry", req: AllergyRequest{Substance: "Latex", Category: models.AllergyEnvironment}, wantStatus: http.StatusCreated},
+		{name: "same substance, other case", req: AllergyRequest{Substance: "latex", Category: models.AllergyEnvironment}, wantStatus: http.StatusConflict},
+		{name: "inactive entry", req: AllergyRequest{Substance: "latex", Category: models.AllergyEnvironment, Status: models.AllergyResolved}, wantStatus: http.StatusCreated},
+		{name: "unstructured", req: AllergyRequest{Substance: "dust", Category: models.AllergyUnstructured}, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newTestContext(http.MethodPost, "/patients/1/allergies", tt.req, &doctor, patientParams(patient.ID))
+			CreateAllergy(c)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"gorm.io/gorm"
+)
+
+// auditLockKey is the Postgres advisory lock that serialises writers to the
+// audit chain
+const auditLockKey int64 = 0x4d42415544495400
+
+// auditVerifyBatch is how many events VerifyAuditLog loads at a time
+const auditVerifyBatch = 1000
+
+// recordAudit appends one event per patient to the audit log. Pass the
+// transaction that performs the change so that the change and its audit
+// entry are committed together.
+func recordAudit(c *gin.Context, db *gorm.DB, action models.AuditAction, fields []string, patientIDs ...uint) error {
+	role, _ := c.Get("userRole")
+	actorRole, _ := role.(models.UserRole)
+
+	var emergencyAccessID *uint
+	if id, ok := c.Get("emergencyAccessID"); ok {
+		if grantID, ok := id.(uint); ok {
+			emergencyAccessID = &grantID
+		}
+	}
+
+	events := make([]models.AuditEvent, len(patientIDs))
+	for i := range patientIDs {
+		events[i] = models.AuditEvent{
+			ActorID:           c.GetUint("userID"),
+			ActorRole:         actorRole,
+			Action:            action,
+			PatientID:         &patientIDs[i],
+			Fields:            fields,
+			IP:                c.ClientIP(),
+			RequestID:         c.GetString("requestID"),
+			Route:             c.Request.Method + " " + c.FullPath(),
+			EmergencyAccessID: emergencyAccessID,
+		}
+	}
+	return appendAuditEvents(db, events)
+}
+
+// appendAuditEvents timestamps the events, links them to the end of the
+// audit chain and stores them
+func appendAuditEvents(db *gorm.DB, events []models.AuditEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Held until the surrounding transaction ends so events link in order
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditLockKey).Error; err != nil {
+			return err
+		}
+
+		var last models.AuditEvent
+		if err := tx.Select("hash").Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+
+		prevHash := last.Hash
+		// Postgres keeps microseconds; hash what will be read back
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		for i := range events {
+			events[i].OccurredAt = now
+			events[i].PrevHash = prevHash
+			events[i].Hash = events[i].ComputeHash()
+			if err := tx.Create(&events[i]).Error; err != nil {
+				return err
+			}
+			prevHash = events[i].Hash
+		}
+		return nil
+	})
+}
+
+// parseTimeFilter accepts RFC 3339 timestamps or YYYY-MM-DD dates. With
+// endOfDay a plain date covers the whole day.
+func parseTimeFilter(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
+
+// GetAuditEvents queries the PHI access log. Supports patientId, userId,
+// action, from, to, emergency, page and limit query parameters.
+func GetAuditEvents(c *gin.Context) {
+	page, limit, responded := parsePage(c, 50, 500)
+	if responded {
+		return
+	}
+
+	query := config.DB.Model(&models.AuditEvent{})
+	if value := c.Query("patientId"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patientId"})
+			return
+		}
+		query = query.Where("patient_id = ?", id)
+	}
+	if value := c.Query("userId"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid userId"})
+			return
+		}
+		query = query.Where("actor_id = ?", id)
+	}
+	if value := c.Query("action"); value != "" {
+		query = query.Where("action = ?", value)
+	}
+	if value := c.Query("from"); value != "" {
+		from, err := parseTimeFilter(value, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from. Use YYYY-MM-DD or RFC 3339"})
+			return
+		}
+		query = query.Where("occurred_at >= ?", from)
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := parseTimeFilter(value, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to. Use YYYY-MM-DD or RFC 3339"})
+			return
+		}
+		query = query.Where("occurred_at <= ?", to)
+	}
+	if c.Query("emergency") == "true" {
+		query = query.Where("emergency_access_id IS NOT NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count audit events"})
+		return
+	}
+
+	var events []models.AuditEvent
+	if err := query.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": events,
+		"pagination": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+var errAuditChainBroken = errors.New("audit chain broken")
+
+// VerifyAuditLog walks the whole audit chain and reports the first event
+// whose hash or link does not match
+func VerifyAuditLog(c *gin.Context) {
+	var (
+		prevHash string
+		lastID   uint
+		checked  int
+		brokenID uint
+	)
+
+	var events []models.AuditEvent
+	err := config.DB.Model(&models.AuditEvent{}).
+		FindInBatches(&events, auditVerifyBatch, func(tx *gorm.DB, batch int) error {
+			if id, ok := models.VerifyAuditChain(prevHash, events); !ok {
+				brokenID = id
+				return errAuditChainBroken
+			}
+			checked += len(events)
+			if len(events) > 0 {
+				prevHash = events[len(events)-1].Hash
+				lastID = events[len(events)-1].ID
+			}
+			return nil
+		}).Error
+	if errors.Is(err, errAuditChainBroken) {
+		utils.Logger(c).Error("audit chain verification failed", "auditEventId", brokenID)
+		c.JSON(http.StatusOK, gin.H{
+			"valid":         false,
+			"brokenEventId": brokenID,
+			"checked":       checked,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":       true,
+		"checked":     checked,
+		"lastEventId": lastID,
+	})
+}
@@ -1,7 +1,6 @@
 package controllers
 
 import (
-	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -13,74 +12,92 @@ import (
 
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Password string `json:"password" binding:"required" redact:"secret"`
 }
 
 // Login handles user authentication
 func Login(c *gin.Context) {
+	logger := utils.Logger(c)
+
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("Invalid request body: %v", err)
+		logger.Warn("invalid login request body", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
 		return
 	}
 
-	log.Printf("Login attempt for email: %s", req.Email)
-	log.Printf("Request body: %+v", req)
-
-	// Check database connection
-	if err := config.DB.Raw("SELECT 1").Error; err != nil {
-		log.Printf("Database connection error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+	if rejectIfLocked(c, req.Email) {
+		logger.Warn("login rejected while locked out", "email", req.Email)
 		return
 	}
-	log.Printf("Database connection is healthy")
-
-	// Log all users in the database for debugging
-	var allUsers []models.User
-	if err := config.DB.Find(&allUsers).Error; err != nil {
-		log.Printf("Error fetching all users: %v", err)
-	} else {
-		log.Printf("All users in database: %+v", allUsers)
-	}
 
 	var user models.User
-	if err := config.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		log.Printf("User not found: %v", err)
+	if err := config.DB.Where("LOWER(email) = ?", normalizeEmail(req.Email)).First(&user).Error; err != nil {
+		logger.Warn("login failed", "email", req.Email, "reason", "unknown user")
+		recordLoginFailure(c, req.Email)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	log.Printf("User found: %+v", user)
-	log.Printf("Stored password hash: %s", user.PasswordHash)
-	log.Printf("Attempting to compare with provided password: %s", req.Password)
-
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		log.Printf("Password mismatch: %v", err)
+		logger.Warn("login failed", "email", req.Email, "reason", "password mismatch")
+		recordLoginFailure(c, req.Email)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	log.Printf("Password verified successfully")
+	if !user.IsActive() {
+		logger.Warn("login rejected for deactivated account", "userId", user.ID)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is deactivated"})
+		return
+	}
+
+	if user.MFAEnabled || config.MFARequired(string(user.Role)) {
+		respondMFAChallenge(c, &user)
+		return
+	}
+
+	completeLogin(c, &user, nil)
+}
 
-	token, err := utils.GenerateToken(&user)
+// completeLogin starts a session for an authenticated user and writes the
+// login response. extra is merged into the response body.
+func completeLogin(c *gin.Context, user *models.User, extra gin.H) {
+	if !user.IsActive() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is deactivated"})
+		return
+	}
+
+	token, refreshToken, err := issueSession(c, user)
 	if err != nil {
-		log.Printf("Token generation failed: %v", err)
+		utils.Logger(c).Error("token generation failed", "userId", user.ID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	log.Printf("Login successful for user: %s", user.Email)
+	utils.Logger(c).Info("login successful", "userId", user.ID, "role", user.Role)
+	if err := loginGuard.Succeed(normalizeEmail(user.Email)); err != nil {
+		utils.Logger(c).Error("failed to reset login attempts", "error", err)
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
+	response := gin.H{
+		"token":        token,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(utils.AccessTokenTTL().Seconds()),
 		"user": gin.H{
 			"id":    user.ID,
 			"name":  user.Name,
 			"email": user.Email,
 			"role":  user.Role,
+			// Lets the client decide which actions to offer
+			"permissions": config.PermissionsFor(user.Role),
 		},
-	})
+	}
+	for k, v := range extra {
+		response[k] = v
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // ValidateToken validates the JWT token and returns the user data
@@ -102,6 +119,7 @@ func ValidateToken(c *gin.Context) {
 	user.PasswordHash = ""
 
 	c.JSON(http.StatusOK, gin.H{
-		"user": user,
+		"user":        user,
+		"permissions": config.PermissionsFor(user.Role),
 	})
 } 
\ No newline at end of file
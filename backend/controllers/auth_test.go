@@ -18,7 +18,7 @@ func TestLogin(t *testing.T) {
 	// Setup
 	gin.SetMode(gin.TestMode)
 	config.InitDB()
-	config.DB.AutoMigrate(&models.User{})
+	config.DB.AutoMigrate(&models.User{}, &models.Session{}, &models.RefreshToken{})
 
 	// Create test user
 	password, _ := bcrypt.GenerateFromPassword([]byte("test123"), bcrypt.DefaultCost)
@@ -89,6 +89,7 @@ func TestLogin(t *testing.T) {
 				err := json.Unmarshal(w.Body.Bytes(), &response)
 				assert.NoError(t, err)
 				assert.Contains(t, response, "token")
+				assert.Contains(t, response, "refreshToken")
 				assert.Contains(t, response, "user")
 			}
 		})
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"gorm.io/gorm"
+)
+
+type AssignCareTeamRequest struct {
+	UserID uint                `json:"userId" binding:"required"`
+	Role   models.CareTeamRole `json:"role" binding:"required"`
+}
+
+// scopePatients limits a patient query to the records the current user may
+// access. Users with patient:access_all see everything, everyone else only
+// the patients whose care team they belong to.
+func scopePatients(c *gin.Context, query *gorm.DB) *gorm.DB {
+	if hasPermission(c, models.PermPatientAccessAll) {
+		return query
+	}
+	return query.Where("patients.id IN (?)",
+		config.DB.Model(&models.CareTeamMember{}).Select("patient_id").Where("user_id = ?", c.GetUint("userID")))
+}
+
+// findScopedPatient loads a patient the current user may access, either
+// through a care team or an active break-glass grant. Patients outside the
+// user's scope are reported as not found so that their existence is not
+// disclosed.
+func findScopedPatient(c *gin.Context, patientID uint64, patient *models.Patient) bool {
+	err := scopePatients(c, config.DB.Model(&models.Patient{})).First(patient, patientID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return findEmergencyPatient(c, patientID, patient)
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to fetch patient", "patientId", patientID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patient"})
+		return false
+	}
+	return true
+}
+
+// findEmergencyPatient loads a patient through the user's active break-glass
+// grant and records the access against it
+func findEmergencyPatient(c *gin.Context, patientID uint64, patient *models.Patient) bool {
+	grant, err := activeEmergencyAccess(c, patientID)
+	if err == nil && grant == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+		return false
+	}
+	if err == nil {
+		err = config.DB.First(patient, patientID).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+		return false
+	}
+	if err == nil {
+		err = useEmergencyAccess(c, grant)
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to fetch patient", "patientId", patientID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patient"})
+		return false
+	}
+	return true
+}
+
+func parsePatientID(c *gin.Context) (uint64, bool) {
+	patientID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient ID"})
+		return 0, false
+	}
+	return patientID, true
+}
+
+// GetCareTeam lists the staff assigned to a patient
+func GetCareTeam(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+
+	var members []models.CareTeamMember
+	if err := config.DB.Preload("User").Where("patient_id = ?", patient.ID).Order("id").Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch care team"})
+		return
+	}
+	if err := recordAudit(c, config.DB, models.AuditRead, []string{"careTeam"}, patient.ID); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch care team"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": members})
+}
+
+// AssignCareTeamMember adds a user to a patient's care team. Physicians must
+// be doctors and a patient has at most one primary physician.
+func AssignCareTeamMember(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	var req AssignCareTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Role.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid care team role. Use primary_physician, consulting or nurse"})
+		return
+	}
+
+	var patient models.Patient
+	if err := config.DB.First(&patient, patientID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, req.UserID).Error; err != nil || !user.IsActive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found or deactivated"})
+		return
+	}
+	if req.Role != models.CareTeamNurse && user.Role != models.RoleDoctor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only doctors can be assigned as physicians"})
+		return
+	}
+	if user.Role == models.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Admins cannot be assigned to a care team"})
+		return
+	}
+
+	member := models.CareTeamMember{
+		PatientID:  patient.ID,
+		UserID:     user.ID,
+		Role:       req.Role,
+		AssignedBy: c.GetUint("userID"),
+	}
+
+	var conflict string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.CareTeamMember{}).Where("patient_id = ? AND user_id = ?", patient.ID, user.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			conflict = "User is already on this patient's care team"
+			return nil
+		}
+
+		if req.Role == models.CareTeamPrimaryPhysician {
+			if err := tx.Model(&models.CareTeamMember{}).
+				Where("patient_id = ? AND role = ?", patient.ID, models.CareTeamPrimaryPhysician).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				conflict = "Patient already has a primary physician"
+				return nil
+			}
+		}
+
+		return tx.Create(&member).Error
+	})
+	if err != nil {
+		utils.Logger(c).Error("failed to assign care team member", "patientId", patient.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign care team member"})
+		return
+	}
+	if conflict != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": conflict})
+		return
+	}
+
+	utils.Logger(c).Info("care team member assigned", "patientId", patient.ID, "targetUserId", user.ID, "careTeamRole", member.Role)
+	member.User = &user
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    member,
+		"message": "Care team member assigned successfully",
+	})
+}
+
+// UnassignCareTeamMember removes a user from a patient's care team
+func UnassignCareTeamMember(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	result := config.DB.Where("patient_id = ? AND user_id = ?", patientID, userID).Delete(&models.CareTeamMember{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unassign care team member"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Care team member not found"})
+		return
+	}
+
+	utils.Logger(c).Info("care team member unassigned", "patientId", patientID, "targetUserId", userID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Care team member unassigned successfully",
+	})
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCareTeamScope(t *testing.T) {
+	setupTestDB(t)
+
+	receptionist := createTestUser(t, models.RoleReceptionist)
+	assigned := createTestUser(t, models.RoleDoctor)
+	outsider := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, receptionist.ID, time.Date(1980, 5, 1, 0, 0, 0, 0, time.UTC))
+	assignTestCareTeam(t, &patient, &assigned)
+
+	tests := []struct {
+		name       string
+		user       models.User
+		wantStatus int
+	}{
+		{name: "care team member", user: assigned, wantStatus: http.StatusOK},
+		{name: "doctor outside the care team", user: outsider, wantStatus: http.StatusNotFound},
+		{name: "access to all patients", user: receptionist, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newTestContext(http.MethodGet, "/patients/1", nil, &tt.user, patientParams(patient.ID))
+			GetPatient(c)
+			assert.Equal(t, tt.wantStatus, w.Code)
+
+			c, w = newTestContext(http.MethodGet, "/patients/1/care-team", nil, &tt.user, patientParams(patient.ID))
+			GetCareTeam(c)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestCareTeamAssignmentGrantsAccess(t *testing.T) {
+	setupTestDB(t)
+
+	receptionist := createTestUser(t, models.RoleReceptionist)
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, receptionist.ID, time.Date(1975, 2, 3, 0, 0, 0, 0, time.UTC))
+
+	c, w := newTestContext(http.MethodGet, "/patients/1", nil, &doctor, patientParams(patient.ID))
+	GetPatient(c)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	body := AssignCareTeamRequest{UserID: doctor.ID, Role: models.CareTeamPrimaryPhysician}
+	c, w = newTestContext(http.MethodPost, "/patients/1/care-team", body, &receptionist, patientParams(patient.ID))
+	AssignCareTeamMember(c)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	c, w = newTestContext(http.MethodGet, "/patients/1", nil, &doctor, patientParams(patient.ID))
+	GetPatient(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	c, w = newTestContext(http.MethodDelete, "/patients/1/care-team/1", nil, &receptionist, patientParams(patient.ID, gin.Param{Key: "userId", Value: fmt.Sprint(doctor.ID)}))
+	UnassignCareTeamMember(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	c, w = newTestContext(http.MethodGet, "/patients/1", nil, &doctor, patientParams(patient.ID))
+	GetPatient(c)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,588 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"gorm.io/gorm"
+)
+
+type ClinicalNoteRequest struct {
+	// Template prefills the title and the sections left empty
+	Template    string `json:"template"`
+	Title       string `json:"title" binding:"max=200" redact:"phi"`
+	Subjective  string `json:"subjective" redact:"phi"`
+	Objective   string `json:"objective" redact:"phi"`
+	Assessment  string `json:"assessment" redact:"phi"`
+	Plan        string `json:"plan" redact:"phi"`
+	EncounterID *uint  `json:"encounterId"`
+}
+
+// UpdateClinicalNoteRequest changes the sections that are set and leaves the
+// rest
+type UpdateClinicalNoteRequest struct {
+	Title      string `json:"title" binding:"max=200" redact:"phi"`
+	Subjective string `json:"subjective" redact:"phi"`
+	Objective  string `json:"objective" redact:"phi"`
+	Assessment string `json:"assessment" redact:"phi"`
+	Plan       string `json:"plan" redact:"phi"`
+}
+
+type NoteAddendumRequest struct {
+	Text string `json:"text" binding:"required" redact:"phi"`
+}
+
+var errNoteChanged = errors.New("note was changed concurrently")
+
+// isTrainee reports whether the calling user is a trainee
+func isTrainee(c *gin.Context) (bool, error) {
+	var user models.User
+	if err := config.DB.Select("trainee").First(&user, c.GetUint("userID")).Error; err != nil {
+		return false, err
+	}
+	return user.Trainee, nil
+}
+
+// visibleNotes keeps signed notes and the caller's own drafts; drafts are
+// private to their author
+func visibleNotes(c *gin.Context, query *gorm.DB) *gorm.DB {
+	return query.Where("status <> ? OR author_id = ?", models.NoteDraft, c.GetUint("userID"))
+}
+
+// attachNoteAddenda loads the addenda of each note, oldest first
+func attachNoteAddenda(notes []models.ClinicalNote) error {
+	if len(notes) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(notes))
+	for i := range notes {
+		ids[i] = notes[i].ID
+	}
+	var addenda []models.NoteAddendum
+	if err := config.DB.Where("note_id IN ?", ids).Order("id").Find(&addenda).Error; err != nil {
+		return err
+	}
+	byNote := make(map[uint][]models.NoteAddendum)
+	for _, addendum := range addenda {
+		byNote[addendum.NoteID] = append(byNote[addendum.NoteID], addendum)
+	}
+	for i := range notes {
+		notes[i].Addenda = byNote[notes[i].ID]
+		if notes[i].Addenda == nil {
+			notes[i].Addenda = []models.NoteAddendum{}
+		}
+	}
+	return nil
+}
+
+// findPatientNote loads one note of a patient the user may access. Other
+// authors' drafts answer 404. It reports whether it found it, and responds
+// otherwise.
+func findPatientNote(c *gin.Context, patient *models.Patient, note *models.ClinicalNote) bool {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return false
+	}
+	noteID, err := strconv.ParseUint(c.Param("noteId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+		return false
+	}
+	if !findScopedPatient(c, patientID, patient) {
+		return false
+	}
+	query := visibleNotes(c, config.DB.Preload("Author").Where("patient_id = ?", patient.ID))
+	if err := query.First(note, noteID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return false
+	}
+	return true
+}
+
+// writeNote saves the given columns of a note if it is still in the status
+// it was read in, and records the audit entry
+func writeNote(c *gin.Context, note *models.ClinicalNote, from models.NoteStatus, columns ...string) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ClinicalNote{}).
+			Where("id = ? AND status = ?", note.ID, from).
+			Select(columns).
+			Updates(note)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errNoteChanged
+		}
+		return recordAudit(c, tx, models.AuditUpdate, []string{"notes"}, note.PatientID)
+	})
+}
+
+// respondNoteError answers the lifecycle errors of notes
+func respondNoteError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, models.ErrNoteNotDraft), errors.Is(err, models.ErrNoteNotAwaiting):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errNoteChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": "Note was changed by someone else. Reload it and retry."})
+	case errors.Is(err, models.ErrNoteEmpty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Write at least one section before signing"})
+	case errors.Is(err, models.ErrNoteCosignOwn), errors.Is(err, models.ErrNoteCosignTrainee):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		utils.Logger(c).Error("failed to "+action+" note", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " note"})
+	}
+}
+
+// freeTextNote turns the legacy notes field of a patient into a legacy note,
+// with the text in the assessment section. It returns nil when the field is
+// empty. The note has no author and no signature: whoever last saved the
+// patient did not necessarily write the notes.
+func freeTextNote(patient *models.Patient) *models.ClinicalNote {
+	return legacyNote(patient.ID, patient.Notes, patient.UpdatedAt)
+}
+
+// legacyNote is an unsigned note without author holding text, or nil when
+// text is empty
+func legacyNote(patientID uint, text string, writtenAt time.Time) *models.ClinicalNote {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	note := &models.ClinicalNote{PatientID: patientID, Status: models.NoteLegacy, Assessment: text, CreatedAt: writtenAt}
+	// Cannot fail: the template exists
+	_ = note.ApplyTemplate(models.NoteFreeText)
+	return note
+}
+
+// ConvertFreeTextNotes moves the free-text notes of every patient, including
+// those in the trash, into legacy clinical notes
+func ConvertFreeTextNotes(db *gorm.DB) (int, error) {
+	var patients []models.Patient
+	if err := db.Unscoped().Where("notes <> ''").Find(&patients).Error; err != nil {
+		return 0, err
+	}
+
+	converted := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := range patients {
+			if note := freeTextNote(&patients[i]); note != nil {
+				if err := tx.Create(note).Error; err != nil {
+					return err
+				}
+				converted++
+			}
+			if err := tx.Unscoped().Model(&models.Patient{}).Where("id = ?", patients[i].ID).UpdateColumn("notes", "").Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return converted, nil
+}
+
+// legacyEncounterNotes is an encounter with the free-text notes column
+// encounters had before clinical notes were linked to them
+type legacyEncounterNotes struct {
+	ID        uint
+	PatientID uint
+	Notes     string
+	UpdatedAt time.Time
+}
+
+// ConvertEncounterNotes moves the free-text notes of encounters into legacy
+// clinical notes of the encounter and drops the column. It returns the
+// number of encounters converted.
+func ConvertEncounterNotes(db *gorm.DB) (int, error) {
+	if !db.Migrator().HasColumn(&models.Encounter{}, "notes") {
+		return 0, nil
+	}
+
+	var encounters []legacyEncounterNotes
+	if err := db.Table("encounters").Where("notes <> ''").Find(&encounters).Error; err != nil {
+		return 0, err
+	}
+
+	converted := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := range encounters {
+			note := legacyNote(encounters[i].PatientID, encounters[i].Notes, encounters[i].UpdatedAt)
+			if note == nil {
+				continue
+			}
+			note.EncounterID = &encounters[i].ID
+			if err := tx.Create(note).Error; err != nil {
+				return err
+			}
+			converted++
+		}
+		return tx.Migrator().DropColumn(&models.Encounter{}, "notes")
+	})
+	if err != nil {
+		return 0, err
+	}
+	return converted, nil
+}
+
+// GetNoteTemplates lists the templates notes can be started from
+func GetNoteTemplates(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": models.NoteTemplates})
+}
+
+// GetNotes lists a patient's clinical notes, newest first, with their
+// addenda. Drafts are only listed for their author. Supports status and
+// encounterId to filter.
+func GetNotes(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Preload("Author")
+	if value := c.Query("status"); value != "" {
+		if !models.NoteStatus(value).Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Use draft, awaiting_cosign, signed or legacy"})
+			return
+		}
+		query = query.Where("status = ?", value)
+	}
+	if value := c.Query("encounterId"); value != "" {
+		encounterID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid encounterId"})
+			return
+		}
+		query = query.Where("encounter_id = ?", encounterID)
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+
+	var notes []models.ClinicalNote
+	if err := visibleNotes(c, query.Where("patient_id = ?", patient.ID)).Order("created_at DESC, id DESC").Find(&notes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
+		return
+	}
+	if err := attachNoteAddenda(notes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
+		return
+	}
+	if err := recordAudit(c, config.DB, models.AuditRead, []string{"notes"}, patient.ID); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": notes})
+}
+
+// GetNote returns one clinical note with its addenda
+func GetNote(c *gin.Context) {
+	var patient models.Patient
+	var note models.ClinicalNote
+	if !findPatientNote(c, &patient, &note) {
+		return
+	}
+
+	notes := []models.ClinicalNote{note}
+	if err := attachNoteAddenda(notes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch note"})
+		return
+	}
+	if err := recordAudit(c, config.DB, models.AuditRead, []string{"notes"}, patient.ID); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch note"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": notes[0]})
+}
+
+// CreateNote starts a draft note by the calling doctor
+func CreateNote(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	var req ClinicalNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	authorID := c.GetUint("userID")
+	note := models.ClinicalNote{
+		AuthorID:   &authorID,
+		Title:      strings.TrimSpace(req.Title),
+		Subjective: req.Subjective,
+		Objective:  req.Objective,
+		Assessment: req.Assessment,
+		Plan:       req.Plan,
+		Status:     models.NoteDraft,
+	}
+	if req.Template != "" {
+		if err := note.ApplyTemplate(req.Template); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown template " + req.Template + ". See GET /notes/templates"})
+			return
+		}
+	}
+	if note.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title or template is required"})
+		return
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+	note.PatientID = patient.ID
+
+	if req.EncounterID != nil {
+		var encounter models.Encounter
+		if err := config.DB.Where("patient_id = ?", patient.ID).First(&encounter, *req.EncounterID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "encounterId is not an encounter of this patient"})
+			return
+		}
+		if encounter.Status == models.EncounterClosed {
+			c.JSON(http.StatusConflict, gin.H{"error": "Encounter is closed and can no longer be changed"})
+			return
+		}
+		note.EncounterID = &encounter.ID
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&note).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditCreate, []string{"notes"}, patient.ID)
+	})
+	if err != nil {
+		utils.Logger(c).Error("failed to create note", "patientId", patient.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create note"})
+		return
+	}
+
+	note.Addenda = []models.NoteAddendum{}
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    note,
+		"message": "Note created successfully",
+	})
+}
+
+// UpdateNote changes the sections of a draft note. Only the author can edit
+// a draft; signed notes answer 409.
+func UpdateNote(c *gin.Context) {
+	var req UpdateClinicalNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var patient models.Patient
+	var note models.ClinicalNote
+	if !findPatientNote(c, &patient, &note) {
+		return
+	}
+	if !note.WrittenBy(c.GetUint("userID")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can edit a note"})
+		return
+	}
+	if note.Status != models.NoteDraft {
+		respondNoteError(c, models.ErrNoteNotDraft, "update")
+		return
+	}
+
+	if title := strings.TrimSpace(req.Title); title != "" {
+		note.Title = title
+	}
+	sections := []struct {
+		value string
+		field *string
+	}{
+		{req.Subjective, &note.Subjective},
+		{req.Objective, &note.Objective},
+		{req.Assessment, &note.Assessment},
+		{req.Plan, &note.Plan},
+	}
+	for _, section := range sections {
+		if section.value != "" {
+			*section.field = section.value
+		}
+	}
+
+	if err := writeNote(c, &note, models.NoteDraft, "title", "subjective", "objective", "assessment", "plan", "updated_at"); err != nil {
+		respondNoteError(c, err, "update")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    note,
+		"message": "Note updated successfully",
+	})
+}
+
+// DeleteNote discards a draft note. Signed notes cannot be deleted.
+func DeleteNote(c *gin.Context) {
+	var patient models.Patient
+	var note models.ClinicalNote
+	if !findPatientNote(c, &patient, &note) {
+		return
+	}
+	if !note.WrittenBy(c.GetUint("userID")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can delete a draft"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("status = ?", models.NoteDraft).Delete(&note)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return models.ErrNoteNotDraft
+		}
+		return recordAudit(c, tx, models.AuditUpdate, []string{"notes"}, patient.ID)
+	})
+	if err != nil {
+		respondNoteError(c, err, "delete")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Draft deleted successfully",
+	})
+}
+
+// SignNote signs a draft note, after which it cannot be changed. Notes
+// signed by trainees wait for a co-signature.
+func SignNote(c *gin.Context) {
+	var patient models.Patient
+	var note models.ClinicalNote
+	if !findPatientNote(c, &patient, &note) {
+		return
+	}
+	if !note.WrittenBy(c.GetUint("userID")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can sign a note"})
+		return
+	}
+
+	trainee, err := isTrainee(c)
+	if err != nil {
+		respondNoteError(c, err, "sign")
+		return
+	}
+	if err := note.Sign(trainee, time.Now()); err != nil {
+		respondNoteError(c, err, "sign")
+		return
+	}
+	if err := writeNote(c, &note, models.NoteDraft, "status", "signed_at", "updated_at"); err != nil {
+		respondNoteError(c, err, "sign")
+		return
+	}
+
+	utils.Logger(c).Info("note signed", "patientId", patient.ID, "noteId", note.ID, "status", note.Status)
+	message := "Note signed successfully"
+	if note.Status == models.NoteAwaitingCosign {
+		message = "Note signed and awaiting co-signature"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    note,
+		"message": message,
+	})
+}
+
+// CosignNote completes a trainee's note. The co-signer must be a doctor who
+// is not a trainee and not the author.
+func CosignNote(c *gin.Context) {
+	var patient models.Patient
+	var note models.ClinicalNote
+	if !findPatientNote(c, &patient, &note) {
+		return
+	}
+
+	trainee, err := isTrainee(c)
+	if err != nil {
+		respondNoteError(c, err, "co-sign")
+		return
+	}
+	if err := note.Cosign(c.GetUint("userID"), trainee, time.Now()); err != nil {
+		respondNoteError(c, err, "co-sign")
+		return
+	}
+	if err := writeNote(c, &note, models.NoteAwaitingCosign, "status", "cosigner_id", "cosigned_at", "updated_at"); err != nil {
+		respondNoteError(c, err, "co-sign")
+		return
+	}
+
+	utils.Logger(c).Info("note co-signed", "patientId", patient.ID, "noteId", note.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    note,
+		"message": "Note co-signed successfully",
+	})
+}
+
+// AddNoteAddendum appends text to a signed or legacy note. Any doctor may
+// add one.
+func AddNoteAddendum(c *gin.Context) {
+	var req NoteAddendumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "text is required"})
+		return
+	}
+
+	var patient models.Patient
+	var note models.ClinicalNote
+	if !findPatientNote(c, &patient, &note) {
+		return
+	}
+	if note.Status == models.NoteDraft {
+		c.JSON(http.StatusConflict, gin.H{"error": "Drafts can be edited; addenda are for signed notes"})
+		return
+	}
+
+	addendum := models.NoteAddendum{
+		NoteID:    note.ID,
+		PatientID: patient.ID,
+		AuthorID:  c.GetUint("userID"),
+		Text:      req.Text,
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&addendum).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditUpdate, []string{"notes"}, patient.ID)
+	})
+	if err != nil {
+		utils.Logger(c).Error("failed to add addendum", "patientId", patient.ID, "noteId", note.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add addendum"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    addendum,
+		"message": "Addendum added successfully",
+	})
+}
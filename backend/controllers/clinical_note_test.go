@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreeTextNote(t *testing.T) {
+	updatedAt := time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)
+	patient := models.Patient{ID: 1, Notes: "  Lives alone, daughter visits weekly ", UpdatedBy: 2, UpdatedAt: updatedAt}
+
+	note := freeTextNote(&patient)
+	require.NotNil(t, note)
+	assert.Equal(t, models.NoteLegacy, note.Status)
+	assert.Nil(t, note.AuthorID, "the last editor did not necessarily write the notes")
+	assert.Nil(t, note.SignedAt)
+	assert.Equal(t, "Lives alone, daughter visits weekly", note.Assessment)
+	assert.Equal(t, models.NoteFreeText, note.Template)
+	assert.Equal(t, updatedAt, note.CreatedAt)
+
+	assert.Nil(t, freeTextNote(&models.Patient{ID: 1, Notes: " "}))
+}
+
+func TestCreatePatientRejectsNotes(t *testing.T) {
+	doctor := models.User{ID: 1, Role: models.RoleDoctor}
+	req := PatientRequest{
+		FirstName:        "Jane",
+		LastName:         "Doe",
+		Phone:            "555-0100",
+		DateOfBirth:      "1980-01-01",
+		Gender:           "female",
+		Address:          "1 Main Street",
+		EmergencyContact: "John Doe",
+		EmergencyPhone:   "555-0101",
+		Notes:            "Anxious about needles",
+	}
+
+	c, w := newTestContext(http.MethodPost, "/patients", req, &doctor, nil)
+	CreatePatient(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "/patients/:id/notes")
+}
+
+func TestConvertFreeTextNotes(t *testing.T) {
+	setupTestDB(t)
+
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, doctor.ID, time.Date(1955, 6, 30, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, config.DB.Model(&patient).UpdateColumn("notes", "Prefers morning appointments").Error)
+
+	_, err := ConvertFreeTextNotes(config.DB)
+	require.NoError(t, err)
+
+	var note models.ClinicalNote
+	require.NoError(t, config.DB.Where("patient_id = ?", patient.ID).First(&note).Error)
+	assert.Equal(t, models.NoteLegacy, note.Status)
+	assert.Nil(t, note.AuthorID)
+	assert.Nil(t, note.SignedAt)
+	assert.Equal(t, "Prefers morning appointments", note.Assessment)
+
+	require.NoError(t, config.DB.First(&patient, patient.ID).Error)
+	assert.Empty(t, patient.Notes)
+
+	// Legacy notes are read-only like signed ones
+	err = config.DB.Model(&note).Update("assessment", "Changed").Error
+	assert.Error(t, err)
+	err = config.DB.Delete(&note).Error
+	assert.Error(t, err)
+}
+
+func TestCreateNoteInClosedEncounter(t *testing.T) {
+	setupTestDB(t)
+
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, doctor.ID, time.Date(1988, 2, 14, 0, 0, 0, 0, time.UTC))
+	assignTestCareTeam(t, &patient, &doctor)
+	encounter := openTestEncounter(t, &doctor, &patient)
+	require.NoError(t, config.DB.Model(&encounter).Update("status", models.EncounterClosed).Error)
+
+	req := ClinicalNoteRequest{Template: "consultation", EncounterID: &encounter.ID}
+	c, w := newTestContext(http.MethodPost, "/patients/1/notes", req, &doctor, patientParams(patient.ID))
+	CreateNote(c)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestSignedNoteTriggers(t *testing.T) {
+	setupTestDB(t)
+
+	doctor := createTestUser(t, models.RoleDoctor)
+	other := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, doctor.ID, time.Date(1970, 8, 8, 0, 0, 0, 0, time.UTC))
+	encounter := openTestEncounter(t, &doctor, &patient)
+	signedAt := time.Now()
+	note := models.ClinicalNote{PatientID: patient.ID, AuthorID: &doctor.ID, Title: "Consultation", Assessment: "Migraine", Status: models.NoteSigned, SignedAt: &signedAt}
+	require.NoError(t, config.DB.Create(&note).Error)
+	addendum := models.NoteAddendum{NoteID: note.ID, PatientID: patient.ID, AuthorID: doctor.ID, Text: "Seen again"}
+	require.NoError(t, config.DB.Create(&addendum).Error)
+
+	changes := map[string]interface{}{
+		"encounter_id": encounter.ID,
+		"cosigner_id":  other.ID,
+		"status":       models.NoteDraft,
+	}
+	for column, value := range changes {
+		err := config.DB.Model(&models.ClinicalNote{}).Where("id = ?", note.ID).Update(column, value).Error
+		assert.Error(t, err, column)
+	}
+
+	assert.Error(t, config.DB.Delete(&models.ClinicalNote{}, note.ID).Error)
+	assert.Error(t, config.DB.Delete(&models.NoteAddendum{}, addendum.ID).Error)
+}
+
+func TestEncounterRequestsRejectNotes(t *testing.T) {
+	doctor := models.User{ID: 1, Role: models.RoleDoctor}
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		body    interface{}
+	}{
+		{name: "open", handler: OpenEncounter, body: OpenEncounterRequest{Type: models.EncounterOutpatient, Reason: "Cough", Notes: "Dry cough"}},
+		{name: "update", handler: UpdateEncounter, body: UpdateEncounterRequest{Notes: "Dry cough"}},
+		{name: "close", handler: CloseEncounter, body: CloseEncounterRequest{Notes: "Dry cough"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newTestContext(http.MethodPost, "/patients/1/encounters", tt.body, &doctor, encounterParams(1, 1))
+			tt.handler(c)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "/patients/:id/notes")
+		})
+	}
+}
+
+func TestConvertEncounterNotes(t *testing.T) {
+	setupTestDB(t)
+
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, doctor.ID, time.Date(1959, 4, 21, 0, 0, 0, 0, time.UTC))
+	encounter := openTestEncounter(t, &doctor, &patient)
+	require.NoError(t, config.DB.Exec("ALTER TABLE encounters ADD COLUMN IF NOT EXISTS notes text").Error)
+	require.NoError(t, config.DB.Exec("UPDATE encounters SET notes = ? WHERE id = ?", "Advised rest", encounter.ID).Error)
+
+	count, err := ConvertEncounterNotes(config.DB)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	var note models.ClinicalNote
+	require.NoError(t, config.DB.Where("encounter_id = ?", encounter.ID).First(&note).Error)
+	assert.Equal(t, patient.ID, note.PatientID)
+	assert.Equal(t, models.NoteLegacy, note.Status)
+	assert.Nil(t, note.AuthorID)
+	assert.Nil(t, note.SignedAt)
+	assert.Equal(t, "Advised rest", note.Assessment)
+	assert.False(t, config.DB.Migrator().HasColumn(&models.Encounter{}, "notes"), "the old column is dropped")
+}
@@ -0,0 +1,433 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultCodeSearchLimit = 20
+	maxCodeSearchLimit     = 100
+)
+
+type DiagnosisRequest struct {
+	Code string `json:"code" binding:"required"`
+	// Description defaults to the catalogue description of the code
+	Description  string                 `json:"description" binding:"max=500" redact:"phi"`
+	Status       models.DiagnosisStatus `json:"status"`
+	OnsetDate    string                 `json:"onsetDate"`
+	ResolvedDate string                 `json:"resolvedDate"`
+	// EncounterID links the diagnosis to an open encounter of the patient
+	EncounterID *uint `json:"encounterId"`
+}
+
+// DiagnosisUpdateRequest changes the fields that are set and leaves the rest
+type DiagnosisUpdateRequest struct {
+	Code         string                 `json:"code"`
+	Description  string                 `json:"description" binding:"max=500" redact:"phi"`
+	Status       models.DiagnosisStatus `json:"status"`
+	OnsetDate    string                 `json:"onsetDate"`
+	ResolvedDate string                 `json:"resolvedDate"`
+}
+
+var errDiagnosisExists = errors.New("diagnosis already on the problem list")
+
+// codeDiagnosis sets the ICD-10 code of a diagnosis and, unless one is
+// given, the catalogue description. It reports whether it responded.
+func codeDiagnosis(c *gin.Context, diagnosis *models.Diagnosis, code, description string) bool {
+	entry, ok := utils.CurrentICD10Catalogue().Lookup(code)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown ICD-10 code " + code + ". Search GET /codes/icd10 for valid codes"})
+		return true
+	}
+	diagnosis.Code = entry.Code
+	diagnosis.Description = entry.Description
+	if description = strings.TrimSpace(description); description != "" {
+		diagnosis.Description = description
+	}
+	return false
+}
+
+// dateDiagnosis validates the status of a diagnosis and applies its onset
+// and resolution dates. A resolved diagnosis without a resolution date is
+// resolved today; an active one has none. It reports whether it responded.
+func dateDiagnosis(c *gin.Context, diagnosis *models.Diagnosis, onset, resolved string) bool {
+	if !diagnosis.Status.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Use active or resolved"})
+		return true
+	}
+	if resolved != "" && diagnosis.Status == models.DiagnosisActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "resolvedDate needs status resolved"})
+		return true
+	}
+
+	dates := []struct {
+		param string
+		value string
+		field **time.Time
+	}{
+		{"onsetDate", onset, &diagnosis.OnsetDate},
+		{"resolvedDate", resolved, &diagnosis.ResolvedDate},
+	}
+	for _, date := range dates {
+		if date.value == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", date.value)
+		if err != nil || t.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + date.param + ". Use a past date in YYYY-MM-DD format"})
+			return true
+		}
+		*date.field = &t
+	}
+
+	if diagnosis.Status == models.DiagnosisActive {
+		diagnosis.ResolvedDate = nil
+	} else if diagnosis.ResolvedDate == nil {
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		diagnosis.ResolvedDate = &today
+	}
+	if diagnosis.OnsetDate != nil && diagnosis.ResolvedDate != nil && diagnosis.ResolvedDate.Before(*diagnosis.OnsetDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "resolvedDate cannot be before onsetDate"})
+		return true
+	}
+	return false
+}
+
+// saveDiagnosis creates or updates a diagnosis, refusing a second active
+// entry for the same code, and records the audit entry
+func saveDiagnosis(c *gin.Context, diagnosis *models.Diagnosis) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if diagnosis.Status == models.DiagnosisActive && diagnosis.Code != "" {
+			var count int64
+			if err := tx.Model(&models.Diagnosis{}).
+				Where("patient_id = ? AND id <> ? AND status = ? AND code = ?",
+					diagnosis.PatientID, diagnosis.ID, models.DiagnosisActive, diagnosis.Code).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return errDiagnosisExists
+			}
+		}
+		if err := tx.Save(diagnosis).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditUpdate, []string{"diagnoses"}, diagnosis.PatientID)
+	})
+}
+
+// freeTextDiagnosis turns the legacy diagnosis field of a patient into an
+// uncoded active entry of the problem list. It returns nil when the field
+// is empty.
+func freeTextDiagnosis(patient *models.Patient) *models.Diagnosis {
+	text := strings.TrimSpace(patient.Diagnosis)
+	if text == "" {
+		return nil
+	}
+	return &models.Diagnosis{
+		PatientID:   patient.ID,
+		Description: text,
+		Status:      models.DiagnosisActive,
+		DiagnosedBy: patient.UpdatedBy,
+		UpdatedBy:   patient.UpdatedBy,
+	}
+}
+
+// ConvertFreeTextDiagnoses moves the free-text diagnosis of every patient,
+// including those in the trash, into uncoded problem list entries
+func ConvertFreeTextDiagnoses(db *gorm.DB) (int, error) {
+	var patients []models.Patient
+	if err := db.Unscoped().Where("diagnosis <> ''").Find(&patients).Error; err != nil {
+		return 0, err
+	}
+
+	converted := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := range patients {
+			if diagnosis := freeTextDiagnosis(&patients[i]); diagnosis != nil {
+				if err := tx.Create(diagnosis).Error; err != nil {
+					return err
+				}
+				converted++
+			}
+			if err := tx.Unscoped().Model(&models.Patient{}).Where("id = ?", patients[i].ID).UpdateColumn("diagnosis", "").Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return converted, nil
+}
+
+// inClosedEncounter reports whether a diagnosis was made in an encounter
+// that has since been closed
+func inClosedEncounter(diagnosis *models.Diagnosis) (bool, error) {
+	if diagnosis.EncounterID == nil {
+		return false, nil
+	}
+	var encounter models.Encounter
+	if err := config.DB.Select("status").First(&encounter, *diagnosis.EncounterID).Error; err != nil {
+		return false, err
+	}
+	return encounter.Status == models.EncounterClosed, nil
+}
+
+// findPatientDiagnosis loads one diagnosis of a patient the user may
+// access. It reports whether it found it, and responds otherwise.
+func findPatientDiagnosis(c *gin.Context, patient *models.Patient, diagnosis *models.Diagnosis) bool {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return false
+	}
+	diagnosisID, err := strconv.ParseUint(c.Param("diagnosisId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid diagnosis ID"})
+		return false
+	}
+	if !findScopedPatient(c, patientID, patient) {
+		return false
+	}
+	if err := config.DB.Where("patient_id = ?", patient.ID).First(diagnosis, diagnosisID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Diagnosis not found"})
+		return false
+	}
+	return true
+}
+
+// SearchICD10Codes searches the ICD-10 catalogue by code prefix or
+// description words, e.g. q=E11 or q=type 2 diabetes
+func SearchICD10Codes(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	limit := defaultCodeSearchLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxCodeSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a whole number from 1 to " + strconv.Itoa(maxCodeSearchLimit)})
+			return
+		}
+		limit = n
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": utils.CurrentICD10Catalogue().Search(query, limit)})
+}
+
+// GetDiagnoses returns a patient's problem list, active problems first.
+// Supports status to filter, e.g. status=active.
+func GetDiagnoses(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Preload("Doctor")
+	if value := c.Query("status"); value != "" {
+		status := models.DiagnosisStatus(value)
+		if !status.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Use active or resolved"})
+			return
+		}
+		query = query.Where("status = ?", status)
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+
+	var diagnoses []models.Diagnosis
+	if err := query.Where("patient_id = ?", patient.ID).Order("status, id").Find(&diagnoses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch diagnoses"})
+		return
+	}
+	if err := recordAudit(c, config.DB, models.AuditRead, []string{"diagnoses"}, patient.ID); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch diagnoses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": diagnoses})
+}
+
+// CreateDiagnosis adds a coded diagnosis to a patient's problem list, with
+// the calling doctor as the diagnosing doctor
+func CreateDiagnosis(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	var req DiagnosisRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	diagnosis := models.Diagnosis{
+		Status:      req.Status,
+		DiagnosedBy: userID,
+		UpdatedBy:   userID,
+	}
+	if diagnosis.Status == "" {
+		diagnosis.Status = models.DiagnosisActive
+	}
+	if codeDiagnosis(c, &diagnosis, req.Code, req.Description) {
+		return
+	}
+	if dateDiagnosis(c, &diagnosis, req.OnsetDate, req.ResolvedDate) {
+		return
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+	diagnosis.PatientID = patient.ID
+
+	if req.EncounterID != nil {
+		var encounter models.Encounter
+		if err := config.DB.Where("patient_id = ?", patient.ID).First(&encounter, *req.EncounterID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "encounterId is not an encounter of this patient"})
+			return
+		}
+		if encounter.Status != models.EncounterOpen {
+			c.JSON(http.StatusConflict, gin.H{"error": "Encounter is closed and can no longer be changed"})
+			return
+		}
+		diagnosis.EncounterID = &encounter.ID
+	}
+
+	err := saveDiagnosis(c, &diagnosis)
+	if errors.Is(err, errDiagnosisExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This diagnosis is already active on the problem list"})
+		return
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to record diagnosis", "patientId", patient.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record diagnosis"})
+		return
+	}
+
+	utils.Logger(c).Info("diagnosis recorded", "patientId", patient.ID, "diagnosisId", diagnosis.ID, "code", diagnosis.Code)
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    diagnosis,
+		"message": "Diagnosis recorded successfully",
+	})
+}
+
+// UpdateDiagnosis changes the fields of a diagnosis that are set in the
+// request, e.g. status=resolved to resolve a problem. What was diagnosed in
+// a closed encounter cannot change; only the course of the problem (status
+// and resolvedDate) can.
+func UpdateDiagnosis(c *gin.Context) {
+	var req DiagnosisUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var patient models.Patient
+	var diagnosis models.Diagnosis
+	if !findPatientDiagnosis(c, &patient, &diagnosis) {
+		return
+	}
+	if req.Code != "" || req.Description != "" || req.OnsetDate != "" {
+		closed, err := inClosedEncounter(&diagnosis)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update diagnosis"})
+			return
+		}
+		if closed {
+			c.JSON(http.StatusConflict, gin.H{"error": "Encounter is closed; only the status and resolvedDate of this diagnosis can be changed"})
+			return
+		}
+	}
+
+	if req.Code != "" {
+		if codeDiagnosis(c, &diagnosis, req.Code, req.Description) {
+			return
+		}
+	} else if req.Description != "" {
+		diagnosis.Description = strings.TrimSpace(req.Description)
+	}
+	if req.Status != "" {
+		diagnosis.Status = req.Status
+	}
+	diagnosis.UpdatedBy = c.GetUint("userID")
+	if dateDiagnosis(c, &diagnosis, req.OnsetDate, req.ResolvedDate) {
+		return
+	}
+
+	err := saveDiagnosis(c, &diagnosis)
+	if errors.Is(err, errDiagnosisExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This diagnosis is already active on the problem list"})
+		return
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to update diagnosis", "patientId", patient.ID, "diagnosisId", diagnosis.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update diagnosis"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    diagnosis,
+		"message": "Diagnosis updated successfully",
+	})
+}
+
+// DeleteDiagnosis removes a diagnosis recorded by mistake. Problems that no
+// longer apply should be resolved instead. Diagnoses made in a closed
+// encounter are part of its record and cannot be deleted.
+func DeleteDiagnosis(c *gin.Context) {
+	var patient models.Patient
+	var diagnosis models.Diagnosis
+	if !findPatientDiagnosis(c, &patient, &diagnosis) {
+		return
+	}
+	closed, err := inClosedEncounter(&diagnosis)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete diagnosis"})
+		return
+	}
+	if closed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Encounter is closed and can no longer be changed"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&diagnosis).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditUpdate, []string{"diagnoses"}, patient.ID)
+	})
+	if err != nil {
+		utils.Logger(c).Error("failed to delete diagnosis", "patientId", patient.ID, "diagnosisId", diagnosis.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete diagnosis"})
+		return
+	}
+
+	utils.Logger(c).Info("diagnosis deleted", "patientId", patient.ID, "diagnosisId", diagnosis.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Diagnosis deleted successfully",
+	})
+}
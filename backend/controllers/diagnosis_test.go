@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/middleware"
+	"github.com/medibridge/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// diagnosisParams are the route parameters of a diagnosis
+func diagnosisParams(patientID, diagnosisID uint) gin.Params {
+	return patientParams(patientID, gin.Param{Key: "diagnosisId", Value: fmt.Sprint(diagnosisID)})
+}
+
+func TestFreeTextDiagnosis(t *testing.T) {
+	patient := models.Patient{ID: 1, Diagnosis: " Type 2 diabetes, diet controlled  ", UpdatedBy: 2}
+
+	diagnosis := freeTextDiagnosis(&patient)
+	require.NotNil(t, diagnosis)
+	assert.Empty(t, diagnosis.Code)
+	assert.Equal(t, "Type 2 diabetes, diet controlled", diagnosis.Description)
+	assert.Equal(t, models.DiagnosisActive, diagnosis.Status)
+	assert.Equal(t, uint(2), diagnosis.DiagnosedBy)
+
+	assert.Nil(t, freeTextDiagnosis(&models.Patient{ID: 1, Diagnosis: " "}))
+}
+
+func TestCreatePatientRejectsDiagnosis(t *testing.T) {
+	doctor := models.User{ID: 1, Role: models.RoleDoctor}
+	req := PatientRequest{
+		FirstName:        "Jane",
+		LastName:         "Doe",
+		Phone:            "555-0100",
+		DateOfBirth:      "1980-01-01",
+		Gender:           "female",
+		Address:          "1 Main Street",
+		EmergencyContact: "John Doe",
+		EmergencyPhone:   "555-0101",
+		Diagnosis:        "Hypertension",
+	}
+
+	c, w := newTestContext(http.MethodPost, "/patients", req, &doctor, nil)
+	CreatePatient(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "/patients/:id/diagnoses")
+}
+
+func TestConvertFreeTextDiagnoses(t *testing.T) {
+	setupTestDB(t)
+
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, doctor.ID, time.Date(1949, 11, 2, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, config.DB.Model(&patient).UpdateColumn("diagnosis", "Atrial fibrillation").Error)
+	// An uncoded entry does not block a second one
+	uncoded := models.Diagnosis{PatientID: patient.ID, Description: "COPD", Status: models.DiagnosisActive, DiagnosedBy: doctor.ID, UpdatedBy: doctor.ID}
+	require.NoError(t, config.DB.Create(&uncoded).Error)
+
+	count, err := ConvertFreeTextDiagnoses(config.DB)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	var diagnosis models.Diagnosis
+	require.NoError(t, config.DB.Where("patient_id = ? AND description = ?", patient.ID, "Atrial fibrillation").First(&diagnosis).Error)
+	assert.Empty(t, diagnosis.Code)
+	assert.Equal(t, models.DiagnosisActive, diagnosis.Status)
+
+	require.NoError(t, config.DB.First(&patient, patient.ID).Error)
+	assert.Empty(t, patient.Diagnosis)
+
+	// A doctor codes the converted entry later
+	assignTestCareTeam(t, &patient, &doctor)
+	req := DiagnosisUpdateRequest{Code: "I48.91"}
+	c, w := newTestContext(http.MethodPut, "/patients/1/diagnoses/1", req, &doctor, diagnosisParams(patient.ID, diagnosis.ID))
+	UpdateDiagnosis(c)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, config.DB.First(&diagnosis, diagnosis.ID).Error)
+	assert.Equal(t, "I48.91", diagnosis.Code)
+}
+
+func TestCreateDiagnosisOncePerActiveCode(t *testing.T) {
+	setupTestDB(t)
+
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, doctor.ID, time.Date(1958, 1, 30, 0, 0, 0, 0, time.UTC))
+	assignTestCareTeam(t, &patient, &doctor)
+
+	create := func(req DiagnosisRequest) int {
+		c, w := newTestContext(http.MethodPost, "/patients/1/diagnoses", req, &doctor, patientParams(patient.ID))
+		CreateDiagnosis(c)
+		return w.Code
+	}
+	require.Equal(t, http.StatusCreated, create(DiagnosisRequest{Code: "I10"}))
+	assert.Equal(t, http.StatusConflict, create(DiagnosisRequest{Code: "I10"}), "the code is already active")
+	assert.Equal(t, http.StatusCreated, create(DiagnosisRequest{Code: "I10", Status: models.DiagnosisResolved}), "resolved entries may repeat")
+
+	var active models.Diagnosis
+	require.NoError(t, config.DB.Where("patient_id = ? AND code = ? AND status = ?", patient.ID, "I10", models.DiagnosisActive).First(&active).Error)
+	c, w := newTestContext(http.MethodPut, "/patients/1/diagnoses/1", DiagnosisUpdateRequest{Status: models.DiagnosisResolved}, &doctor, diagnosisParams(patient.ID, active.ID))
+	UpdateDiagnosis(c)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusCreated, create(DiagnosisRequest{Code: "I10"}), "a resolved problem can become active again")
+
+	// Reactivating the resolved entry would make the code active twice
+	c, w = newTestContext(http.MethodPut, "/patients/1/diagnoses/1", DiagnosisUpdateRequest{Status: models.DiagnosisActive}, &doctor, diagnosisParams(patient.ID, active.ID))
+	UpdateDiagnosis(c)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestDiagnosisFrozenInClosedEncounter(t *testing.T) {
+	setupTestDB(t)
+
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, doctor.ID, time.Date(1986, 10, 5, 0, 0, 0, 0, time.UTC))
+	assignTestCareTeam(t, &patient, &doctor)
+	encounter := openTestEncounter(t, &doctor, &patient)
+	inEncounter := models.Diagnosis{PatientID: patient.ID, EncounterID: &encounter.ID, Code: "E11.9", Description: "Type 2 diabetes mellitus without complications", Status: models.DiagnosisActive, DiagnosedBy: doctor.ID, UpdatedBy: doctor.ID}
+	require.NoError(t, config.DB.Create(&inEncounter).Error)
+	unlinked := models.Diagnosis{PatientID: patient.ID, Code: "I10", Description: "Essential (primary) hypertension", Status: models.DiagnosisActive, DiagnosedBy: doctor.ID, UpdatedBy: doctor.ID}
+	require.NoError(t, config.DB.Create(&unlinked).Error)
+	require.NoError(t, config.DB.Model(&encounter).Update("status", models.EncounterClosed).Error)
+
+	c, w := newTestContext(http.MethodPost, "/patients/1/diagnoses", DiagnosisRequest{Code: "I48.91", EncounterID: &encounter.ID}, &doctor, patientParams(patient.ID))
+	CreateDiagnosis(c)
+	assert.Equal(t, http.StatusConflict, w.Code, "no new diagnoses in a closed encounter")
+
+	tests := []struct {
+		name       string
+		diagnosis  models.Diagnosis
+		req        DiagnosisUpdateRequest
+		wantStatus int
+	}{
+		{name: "recode in closed encounter", diagnosis: inEncounter, req: DiagnosisUpdateRequest{Code: "E11.65"}, wantStatus: http.StatusConflict},
+		{name: "change onset in closed encounter", diagnosis: inEncounter, req: DiagnosisUpdateRequest{OnsetDate: "2020-01-01"}, wantStatus: http.StatusConflict},
+		{name: "change description outside encounters", diagnosis: unlinked, req: DiagnosisUpdateRequest{Description: "Hypertension, well controlled"}, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newTestContext(http.MethodPut, "/patients/1/diagnoses/1", tt.req, &doctor, diagnosisParams(patient.ID, tt.diagnosis.ID))
+			UpdateDiagnosis(c)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	c, w = newTestContext(http.MethodDelete, "/patients/1/diagnoses/1", nil, &doctor, diagnosisParams(patient.ID, unlinked.ID))
+	DeleteDiagnosis(c)
+	assert.Equal(t, http.StatusOK, w.Code, "diagnoses outside encounters can be deleted")
+}
+
+func TestDiagnosisWritesNeedClinicalPermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handlers := map[string]gin.HandlerFunc{
+		http.MethodPost:   CreateDiagnosis,
+		http.MethodPut:    UpdateDiagnosis,
+		http.MethodDelete: DeleteDiagnosis,
+	}
+	for _, role := range []models.UserRole{models.RoleReceptionist, models.RoleAdmin} {
+		for method, handler := range handlers {
+			t.Run(string(role)+" "+method, func(t *testing.T) {
+				r := gin.New()
+				r.Handle(method, "/patients/:id/diagnoses", func(c *gin.Context) {
+					c.Set("userRole", role)
+					c.Next()
+				}, middleware.RequirePermission(models.PermPatientWriteClinical), handler)
+
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(method, "/patients/1/diagnoses", nil))
+				assert.Equal(t, http.StatusForbidden, w.Code)
+			})
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultBreakGlassTTL = time.Hour
+	// minBreakGlassReasonLength keeps justifications from being a single word
+	minBreakGlassReasonLength = 20
+)
+
+type BreakGlassRequest struct {
+	Reason string `json:"reason" binding:"required" redact:"phi"`
+}
+
+type AcknowledgeEmergencyAccessRequest struct {
+	Note string `json:"note"`
+}
+
+// breakGlassTTL is how long a break-glass grant lasts, configured with
+// BREAK_GLASS_TTL (default 1h)
+func breakGlassTTL() time.Duration {
+	return utils.DurationFromEnv("BREAK_GLASS_TTL", defaultBreakGlassTTL)
+}
+
+// activeEmergencyAccess returns the current user's newest unexpired
+// break-glass grant for a patient, or nil when there is none
+func activeEmergencyAccess(c *gin.Context, patientID uint64) (*models.EmergencyAccess, error) {
+	var grant models.EmergencyAccess
+	err := config.DB.
+		Where("patient_id = ? AND user_id = ? AND expires_at > ?", patientID, c.GetUint("userID"), time.Now()).
+		Order("expires_at DESC").
+		First(&grant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// emergencyAction names the kind of access a request makes
+func emergencyAction(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return "read"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return "write"
+	}
+}
+
+// useEmergencyAccess marks the request as made under a break-glass grant and
+// records it against the grant for review
+func useEmergencyAccess(c *gin.Context, grant *models.EmergencyAccess) error {
+	c.Set("emergencyAccessID", grant.ID)
+
+	action := models.EmergencyAccessAction{
+		EmergencyAccessID: grant.ID,
+		Action:            emergencyAction(c.Request.Method),
+		Route:             c.Request.Method + " " + c.FullPath(),
+		RequestID:         c.GetString("requestID"),
+	}
+	if err := config.DB.Create(&action).Error; err != nil {
+		return err
+	}
+
+	utils.Logger(c).Warn("emergency access used", "emergencyAccessId", grant.ID, "patientId", grant.PatientID, "action", action.Action)
+	return nil
+}
+
+// BreakGlass grants the current user time-boxed access to a patient outside
+// their care teams. A written justification is mandatory and the grant is
+// queued for admin review.
+func BreakGlass(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	var req BreakGlassRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason for emergency access is required"})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len(req.Reason) < minBreakGlassReasonLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reason must be at least " + strconv.Itoa(minBreakGlassReasonLength) + " characters long"})
+		return
+	}
+
+	var patient models.Patient
+	if err := config.DB.First(&patient, patientID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+		return
+	}
+
+	var count int64
+	if err := scopePatients(c, config.DB.Model(&models.Patient{})).Where("patients.id = ?", patient.ID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant emergency access"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You already have access to this patient"})
+		return
+	}
+
+	grant := models.EmergencyAccess{
+		PatientID: patient.ID,
+		UserID:    c.GetUint("userID"),
+		Reason:    req.Reason,
+		ExpiresAt: time.Now().Add(breakGlassTTL()),
+	}
+	if err := config.DB.Create(&grant).Error; err != nil {
+		utils.Logger(c).Error("failed to grant emergency access", "patientId", patient.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant emergency access"})
+		return
+	}
+
+	fields := readablePatientFields(c)
+	data, err := projectPatient(&patient, fields)
+	if err == nil {
+		err = useEmergencyAccess(c, &grant)
+	}
+	if err == nil {
+		err = recordAudit(c, config.DB, models.AuditRead, fields, patient.ID)
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to record emergency access", "emergencyAccessId", grant.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant emergency access"})
+		return
+	}
+
+	c.Header("ETag", utils.ETag(patient.Version))
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data": gin.H{
+			"grant":   grant,
+			"patient": data,
+		},
+		"message": "Emergency access granted. This access is recorded and will be reviewed.",
+	})
+}
+
+// GetEmergencyAccessReviews lists break-glass grants for review. Supports
+// status (pending/acknowledged/all, default pending), page and limit.
+func GetEmergencyAccessReviews(c *gin.Context) {
+	page, limit, responded := parsePage(c, 20, 100)
+	if responded {
+		return
+	}
+
+	query := config.DB.Model(&models.EmergencyAccess{})
+	switch c.DefaultQuery("status", "pending") {
+	case "pending":
+		query = query.Where("acknowledged_at IS NULL")
+	case "acknowledged":
+		query = query.Where("acknowledged_at IS NOT NULL")
+	case "all":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Use pending, acknowledged or all"})
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count emergency access events"})
+		return
+	}
+
+	var grants []models.EmergencyAccess
+	if err := query.Preload("User").Preload("Actions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Order("id").Offset((page - 1) * limit).Limit(limit).Find(&grants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch emergency access events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": grants,
+		"pagination": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// AcknowledgeEmergencyAccess marks a break-glass grant as reviewed
+func AcknowledgeEmergencyAccess(c *gin.Context) {
+	grantID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid emergency access ID"})
+		return
+	}
+
+	var req AcknowledgeEmergencyAccessRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	var grant models.EmergencyAccess
+	if err := config.DB.First(&grant, grantID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Emergency access event not found"})
+		return
+	}
+
+	reviewerID := c.GetUint("userID")
+	if grant.UserID == reviewerID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot review your own emergency access"})
+		return
+	}
+
+	now := time.Now()
+	result := config.DB.Model(&models.EmergencyAccess{}).
+		Where("id = ? AND acknowledged_at IS NULL", grant.ID).
+		Updates(map[string]interface{}{
+			"acknowledged_at": &now,
+			"acknowledged_by": reviewerID,
+			"review_note":     req.Note,
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to acknowledge emergency access"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Emergency access event was already acknowledged"})
+		return
+	}
+
+	utils.Logger(c).Info("emergency access acknowledged", "emergencyAccessId", grant.ID)
+	grant.AcknowledgedAt = &now
+	grant.AcknowledgedBy = &reviewerID
+	grant.ReviewNote = req.Note
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    grant,
+		"message": "Emergency access acknowledged",
+	})
+}
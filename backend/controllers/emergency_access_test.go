@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakGlass(t *testing.T) {
+	setupTestDB(t)
+
+	receptionist := createTestUser(t, models.RoleReceptionist)
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, receptionist.ID, time.Date(1962, 8, 9, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name       string
+		reason     string
+		wantStatus int
+	}{
+		{name: "reason too short", reason: "urgent", wantStatus: http.StatusBadRequest},
+		{name: "justified", reason: "Unconscious patient in the emergency department", wantStatus: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newTestContext(http.MethodPost, "/patients/1/break-glass", BreakGlassRequest{Reason: tt.reason}, &doctor, patientParams(patient.ID))
+			BreakGlass(c)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	var grant models.EmergencyAccess
+	require.NoError(t, config.DB.Where("patient_id = ? AND user_id = ?", patient.ID, doctor.ID).First(&grant).Error)
+	assert.True(t, grant.ExpiresAt.After(time.Now()))
+
+	// The grant's own view of the record is its first recorded action
+	var count int64
+	config.DB.Model(&models.EmergencyAccessAction{}).Where("emergency_access_id = ?", grant.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestEmergencyAccessRecordedOnEveryUse(t *testing.T) {
+	setupTestDB(t)
+
+	receptionist := createTestUser(t, models.RoleReceptionist)
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, receptionist.ID, time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	grant := models.EmergencyAccess{
+		PatientID: patient.ID,
+		UserID:    doctor.ID,
+		Reason:    "Patient arrived unresponsive by ambulance",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	require.NoError(t, config.DB.Create(&grant).Error)
+
+	for i := 0; i < 3; i++ {
+		c, w := newTestContext(http.MethodGet, "/patients/1", nil, &doctor, patientParams(patient.ID))
+		GetPatient(c)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	var actions []models.EmergencyAccessAction
+	require.NoError(t, config.DB.Where("emergency_access_id = ?", grant.ID).Find(&actions).Error)
+	assert.Len(t, actions, 3)
+	for _, action := range actions {
+		assert.Equal(t, "read", action.Action)
+	}
+
+	// The access is also marked as emergency access in the audit log
+	var event models.AuditEvent
+	require.NoError(t, config.DB.Where("patient_id = ? AND actor_id = ?", patient.ID, doctor.ID).Order("id DESC").First(&event).Error)
+	require.NotNil(t, event.EmergencyAccessID)
+	assert.Equal(t, grant.ID, *event.EmergencyAccessID)
+}
+
+func TestExpiredEmergencyAccessRefused(t *testing.T) {
+	setupTestDB(t)
+
+	receptionist := createTestUser(t, models.RoleReceptionist)
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, receptionist.ID, time.Date(1955, 11, 30, 0, 0, 0, 0, time.UTC))
+
+	grant := models.EmergencyAccess{
+		PatientID: patient.ID,
+		UserID:    doctor.ID,
+		Reason:    "Patient arrived unresponsive by ambulance",
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}
+	require.NoError(t, config.DB.Create(&grant).Error)
+
+	c, w := newTestContext(http.MethodGet, "/patients/1", nil, &doctor, patientParams(patient.ID))
+	GetPatient(c)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var count int64
+	config.DB.Model(&models.EmergencyAccessAction{}).Where("emergency_access_id = ?", grant.ID).Count(&count)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,521 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"gorm.io/gorm"
+)
+
+// EncounterVitalsRequest are vital signs taken during an encounter. They
+// are in the canonical unit of their type unless Units names another one,
+// for example {"temperature": "°F", "weight": "lb"}.
+type EncounterVitalsRequest struct {
+	SystolicBP  *int                        `json:"systolicBp"`
+	DiastolicBP *int                        `json:"diastolicBp"`
+	Pulse       *int                        `json:"pulse"`
+	Temperature *float64                    `json:"temperature"`
+	SpO2        *int                        `json:"spo2"`
+	HeightCm    *float64                    `json:"heightCm"`
+	WeightKg    *float64                    `json:"weightKg"`
+	Units       map[models.VitalType]string `json:"units"`
+}
+
+type OpenEncounterRequest struct {
+	Type   models.EncounterType `json:"type" binding:"required"`
+	Reason string               `json:"reason" binding:"required,max=500" redact:"phi"`
+	// StartedAt defaults to now, for encounters recorded after the fact
+	StartedAt string `json:"startedAt"`
+	// Notes is only bound to reject it; see rejectEncounterNotes
+	Notes  string                  `json:"notes" redact:"phi"`
+	Vitals *EncounterVitalsRequest `json:"vitals"`
+}
+
+// UpdateEncounterRequest changes the fields that are set and leaves the
+// rest. Vital signs are merged one by one.
+type UpdateEncounterRequest struct {
+	Type   models.EncounterType `json:"type"`
+	Reason string               `json:"reason" binding:"max=500" redact:"phi"`
+	// Notes is only bound to reject it; see rejectEncounterNotes
+	Notes  string                  `json:"notes" redact:"phi"`
+	Vitals *EncounterVitalsRequest `json:"vitals"`
+}
+
+type CloseEncounterRequest struct {
+	// EndedAt defaults to now
+	EndedAt string `json:"endedAt"`
+	// Notes is only bound to reject it; see rejectEncounterNotes
+	Notes string `json:"notes" redact:"phi"`
+}
+
+// rejectEncounterNotes answers 400 when the retired free-text notes of an
+// encounter are written. It reports whether it responded.
+func rejectEncounterNotes(c *gin.Context, notes string) bool {
+	if notes == "" {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "The notes field is no longer written. Record notes with POST /patients/:id/notes and the encounterId"})
+	return true
+}
+
+var (
+	errEncounterClosed = errors.New("encounter is closed")
+	errEncounterOpen   = errors.New("encounter already open")
+)
+
+// ownsEncounter reports whether the user is the doctor of an encounter, who
+// alone may change it, and responds with 403 otherwise
+func ownsEncounter(c *gin.Context, encounter *models.Encounter) bool {
+	if encounter.DoctorID != c.GetUint("userID") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the doctor of this encounter can change it"})
+		return false
+	}
+	return true
+}
+
+// vitalObservations converts the vital signs given in a request to
+// observations in the canonical units. It responds with 400 and reports
+// false when a value or unit is not accepted.
+func vitalObservations(c *gin.Context, req *EncounterVitalsRequest) ([]models.VitalSign, bool) {
+	if req == nil {
+		return nil, true
+	}
+	given := models.EncounterVitals{
+		SystolicBP:  req.SystolicBP,
+		DiastolicBP: req.DiastolicBP,
+		Pulse:       req.Pulse,
+		Temperature: req.Temperature,
+		SpO2:        req.SpO2,
+		HeightCm:    req.HeightCm,
+		WeightKg:    req.WeightKg,
+	}
+	observations := given.Observations()
+	for t, unit := range req.Units {
+		if !t.Valid() || t == models.VitalBMI {
+			respondVitalError(c, t, unit, models.ErrVitalUnknownType)
+			return nil, false
+		}
+	}
+	for i := range observations {
+		t := observations[i].Type
+		value, err := models.ConvertVital(t, observations[i].Value, req.Units[t])
+		if err != nil {
+			respondVitalError(c, t, req.Units[t], err)
+			return nil, false
+		}
+		observations[i].Value = value
+	}
+	return observations, true
+}
+
+// parseEncounterTime parses an encounter start or end time, which cannot be
+// in the future
+func parseEncounterTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if t.After(time.Now().Add(time.Minute)) {
+		return time.Time{}, errors.New("time is in the future")
+	}
+	return t, nil
+}
+
+// attachEncounterDiagnoses loads the diagnoses made in each encounter
+func attachEncounterDiagnoses(encounters []models.Encounter) error {
+	if len(encounters) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(encounters))
+	for i := range encounters {
+		ids[i] = encounters[i].ID
+	}
+	var diagnoses []models.Diagnosis
+	if err := config.DB.Where("encounter_id IN ?", ids).Order("id").Find(&diagnoses).Error; err != nil {
+		return err
+	}
+	byEncounter := make(map[uint][]models.Diagnosis)
+	for _, diagnosis := range diagnoses {
+		byEncounter[*diagnosis.EncounterID] = append(byEncounter[*diagnosis.EncounterID], diagnosis)
+	}
+	for i := range encounters {
+		encounters[i].Diagnoses = byEncounter[encounters[i].ID]
+	}
+	return nil
+}
+
+// attachEncounterVitals fills the vitals of each encounter with the latest
+// observation of each vital sign taken during it
+func attachEncounterVitals(encounters []models.Encounter) error {
+	if len(encounters) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(encounters))
+	byID := make(map[uint]*models.Encounter, len(encounters))
+	for i := range encounters {
+		ids[i] = encounters[i].ID
+		byID[encounters[i].ID] = &encounters[i]
+		encounters[i].Vitals = models.EncounterVitals{}
+	}
+	var observations []models.VitalSign
+	if err := config.DB.Where("encounter_id IN ?", ids).Order("taken_at, id").Find(&observations).Error; err != nil {
+		return err
+	}
+	// Oldest first, so later observations replace earlier ones
+	for _, observation := range observations {
+		byID[*observation.EncounterID].Vitals.Apply(observation)
+	}
+	return nil
+}
+
+// findPatientEncounter loads one encounter of a patient the user may
+// access, with its vitals. It reports whether it found it, and responds
+// otherwise.
+func findPatientEncounter(c *gin.Context, patient *models.Patient, encounter *models.Encounter) bool {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return false
+	}
+	encounterID, err := strconv.ParseUint(c.Param("encounterId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid encounter ID"})
+		return false
+	}
+	if !findScopedPatient(c, patientID, patient) {
+		return false
+	}
+	if err := config.DB.Preload("Doctor").Where("patient_id = ?", patient.ID).First(encounter, encounterID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Encounter not found"})
+		return false
+	}
+	encounters := []models.Encounter{*encounter}
+	if err := attachEncounterVitals(encounters); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch encounter"})
+		return false
+	}
+	*encounter = encounters[0]
+	return true
+}
+
+// saveOpenEncounter writes changes to an encounter if it is still open,
+// saves the vital sign observations taken and records the audit entry
+func saveOpenEncounter(c *gin.Context, encounter *models.Encounter, vitals *vitalsRecord) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Encounter{}).
+			Where("id = ? AND status = ?", encounter.ID, models.EncounterOpen).
+			Select("*").Omit("id", "patient_id", "doctor_id", "created_at", "Doctor").
+			Updates(encounter)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errEncounterClosed
+		}
+		if vitals != nil {
+			if err := vitals.save(c, tx); err != nil {
+				return err
+			}
+		}
+		return recordAudit(c, tx, models.AuditUpdate, []string{"encounters"}, encounter.PatientID)
+	})
+}
+
+// GetEncounters returns a patient's visit timeline, newest first, with the
+// diagnoses made in each visit. Supports from and to (YYYY-MM-DD or RFC
+// 3339) on the start time, type and status.
+func GetEncounters(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Preload("Doctor")
+	for _, param := range []string{"from", "to"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := parseTimeFilter(value, param == "to")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ". Use YYYY-MM-DD or RFC 3339"})
+			return
+		}
+		if param == "from" {
+			query = query.Where("started_at >= ?", t)
+		} else {
+			query = query.Where("started_at <= ?", t)
+		}
+	}
+	if value := c.Query("type"); value != "" {
+		if !models.EncounterType(value).Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type. Use outpatient, inpatient, emergency or telehealth"})
+			return
+		}
+		query = query.Where("type = ?", value)
+	}
+	if value := c.Query("status"); value != "" {
+		if !models.EncounterStatus(value).Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Use open or closed"})
+			return
+		}
+		query = query.Where("status = ?", value)
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+
+	var encounters []models.Encounter
+	if err := query.Where("patient_id = ?", patient.ID).Order("started_at DESC, id DESC").Find(&encounters).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch encounters"})
+		return
+	}
+	if err := attachEncounterVitals(encounters); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch encounters"})
+		return
+	}
+	if err := attachEncounterDiagnoses(encounters); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch encounters"})
+		return
+	}
+	if err := recordAudit(c, config.DB, models.AuditRead, []string{"encounters"}, patient.ID); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch encounters"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": encounters})
+}
+
+// GetEncounter returns one encounter with its diagnoses
+func GetEncounter(c *gin.Context) {
+	var patient models.Patient
+	var encounter models.Encounter
+	if !findPatientEncounter(c, &patient, &encounter) {
+		return
+	}
+
+	encounters := []models.Encounter{encounter}
+	if err := attachEncounterDiagnoses(encounters); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch encounter"})
+		return
+	}
+	if err := recordAudit(c, config.DB, models.AuditRead, []string{"encounters"}, patient.ID); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch encounter"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": encounters[0]})
+}
+
+// OpenEncounter starts a visit of a patient with the calling doctor. A
+// doctor can only have one open encounter per patient.
+func OpenEncounter(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	var req OpenEncounterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if rejectEncounterNotes(c, req.Notes) {
+		return
+	}
+	if !req.Type.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type. Use outpatient, inpatient, emergency or telehealth"})
+		return
+	}
+
+	encounter := models.Encounter{
+		DoctorID:  c.GetUint("userID"),
+		Type:      req.Type,
+		Reason:    strings.TrimSpace(req.Reason),
+		Status:    models.EncounterOpen,
+		StartedAt: time.Now(),
+	}
+	if req.StartedAt != "" {
+		startedAt, err := parseEncounterTime(req.StartedAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid startedAt. Use a past RFC 3339 time"})
+			return
+		}
+		encounter.StartedAt = startedAt
+	}
+	observations, ok := vitalObservations(c, req.Vitals)
+	if !ok {
+		return
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+	encounter.PatientID = patient.ID
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&encounter).Error; err != nil {
+			if isUniqueViolation(err, config.EncounterOpenIndex) {
+				return errEncounterOpen
+			}
+			return err
+		}
+		vitals := vitalsRecord{
+			patient:      &patient,
+			encounterID:  &encounter.ID,
+			takenAt:      encounter.StartedAt,
+			observations: observations,
+		}
+		if err := vitals.save(c, tx); err != nil {
+			return err
+		}
+		for _, observation := range vitals.observations {
+			encounter.Vitals.Apply(observation)
+		}
+		return recordAudit(c, tx, models.AuditCreate, []string{"encounters"}, patient.ID)
+	})
+	if errors.Is(err, errEncounterOpen) {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have an open encounter with this patient. Close it first."})
+		return
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to open encounter", "patientId", patient.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open encounter"})
+		return
+	}
+
+	utils.Logger(c).Info("encounter opened", "patientId", patient.ID, "encounterId", encounter.ID)
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    encounter,
+		"message": "Encounter opened successfully",
+	})
+}
+
+// UpdateEncounter changes an open encounter of the calling doctor. Closed
+// encounters answer 409.
+func UpdateEncounter(c *gin.Context) {
+	var req UpdateEncounterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if rejectEncounterNotes(c, req.Notes) {
+		return
+	}
+	if req.Type != "" && !req.Type.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type. Use outpatient, inpatient, emergency or telehealth"})
+		return
+	}
+	observations, ok := vitalObservations(c, req.Vitals)
+	if !ok {
+		return
+	}
+
+	var patient models.Patient
+	var encounter models.Encounter
+	if !findPatientEncounter(c, &patient, &encounter) || !ownsEncounter(c, &encounter) {
+		return
+	}
+
+	if req.Type != "" {
+		encounter.Type = req.Type
+	}
+	if req.Reason != "" {
+		encounter.Reason = strings.TrimSpace(req.Reason)
+	}
+
+	vitals := vitalsRecord{
+		patient:      &patient,
+		encounterID:  &encounter.ID,
+		takenAt:      time.Now(),
+		observations: observations,
+	}
+	err := saveOpenEncounter(c, &encounter, &vitals)
+	if errors.Is(err, errEncounterClosed) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Encounter is closed and can no longer be changed"})
+		return
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to update encounter", "patientId", patient.ID, "encounterId", encounter.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update encounter"})
+		return
+	}
+	for _, observation := range vitals.observations {
+		encounter.Vitals.Apply(observation)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    encounter,
+		"message": "Encounter updated successfully",
+	})
+}
+
+// CloseEncounter ends an open encounter of the calling doctor. The body is
+// optional: endedAt defaults to now.
+func CloseEncounter(c *gin.Context) {
+	var req CloseEncounterRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if rejectEncounterNotes(c, req.Notes) {
+		return
+	}
+
+	var patient models.Patient
+	var encounter models.Encounter
+	if !findPatientEncounter(c, &patient, &encounter) || !ownsEncounter(c, &encounter) {
+		return
+	}
+
+	endedAt := time.Now()
+	if req.EndedAt != "" {
+		t, err := parseEncounterTime(req.EndedAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid endedAt. Use a past RFC 3339 time"})
+			return
+		}
+		endedAt = t
+	}
+	if endedAt.Before(encounter.StartedAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "endedAt cannot be before the encounter started"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	encounter.Status = models.EncounterClosed
+	encounter.EndedAt = &endedAt
+	encounter.ClosedBy = &userID
+
+	err := saveOpenEncounter(c, &encounter, nil)
+	if errors.Is(err, errEncounterClosed) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Encounter is already closed"})
+		return
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to close encounter", "patientId", patient.ID, "encounterId", encounter.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close encounter"})
+		return
+	}
+
+	utils.Logger(c).Info("encounter closed", "patientId", patient.ID, "encounterId", encounter.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    encounter,
+		"message": "Encounter closed successfully",
+	})
+}
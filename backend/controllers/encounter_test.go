@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encounterParams are the route parameters of an encounter
+func encounterParams(patientID, encounterID uint) gin.Params {
+	return patientParams(patientID, gin.Param{Key: "encounterId", Value: fmt.Sprint(encounterID)})
+}
+
+// openTestEncounter opens an encounter of doctor with patient
+func openTestEncounter(t *testing.T, doctor *models.User, patient *models.Patient) models.Encounter {
+	t.Helper()
+	encounter := models.Encounter{PatientID: patient.ID, DoctorID: doctor.ID, Type: models.EncounterOutpatient, Reason: "Follow-up", Status: models.EncounterOpen, StartedAt: time.Now().Add(-time.Hour)}
+	require.NoError(t, config.DB.Create(&encounter).Error)
+	return encounter
+}
+
+func TestOpenEncounterOncePerDoctor(t *testing.T) {
+	setupTestDB(t)
+
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, doctor.ID, time.Date(1979, 10, 2, 0, 0, 0, 0, time.UTC))
+	assignTestCareTeam(t, &patient, &doctor)
+
+	req := OpenEncounterRequest{Type: models.EncounterOutpatient, Reason: "Chest pain"}
+	c, w := newTestContext(http.MethodPost, "/patients/1/encounters", req, &doctor, patientParams(patient.ID))
+	OpenEncounter(c)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	c, w = newTestContext(http.MethodPost, "/patients/1/encounters", req, &doctor, patientParams(patient.ID))
+	OpenEncounter(c)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// The index also refuses a second open encounter written directly
+	duplicate := models.Encounter{PatientID: patient.ID, DoctorID: doctor.ID, Type: models.EncounterOutpatient, Reason: "Chest pain", Status: models.EncounterOpen, StartedAt: time.Now()}
+	err := config.DB.Create(&duplicate).Error
+	assert.True(t, isUniqueViolation(err, config.EncounterOpenIndex), "got %v", err)
+}
+
+func TestEncounterChangedByItsDoctorOnly(t *testing.T) {
+	setupTestDB(t)
+
+	doctor := createTestUser(t, models.RoleDoctor)
+	colleague := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, doctor.ID, time.Date(1966, 1, 15, 0, 0, 0, 0, time.UTC))
+	assignTestCareTeam(t, &patient, &doctor)
+	assignTestCareTeam(t, &patient, &colleague)
+	encounter := openTestEncounter(t, &doctor, &patient)
+
+	update := UpdateEncounterRequest{Reason: "Reviewed results"}
+	tests := []struct {
+		name       string
+		user       models.User
+		handler    gin.HandlerFunc
+		body       interface{}
+		wantStatus int
+	}{
+		{name: "other doctor updates", user: colleague, handler: UpdateEncounter, body: update, wantStatus: http.StatusForbidden},
+		{name: "other doctor closes", user: colleague, handler: CloseEncounter, wantStatus: http.StatusForbidden},
+		{name: "own doctor updates", user: doctor, handler: UpdateEncounter, body: update, wantStatus: http.StatusOK},
+		{name: "own doctor closes", user: doctor, handler: CloseEncounter, wantStatus: http.StatusOK},
+		{name: "update after close", user: doctor, handler: UpdateEncounter, body: update, wantStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newTestContext(http.MethodPut, "/patients/1/encounters/1", tt.body, &tt.user, encounterParams(patient.ID, encounter.ID))
+			tt.handler(c)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	require.NoError(t, config.DB.First(&encounter, encounter.ID).Error)
+	assert.Equal(t, models.EncounterClosed, encounter.Status)
+	require.NotNil(t, encounter.ClosedBy)
+	assert.Equal(t, doctor.ID, *encounter.ClosedBy)
+}
+
+func TestDiagnosisOfClosedEncounter(t *testing.T) {
+	setupTestDB(t)
+
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, doctor.ID, time.Date(1992, 12, 1, 0, 0, 0, 0, time.UTC))
+	assignTestCareTeam(t, &patient, &doctor)
+	encounter := openTestEncounter(t, &doctor, &patient)
+	diagnosis := models.Diagnosis{PatientID: patient.ID, EncounterID: &encounter.ID, Code: "J06.9", Description: "Upper respiratory infection", Status: models.DiagnosisActive, DiagnosedBy: doctor.ID, UpdatedBy: doctor.ID}
+	require.NoError(t, config.DB.Create(&diagnosis).Error)
+	require.NoError(t, config.DB.Model(&encounter).Update("status", models.EncounterClosed).Error)
+
+	params := patientParams(patient.ID, gin.Param{Key: "diagnosisId", Value: fmt.Sprint(diagnosis.ID)})
+	tests := []struct {
+		name       string
+		req        DiagnosisUpdateRequest
+		wantStatus int
+	}{
+		{name: "change what was diagnosed", req: DiagnosisUpdateRequest{Description: "Sinusitis"}, wantStatus: http.StatusConflict},
+		{name: "resolve", req: DiagnosisUpdateRequest{Status: models.DiagnosisResolved}, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newTestContext(http.MethodPut, "/patients/1/diagnoses/1", tt.req, &doctor, params)
+			UpdateDiagnosis(c)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	c, w := newTestContext(http.MethodDelete, "/patients/1/diagnoses/1", nil, &doctor, params)
+	DeleteDiagnosis(c)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
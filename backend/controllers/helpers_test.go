@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestDB connects to the database configured by the DB_* variables and
+// migrates the schema. Tests that need it are skipped without a database.
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	if os.Getenv("DB_HOST") == "" {
+		t.Skip("DB_HOST is not set")
+	}
+	gin.SetMode(gin.TestMode)
+	config.InitDB()
+	require.NoError(t, config.DB.AutoMigrate(&models.User{}, &models.Patient{}, &models.Session{}, &models.RefreshToken{}, &models.CareTeamMember{}, &models.EmergencyAccess{}, &models.EmergencyAccessAction{}, &models.AuditEvent{}, &models.PatientRevision{}, &models.PatientIdentifier{}, &models.PatientMerge{}, &models.Allergy{}, &models.Diagnosis{}, &models.Encounter{}, &models.ClinicalNote{}, &models.NoteAddendum{}, &models.VitalSign{}))
+	require.NoError(t, config.ProtectSignedNotes(config.DB))
+	require.NoError(t, config.EnforceClinicalUniqueness(config.DB))
+}
+
+// createTestUser stores a user with a unique email
+func createTestUser(t *testing.T, role models.UserRole) models.User {
+	t.Helper()
+	user := models.User{
+		Name:         "Test " + string(role),
+		Email:        fmt.Sprintf("%s-%d@example.com", role, time.Now().UnixNano()),
+		PasswordHash: "-",
+		Role:         role,
+	}
+	require.NoError(t, config.DB.Create(&user).Error)
+	return user
+}
+
+// createTestPatient stores a patient born on dob
+func createTestPatient(t *testing.T, createdBy uint, dob time.Time) models.Patient {
+	t.Helper()
+	patient := models.Patient{
+		FirstName:        "Jane",
+		LastName:         "Doe",
+		Phone:            "555-0100",
+		DateOfBirth:      dob,
+		Gender:           "female",
+		Address:          "1 Main Street",
+		EmergencyContact: "John Doe",
+		EmergencyPhone:   "555-0101",
+		CreatedBy:        createdBy,
+		UpdatedBy:        createdBy,
+		Version:          1,
+	}
+	require.NoError(t, config.DB.Create(&patient).Error)
+	return patient
+}
+
+// assignTestCareTeam puts a user on a patient's care team
+func assignTestCareTeam(t *testing.T, patient *models.Patient, user *models.User) {
+	t.Helper()
+	member := models.CareTeamMember{PatientID: patient.ID, UserID: user.ID, Role: models.CareTeamConsulting, AssignedBy: user.ID}
+	require.NoError(t, config.DB.Create(&member).Error)
+}
+
+// newTestContext builds the context of a request made by user, as the auth
+// middleware leaves it. body is sent as JSON unless nil.
+func newTestContext(method, target string, body interface{}, user *models.User, params gin.Params) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	var reader io.Reader
+	if body != nil {
+		payload, _ := json.Marshal(body)
+		reader = bytes.NewReader(payload)
+	}
+	c.Request = httptest.NewRequest(method, target, reader)
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = params
+	c.Set("userID", user.ID)
+	c.Set("userRole", user.Role)
+	c.Set("requestID", fmt.Sprintf("test-%d", time.Now().UnixNano()))
+	return c, w
+}
+
+// patientParams are the route parameters of a patient route
+func patientParams(patientID uint, extra ...gin.Param) gin.Params {
+	return append(gin.Params{{Key: "id", Value: fmt.Sprint(patientID)}}, extra...)
+}
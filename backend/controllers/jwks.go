@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/utils"
+)
+
+// JWKS publishes the public keys used to sign access tokens
+func JWKS(c *gin.Context) {
+	keyRing, err := utils.CurrentKeyRing()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Signing keys are not configured"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{
+		"keys": keyRing.JWKS(),
+	})
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/utils"
+)
+
+type UnlockRequest struct {
+	Email string `json:"email"`
+	IP    string `json:"ip"`
+}
+
+// loginGuard tracks failed logins. It uses process memory unless main
+// installs a shared store with SetLoginGuard.
+var loginGuard = utils.NewLoginGuard(utils.NewMemoryAttemptStore(time.Hour), utils.DefaultAccountPolicy, utils.DefaultIPPolicy)
+
+// SetLoginGuard replaces the guard used by the login handlers
+func SetLoginGuard(guard *utils.LoginGuard) {
+	loginGuard = guard
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// rejectIfLocked answers 429 with Retry-After when the account or client IP
+// is currently backed off or locked. It reports whether it responded.
+func rejectIfLocked(c *gin.Context, email string) bool {
+	wait, err := loginGuard.Check(normalizeEmail(email), c.ClientIP())
+	if err != nil {
+		utils.Logger(c).Error("login guard check failed", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process login"})
+		return true
+	}
+	if wait == 0 {
+		return false
+	}
+
+	c.Header("Retry-After", utils.RetryAfterSeconds(wait))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts. Try again later."})
+	return true
+}
+
+// recordLoginFailure counts a failed attempt and sets Retry-After when the
+// next attempt has to wait
+func recordLoginFailure(c *gin.Context, email string) {
+	wait, err := loginGuard.Fail(normalizeEmail(email), c.ClientIP())
+	if err != nil {
+		utils.Logger(c).Error("login guard update failed", "error", err)
+		return
+	}
+	if wait > 0 {
+		c.Header("Retry-After", utils.RetryAfterSeconds(wait))
+	}
+}
+
+// UnlockLogin clears failed-login counters for an account and/or IP address
+func UnlockLogin(c *gin.Context) {
+	var req UnlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Email == "" && req.IP == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email or ip is required"})
+		return
+	}
+
+	if req.Email != "" {
+		if err := loginGuard.UnlockAccount(normalizeEmail(req.Email)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account"})
+			return
+		}
+	}
+	if req.IP != "" {
+		if err := loginGuard.UnlockIP(req.IP); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock IP address"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Login lockout cleared",
+	})
+}
@@ -0,0 +1,366 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	mfaIssuer         = "MediBridge"
+	recoveryCodeCount = 10
+)
+
+type MFACodeRequest struct {
+	Code         string `json:"code" redact:"secret"`
+	RecoveryCode string `json:"recoveryCode" redact:"secret"`
+}
+
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfaToken" binding:"required" redact:"secret"`
+	Code         string `json:"code" redact:"secret"`
+	RecoveryCode string `json:"recoveryCode" redact:"secret"`
+}
+
+type MFAEnrollLoginRequest struct {
+	MFAToken string `json:"mfaToken" binding:"required" redact:"secret"`
+}
+
+var errMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+
+// respondMFAChallenge answers the first login step for users who must pass a
+// second factor. The returned token can only be used with /login/mfa.
+func respondMFAChallenge(c *gin.Context, user *models.User) {
+	purpose := utils.PurposeMFAChallenge
+	if !user.MFAEnabled {
+		purpose = utils.PurposeMFAEnrollment
+	}
+
+	mfaToken, err := utils.GenerateMFAToken(user, purpose)
+	if err != nil {
+		utils.Logger(c).Error("MFA token generation failed", "userId", user.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mfaRequired":        true,
+		"enrollmentRequired": !user.MFAEnabled,
+		"mfaToken":           mfaToken,
+	})
+}
+
+// startMFAEnrollment stores a new pending TOTP secret for the user
+func startMFAEnrollment(user *models.User) (gin.H, error) {
+	if user.MFAEnabled {
+		return nil, errMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.DB.Model(user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"secret":          secret,
+		"provisioningUri": utils.TOTPProvisioningURI(mfaIssuer, user.Email, secret),
+	}, nil
+}
+
+// completeMFAEnrollment verifies the first code from the authenticator,
+// enables MFA and returns a fresh set of recovery codes.
+func completeMFAEnrollment(user *models.User, code string) ([]string, bool, error) {
+	if user.TOTPSecret == "" {
+		return nil, false, nil
+	}
+
+	step, ok := utils.VerifyTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, false, nil
+	}
+
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"mfa_enabled":     true,
+			"mfa_enrolled_at": &now,
+			"totp_last_step":  step,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return codes, true, nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(utils.NormalizeRecoveryCode(code)), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&models.RecoveryCode{UserID: userID, CodeHash: string(hash)}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// verifySecondFactor checks a TOTP code or, failing that, consumes a
+// recovery code. TOTP codes cannot be replayed within their time step.
+func verifySecondFactor(user *models.User, code, recoveryCode string) bool {
+	if !user.MFAEnabled || user.TOTPSecret == "" {
+		return false
+	}
+
+	if code != "" {
+		step, ok := utils.VerifyTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return false
+		}
+		result := config.DB.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		return result.Error == nil && result.RowsAffected == 1
+	}
+
+	if recoveryCode == "" {
+		return false
+	}
+
+	var codes []models.RecoveryCode
+	if err := config.DB.Where("user_id = ? AND used_at IS NULL", user.ID).Find(&codes).Error; err != nil {
+		return false
+	}
+
+	normalized := []byte(utils.NormalizeRecoveryCode(recoveryCode))
+	for _, stored := range codes {
+		if bcrypt.CompareHashAndPassword([]byte(stored.CodeHash), normalized) != nil {
+			continue
+		}
+		result := config.DB.Model(&models.RecoveryCode{}).
+			Where("id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", time.Now())
+		return result.Error == nil && result.RowsAffected == 1
+	}
+
+	return false
+}
+
+// LoginMFA completes a login with a TOTP or recovery code. Users whose role
+// requires MFA but who have not enrolled yet finish enrollment here.
+func LoginMFA(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	claims, err := utils.ValidateMFAToken(req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, claims.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	if rejectIfLocked(c, user.Email) {
+		return
+	}
+
+	if claims.Purpose == utils.PurposeMFAEnrollment && !user.MFAEnabled {
+		codes, ok, err := completeMFAEnrollment(&user, req.Code)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+			return
+		}
+		if !ok {
+			recordLoginFailure(c, user.Email)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+			return
+		}
+		completeLogin(c, &user, gin.H{"recoveryCodes": codes})
+		return
+	}
+
+	if !verifySecondFactor(&user, req.Code, req.RecoveryCode) {
+		recordLoginFailure(c, user.Email)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	completeLogin(c, &user, nil)
+}
+
+// LoginMFAEnroll starts TOTP enrollment during login for users whose role
+// requires MFA but who have not enrolled yet
+func LoginMFAEnroll(c *gin.Context) {
+	var req MFAEnrollLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	claims, err := utils.ValidateMFAToken(req.MFAToken)
+	if err != nil || claims.Purpose != utils.PurposeMFAEnrollment {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, claims.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	enrollment, err := startMFAEnrollment(&user)
+	if errors.Is(err, errMFAAlreadyEnabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// EnrollMFA starts TOTP enrollment for the current user
+func EnrollMFA(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	enrollment, err := startMFAEnrollment(&user)
+	if errors.Is(err, errMFAAlreadyEnabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// VerifyMFA confirms enrollment with a code from the authenticator app and
+// returns the recovery codes. They are only shown once.
+func VerifyMFA(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Verification code is required"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": errMFAAlreadyEnabled.Error()})
+		return
+	}
+
+	codes, ok, err := completeMFAEnrollment(&user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"recoveryCodes": codes,
+		"message":       "Two-factor authentication enabled",
+	})
+}
+
+// DisableMFA turns off two-factor authentication for the current user. It is
+// refused when the user's role requires MFA.
+func DisableMFA(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if config.MFARequired(string(user.Role)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is mandatory for your role"})
+		return
+	}
+
+	if !verifySecondFactor(&user, req.Code, req.RecoveryCode) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"mfa_enabled":     false,
+			"mfa_enrolled_at": nil,
+			"totp_secret":     "",
+			"totp_last_step":  0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Two-factor authentication disabled",
+	})
+}
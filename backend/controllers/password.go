@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const defaultPasswordResetTTL = time.Hour
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required" redact:"secret"`
+	NewPassword     string `json:"newPassword" binding:"required" redact:"secret"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required" redact:"secret"`
+	NewPassword string `json:"newPassword" binding:"required" redact:"secret"`
+}
+
+var errResetTokenUsed = errors.New("reset token already used")
+
+func passwordResetTTL() time.Duration {
+	return utils.DurationFromEnv("PASSWORD_RESET_TTL", defaultPasswordResetTTL)
+}
+
+// setPassword stores the bcrypt hash of a new password
+func setPassword(tx *gorm.DB, user *models.User, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return tx.Model(user).Updates(map[string]interface{}{
+		"password_hash":       string(hash),
+		"password_changed_at": &now,
+	}).Error
+}
+
+// ChangePassword lets the current user change their password. All other
+// sessions of the user are revoked; the current one stays signed in.
+func ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	if req.NewPassword == req.CurrentPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New password must be different from the current password"})
+		return
+	}
+
+	if err := utils.CurrentPasswordPolicy().Validate(req.NewPassword, user.Name, user.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := setPassword(tx, &user, req.NewPassword); err != nil {
+			return err
+		}
+		return revokeOtherSessions(tx, user.ID, c.GetString("sessionID"), "password changed")
+	})
+	if err != nil {
+		utils.Logger(c).Error("failed to change password", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	utils.Logger(c).Info("password changed")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Password changed successfully",
+	})
+}
+
+// issuePasswordReset creates a reset token for a user and invalidates the
+// user's earlier unused tokens
+func issuePasswordReset(tx *gorm.DB, userID, createdBy uint) (string, *models.PasswordResetToken, error) {
+	token, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	if err := tx.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", now).Error; err != nil {
+		return "", nil, err
+	}
+
+	reset := models.PasswordResetToken{
+		UserID:    userID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: now.Add(passwordResetTTL()),
+		CreatedBy: createdBy,
+	}
+	if err := tx.Create(&reset).Error; err != nil {
+		return "", nil, err
+	}
+
+	return token, &reset, nil
+}
+
+// CreatePasswordReset issues a single-use reset token for a user. Earlier
+// unused tokens for the same user stop working. The token is only returned
+// in this response and must be handed to the user out of band.
+func CreatePasswordReset(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var token string
+	var reset *models.PasswordResetToken
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		token, reset, err = issuePasswordReset(tx, user.ID, c.GetUint("userID"))
+		return err
+	})
+	if err != nil {
+		utils.Logger(c).Error("failed to create password reset token", "targetUserId", user.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset token"})
+		return
+	}
+
+	utils.Logger(c).Info("password reset token issued", "targetUserId", user.ID)
+	c.JSON(http.StatusCreated, gin.H{
+		"success":   true,
+		"token":     token,
+		"expiresAt": reset.ExpiresAt,
+		"message":   "Password reset token created",
+	})
+}
+
+// ResetPassword sets a new password using a reset token and signs the user
+// out everywhere
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	var reset models.PasswordResetToken
+	if err := config.DB.Where("token_hash = ?", utils.HashToken(req.Token)).First(&reset).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	now := time.Now()
+	if reset.UsedAt != nil || now.After(reset.ExpiresAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, reset.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	if err := utils.CurrentPasswordPolicy().Validate(req.NewPassword, user.Name, user.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errResetTokenUsed
+		}
+
+		if err := setPassword(tx, &user, req.NewPassword); err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID, "password reset")
+	})
+	if errors.Is(err, errResetTokenUsed) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to reset password", "targetUserId", user.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	if err := loginGuard.UnlockAccount(normalizeEmail(user.Email)); err != nil {
+		utils.Logger(c).Error("failed to clear login lockout", "error", err)
+	}
+
+	utils.Logger(c).Info("password reset", "targetUserId", user.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Password has been reset. Please log in with your new password.",
+	})
+}
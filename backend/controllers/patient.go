@@ -1,46 +1,66 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/medibridge/config"
 	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PatientRequest struct {
-	FirstName        string `json:"firstName" binding:"required"`
-	LastName         string `json:"lastName" binding:"required"`
-	Email           string `json:"email" binding:"required,email"`
-	Phone           string `json:"phone" binding:"required"`
-	DateOfBirth     string `json:"dateOfBirth" binding:"required"`
+	FirstName        string `json:"firstName" binding:"required" redact:"phi"`
+	LastName         string `json:"lastName" binding:"required" redact:"phi"`
+	Email           string `json:"email" binding:"omitempty,email" redact:"phi"`
+	Phone           string `json:"phone" binding:"required" redact:"phi"`
+	DateOfBirth     string `json:"dateOfBirth" binding:"required" redact:"phi"`
 	Gender          string `json:"gender" binding:"required,oneof=male female other"`
-	Address         string `json:"address" binding:"required"`
-	EmergencyContact string `json:"emergencyContact" binding:"required"`
-	EmergencyPhone  string `json:"emergencyPhone" binding:"required"`
-	BloodGroup      string `json:"bloodGroup"`
-	Allergies       string `json:"allergies"`
-	Diagnosis       string `json:"diagnosis"`
-	Notes           string `json:"notes"`
+	Address         string `json:"address" binding:"required" redact:"phi"`
+	EmergencyContact string `json:"emergencyContact" binding:"required" redact:"phi"`
+	EmergencyPhone  string `json:"emergencyPhone" binding:"required" redact:"phi"`
+	BloodGroup      string `json:"bloodGroup" redact:"phi"`
+	Allergies       string `json:"allergies" redact:"phi"`
+	// Diagnosis and Notes are only bound to reject them; see
+	// rejectRetiredPatientFields
+	Diagnosis       string `json:"diagnosis" redact:"phi"`
+	Notes           string `json:"notes" redact:"phi"`
 }
 
 type PatientUpdateRequest struct {
-	FirstName        string `json:"firstName"`
-	LastName         string `json:"lastName"`
-	Email           string `json:"email"`
-	Phone           string `json:"phone"`
-	DateOfBirth     string `json:"dateOfBirth"`
+	FirstName        string `json:"firstName" redact:"phi"`
+	LastName         string `json:"lastName" redact:"phi"`
+	Email           string `json:"email" redact:"phi"`
+	Phone           string `json:"phone" redact:"phi"`
+	DateOfBirth     string `json:"dateOfBirth" redact:"phi"`
 	Gender          string `json:"gender"`
-	Address         string `json:"address"`
-	EmergencyContact string `json:"emergencyContact"`
-	EmergencyPhone  string `json:"emergencyPhone"`
-	BloodGroup      string `json:"bloodGroup"`
-	Allergies       string `json:"allergies"`
-	Diagnosis       string `json:"diagnosis"`
-	Notes           string `json:"notes"`
+	Address         string `json:"address" redact:"phi"`
+	EmergencyContact string `json:"emergencyContact" redact:"phi"`
+	EmergencyPhone  string `json:"emergencyPhone" redact:"phi"`
+	BloodGroup      string `json:"bloodGroup" redact:"phi"`
+	Allergies       string `json:"allergies" redact:"phi"`
+	// Diagnosis and Notes are only bound to reject them; see
+	// rejectRetiredPatientFields
+	Diagnosis       string `json:"diagnosis" redact:"phi"`
+	Notes           string `json:"notes" redact:"phi"`
+}
+
+var errPatientVersionConflict = errors.New("patient was modified concurrently")
+
+// respondStalePatient answers 412 with the current state of a patient so the
+// client can merge its changes and retry with the new ETag
+func respondStalePatient(c *gin.Context, current *models.Patient) {
+	c.Header("ETag", utils.ETag(current.Version))
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error": "Patient was modified by someone else. Review the current record and retry with its ETag.",
+		"data":  readablePatient(c, current),
+	})
 }
 
 func CreatePatient(c *gin.Context) {
@@ -50,6 +70,10 @@ func CreatePatient(c *gin.Context) {
 		return
 	}
 
+	if rejectRetiredPatientFields(c, providedFields(&req)) || rejectForbiddenPatientFields(c, providedFields(&req)) {
+		return
+	}
+
 	// Parse date of birth
 	dob, err := time.Parse("2006-01-02", req.DateOfBirth)
 	if err != nil {
@@ -57,11 +81,13 @@ func CreatePatient(c *gin.Context) {
 		return
 	}
 
-	// Check if email already exists
-	var existingPatient models.Patient
-	if err := config.DB.Where("email = ?", req.Email).First(&existingPatient).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "A patient with this email already exists"})
-		return
+	// Check if email already exists. Email is optional.
+	if req.Email != "" {
+		var existingPatient models.Patient
+		if err := config.DB.Where("email = ?", req.Email).First(&existingPatient).Error; err == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A patient with this email already exists"})
+			return
+		}
 	}
 
 	userID, _ := c.Get("userID")
@@ -77,73 +103,223 @@ func CreatePatient(c *gin.Context) {
 		EmergencyPhone:  req.EmergencyPhone,
 		BloodGroup:      req.BloodGroup,
 		Allergies:       req.Allergies,
-		Diagnosis:       req.Diagnosis,
-		Notes:           req.Notes,
 		CreatedBy:       userID.(uint),
 		UpdatedBy:       userID.(uint),
+		Version:         1,
+	}
+
+	if rejectDuplicatePatient(c, &patient) {
+		return
 	}
 
-	if err := config.DB.Create(&patient).Error; err != nil {
+	// Free-text allergies are kept as an unstructured allergy entry
+	allergy := freeTextAllergy(&patient, patient.CreatedBy)
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		mrn, err := nextMRN(tx)
+		if err != nil {
+			return err
+		}
+		patient.MRN = mrn
+		if err := tx.Create(&patient).Error; err != nil {
+			return err
+		}
+		if allergy != nil {
+			allergy.PatientID = patient.ID
+			if err := tx.Create(allergy).Error; err != nil {
+				return err
+			}
+		}
+		if err := recordPatientRevision(c, tx, models.AuditCreate, nil, &patient); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditCreate, providedFields(&req), patient.ID)
+	})
+	if err != nil {
 		// Check for other database errors
-		if err.Error() == "ERROR: duplicate key value violates unique constraint \"patients_email_key\" (SQLSTATE 23505)" {
+		if isDuplicatePatientEmail(err) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "A patient with this email already exists"})
 			return
 		}
+		utils.Logger(c).Error("failed to create patient", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create patient"})
 		return
 	}
 
+	c.Header("ETag", utils.ETag(patient.Version))
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
-		"data": patient,
+		"data": readablePatient(c, &patient),
 		"message": "Patient created successfully",
 	})
 }
 
+// GetPatients lists the patients the user may access. Pages are selected
+// with page or with the nextCursor/prevCursor of a previous page; cursors
+// stay stable while patients are added or removed.
 func GetPatients(c *gin.Context) {
 	// Get pagination parameters
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	params, responded := parsePatientPage(c)
+	if responded {
+		return
+	}
 	search := c.Query("search")
 
-	// Calculate offset
-	offset := (page - 1) * limit
+	fields, responded := requestedPatientFields(c)
+	if responded {
+		return
+	}
+
+	// The list is read in one transaction, which only carries the fuzzy name
+	// threshold of a search and is never committed
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patients"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Build query, limited to the patients the user may access
+	query := scopePatients(c, tx.Model(&models.Patient{}))
 
-	// Build query
-	query := config.DB.Model(&models.Patient{})
+	// Apply filters and the search term, ranking matches by relevance
+	query, responded = filterPatients(c, query)
+	if responded {
+		return
+	}
+	var relevance *clause.Expr
+	if strings.TrimSpace(search) != "" {
+		if err := setFuzzyNameThreshold(tx); err != nil {
+			utils.Logger(c).Error("failed to search patients", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patients"})
+			return
+		}
+		var rank clause.Expr
+		query, rank = searchPatients(query, search)
+		relevance = &rank
+	}
 
-	// Add search condition if provided
-	if search != "" {
-		query = query.Where("first_name LIKE ? OR last_name LIKE ? OR email LIKE ?", 
-			"%"+search+"%", "%"+search+"%", "%"+search+"%")
+	keys, responded := patientSortKeys(c, relevance)
+	if responded {
+		return
+	}
+	cursorKey := patientCursorKey(keys, search)
+	var seek []interface{}
+	if params.cursor != nil {
+		var err error
+		if params.cursor.Key != cursorKey {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cursor belongs to a different sort or search"})
+			return
+		}
+		if seek, err = cursorValues(params.cursor, keys); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
 	}
 
-	// Get total count
+	// Get total count, unless the client opted out with count=false
 	var total int64
-	if err := query.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count patients"})
-		return
+	if params.count {
+		if err := query.Count(&total).Error; err != nil {
+			utils.Logger(c).Error("failed to count patients", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count patients"})
+			return
+		}
 	}
 
-	// Get paginated results
-	var patients []models.Patient
-	if err := query.Offset(offset).Limit(limit).Find(&patients).Error; err != nil {
+	// Get one row more than the page to know whether another page follows.
+	// A prev cursor reads backwards from its position.
+	backward := params.cursor != nil && params.cursor.Prev
+	if seek != nil {
+		query = seekPatients(query, keys, seek, backward)
+	}
+	query = orderPatients(query, keys, backward)
+	if params.page > 1 {
+		query = query.Offset((params.page - 1) * params.limit)
+	}
+	var rows []rankedPatient
+	if err := query.Limit(params.limit + 1).Find(&rows).Error; err != nil {
+		utils.Logger(c).Error("failed to fetch patients", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patients"})
 		return
 	}
+	more := len(rows) > params.limit
+	if more {
+		rows = rows[:params.limit]
+	}
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
 
-	// Calculate total pages
-	totalPages := (int(total) + limit - 1) / limit
+	var nextCursor, prevCursor string
+	if len(rows) > 0 {
+		hasNext, hasPrev := more, params.cursor != nil || params.page > 1
+		if backward {
+			hasNext, hasPrev = true, more
+		}
+		var err error
+		if hasNext {
+			nextCursor, err = newPatientCursor(&rows[len(rows)-1], keys, cursorKey, false)
+		}
+		if hasPrev && err == nil {
+			prevCursor, err = newPatientCursor(&rows[0], keys, cursorKey, true)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patients"})
+			return
+		}
+	}
+
+	patientIDs := make([]uint, len(rows))
+	data := make([]models.PatientSnapshot, len(rows))
+	for i := range rows {
+		patientIDs[i] = rows[i].ID
+		snapshot, err := projectPatient(&rows[i].Patient, fields)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patients"})
+			return
+		}
+		data[i] = snapshot
+	}
+
+	// Like a single patient, every listed patient comes with its allergy
+	// banner
+	banners, err := loadAllergyBanners(patientIDs)
+	if err != nil {
+		utils.Logger(c).Error("failed to load allergy banners", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patients"})
+		return
+	}
+	for i := range data {
+		data[i]["allergyBanner"] = banners[patientIDs[i]]
+	}
+	audited := append(append([]string{}, fields...), "allergyBanner")
+	if err := recordAudit(c, config.DB, models.AuditRead, audited, patientIDs...); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patients"})
+		return
+	}
 
 	// Return paginated response
+	pagination := gin.H{"limit": params.limit}
+	if nextCursor != "" {
+		pagination["nextCursor"] = nextCursor
+	}
+	if prevCursor != "" {
+		pagination["prevCursor"] = prevCursor
+	}
+	if params.cursor == nil {
+		pagination["page"] = params.page
+	}
+	if params.count {
+		pagination["total"] = total
+		pagination["totalPages"] = (int(total) + params.limit - 1) / params.limit
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"data": patients,
-		"pagination": gin.H{
-			"page":       page,
-			"limit":      limit,
-			"total":      total,
-			"totalPages": totalPages,
-		},
+		"data":       data,
+		"pagination": pagination,
 	})
 }
 
@@ -155,20 +331,38 @@ func UpdatePatient(c *gin.Context) {
 		return
 	}
 
+	if !hasPermission(c, models.PermPatientWriteDemographics) && !hasPermission(c, models.PermPatientWriteClinical) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
 	var req PatientUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if rejectRetiredPatientFields(c, providedFields(&req)) || rejectForbiddenPatientFields(c, providedFields(&req)) {
+		return
+	}
+
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header with the patient's ETag is required"})
+		return
+	}
+
 	userID, _ := c.Get("userID")
-	userRole, _ := c.Get("userRole")
 
 	var patient models.Patient
-	if err := config.DB.First(&patient, patientID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+	if !findScopedPatient(c, patientID, &patient) {
 		return
 	}
+	if !utils.MatchesETag(ifMatch, patient.Version) {
+		respondStalePatient(c, &patient)
+		return
+	}
+	before := patient
 
 	// Update fields if provided
 	if req.FirstName != "" {
@@ -210,26 +404,64 @@ func UpdatePatient(c *gin.Context) {
 		patient.Allergies = req.Allergies
 	}
 
-	// Only allow doctors to update diagnosis and notes
-	if userRole == models.RoleDoctor {
-		if req.Diagnosis != "" {
-			patient.Diagnosis = req.Diagnosis
-		}
-		if req.Notes != "" {
-			patient.Notes = req.Notes
-		}
-	}
 
 	patient.UpdatedBy = userID.(uint)
+	savePatientUpdate(c, &before, &patient, providedFields(&req))
+}
 
-	if err := config.DB.Save(&patient).Error; err != nil {
+// savePatientUpdate writes an edited patient if it is still at the version
+// it was read at, records the revision and audit entry, and responds. A
+// concurrent change answers 412 with the current record. Free-text
+// allergies become an unstructured allergy entry.
+func savePatientUpdate(c *gin.Context, before, patient *models.Patient, fields []string) {
+	patient.Version = before.Version + 1
+	allergy := freeTextAllergy(patient, patient.UpdatedBy)
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Only write if nobody else updated the record since it was read
+		result := tx.Model(&models.Patient{}).
+			Where("id = ? AND version = ?", patient.ID, before.Version).
+			Select("*").Omit("id", "created_at", "created_by").
+			Updates(patient)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errPatientVersionConflict
+		}
+		if allergy != nil {
+			if err := tx.Create(allergy).Error; err != nil {
+				return err
+			}
+		}
+		if err := recordPatientRevision(c, tx, models.AuditUpdate, before, patient); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditUpdate, fields, patient.ID)
+	})
+	if errors.Is(err, errPatientVersionConflict) {
+		var current models.Patient
+		if err := config.DB.First(&current, patient.ID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+			return
+		}
+		respondStalePatient(c, &current)
+		return
+	}
+	if isDuplicatePatientEmail(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A patient with this email already exists"})
+		return
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to update patient", "patientId", patient.ID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update patient"})
 		return
 	}
 
+	c.Header("ETag", utils.ETag(patient.Version))
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data": patient,
+		"data":    readablePatient(c, patient),
 		"message": "Patient updated successfully",
 	})
 }
@@ -242,19 +474,51 @@ func DeletePatient(c *gin.Context) {
 		return
 	}
 
-	// First check if patient exists
+	// First check if patient exists and is within the user's scope
 	var patient models.Patient
-	if err := config.DB.First(&patient, patientID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && !utils.MatchesETag(ifMatch, patient.Version) {
+		respondStalePatient(c, &patient)
+		return
+	}
+
+	// Perform the deletion. Deleting is a change of the record and gets a
+	// version of its own.
+	before := patient
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		userID := c.GetUint("userID")
+		patient.DeletedBy = &userID
+		patient.Version = before.Version + 1
+		result := tx.Model(&models.Patient{}).
+			Where("id = ? AND version = ?", patient.ID, before.Version).
+			Updates(map[string]interface{}{"deleted_by": userID, "version": patient.Version})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errPatientVersionConflict
+		}
+		if err := tx.Delete(&patient).Error; err != nil {
+			return err
+		}
+		if err := recordPatientRevision(c, tx, models.AuditDelete, &before, &patient); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditDelete, nil, patient.ID)
+	})
+	if errors.Is(err, errPatientVersionConflict) {
+		var current models.Patient
+		if err := config.DB.First(&current, patient.ID).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check patient existence"})
+		respondStalePatient(c, &current)
 		return
 	}
-
-	// Perform the deletion
-	if err := config.DB.Delete(&patient).Error; err != nil {
+	if err != nil {
+		utils.Logger(c).Error("failed to delete patient", "patientId", patient.ID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete patient"})
 		return
 	}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+)
+
+const (
+	defaultDuplicateThreshold = 0.75
+	maxDuplicateCandidates    = 5
+)
+
+// duplicateCandidate is an existing patient that may be the same person
+type duplicateCandidate struct {
+	Patient models.PatientSnapshot `json:"patient"`
+	utils.DuplicateMatch
+}
+
+// duplicateThreshold is the score from which a patient is reported as a
+// possible duplicate, configured with DUPLICATE_THRESHOLD (0.5 to 1). A name
+// match alone never reaches it.
+func duplicateThreshold() float64 {
+	if value, err := strconv.ParseFloat(os.Getenv("DUPLICATE_THRESHOLD"), 64); err == nil && value >= 0.5 && value <= 1 {
+		return value
+	}
+	return defaultDuplicateThreshold
+}
+
+// personDetails returns the details of a patient compared for duplicates
+func personDetails(patient *models.Patient) utils.PersonDetails {
+	return utils.PersonDetails{
+		FirstName:   patient.FirstName,
+		LastName:    patient.LastName,
+		DateOfBirth: patient.DateOfBirth,
+		Phone:       patient.Phone,
+		Email:       patient.Email,
+	}
+}
+
+// findDuplicateCandidates scores the patients the user may access that
+// share a date of birth (also with day and month swapped), phone or email
+// with person, and returns the best ones at or above the threshold. Those
+// are the only patients that can reach it.
+func findDuplicateCandidates(c *gin.Context, person utils.PersonDetails, excludeID uint) ([]duplicateCandidate, []uint, error) {
+	y, m, d := person.DateOfBirth.Date()
+	dates := []time.Time{person.DateOfBirth}
+	if d <= 12 && d != int(m) {
+		dates = append(dates, time.Date(y, time.Month(d), int(m), 0, 0, 0, 0, person.DateOfBirth.Location()))
+	}
+
+	signals := config.DB.Where("date_of_birth IN ?", dates)
+	if phone := utils.NormalizePhone(person.Phone); len(phone) >= 7 {
+		signals = signals.Or("right(regexp_replace(phone, '[^0-9]', '', 'g'), 10) = ?", phone)
+	}
+	if email := strings.ToLower(strings.TrimSpace(person.Email)); email != "" {
+		signals = signals.Or("lower(email) = ?", email)
+	}
+
+	var patients []models.Patient
+	if err := scopePatients(c, config.DB.Model(&models.Patient{})).
+		Where("patients.id <> ?", excludeID).
+		Where(signals).
+		Limit(200).Find(&patients).Error; err != nil {
+		return nil, nil, err
+	}
+
+	threshold := duplicateThreshold()
+	var matches []*models.Patient
+	scores := make(map[*models.Patient]utils.DuplicateMatch)
+	for i := range patients {
+		match := utils.ScoreDuplicate(person, personDetails(&patients[i]))
+		if match.Score >= threshold {
+			matches = append(matches, &patients[i])
+			scores[&patients[i]] = match
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return scores[matches[i]].Score > scores[matches[j]].Score })
+	if len(matches) > maxDuplicateCandidates {
+		matches = matches[:maxDuplicateCandidates]
+	}
+
+	candidates := make([]duplicateCandidate, len(matches))
+	patientIDs := make([]uint, len(matches))
+	for i, patient := range matches {
+		candidates[i] = duplicateCandidate{Patient: readablePatient(c, patient), DuplicateMatch: scores[patient]}
+		patientIDs[i] = patient.ID
+	}
+	return candidates, patientIDs, nil
+}
+
+// rejectDuplicatePatient answers 409 with the possible duplicates of a new
+// patient, unless the request has allowDuplicate=true. It reports whether
+// it responded.
+func rejectDuplicatePatient(c *gin.Context, patient *models.Patient) bool {
+	if c.Query("allowDuplicate") == "true" {
+		return false
+	}
+
+	candidates, patientIDs, err := findDuplicateCandidates(c, personDetails(patient), 0)
+	if err == nil && len(candidates) > 0 {
+		err = recordAudit(c, config.DB, models.AuditRead, readablePatientFields(c), patientIDs...)
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to check for duplicate patients", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create patient"})
+		return true
+	}
+	if len(candidates) == 0 {
+		return false
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"error":      "Possible duplicate patients found. Review the candidates, or retry with allowDuplicate=true to register a new patient anyway.",
+		"candidates": candidates,
+	})
+	return true
+}
+
+// GetPatientDuplicates lists the existing patients that may be the same
+// person as a patient, best match first
+func GetPatientDuplicates(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+
+	candidates, patientIDs, err := findDuplicateCandidates(c, personDetails(&patient), patient.ID)
+	if err == nil {
+		err = recordAudit(c, config.DB, models.AuditRead, readablePatientFields(c), append(patientIDs, patient.ID)...)
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to find duplicate patients", "patientId", patient.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find duplicate patients"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": candidates})
+}
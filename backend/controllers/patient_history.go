@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"gorm.io/gorm"
+)
+
+// recordPatientRevision stores the version of a patient after a change
+// together with the fields that changed since before, so that revision N is
+// the record served with ETag N. Pass a nil before for new records.
+func recordPatientRevision(c *gin.Context, tx *gorm.DB, action models.AuditAction, before, after *models.Patient) error {
+	var from models.PatientSnapshot
+	if before != nil {
+		var err error
+		if from, err = models.NewPatientSnapshot(before); err != nil {
+			return err
+		}
+	}
+	to, err := models.NewPatientSnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	revision := models.PatientRevision{
+		PatientID: after.ID,
+		Version:   after.Version,
+		Action:    action,
+		Snapshot:  to,
+		Changes:   models.DiffSnapshots(from, to),
+		ChangedBy: c.GetUint("userID"),
+	}
+	if action == models.AuditDelete {
+		revision.Changes = nil
+	}
+	return tx.Create(&revision).Error
+}
+
+// GetPatientHistory lists every revision of a patient, oldest first, with
+// the fields each one changed
+func GetPatientHistory(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+
+	var revisions []models.PatientRevision
+	if err := config.DB.Omit("snapshot").Where("patient_id = ?", patient.ID).Order("version").Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patient history"})
+		return
+	}
+
+	// Leave out changes to fields the user may not read
+	readable := make(map[string]bool)
+	for _, field := range readablePatientFields(c) {
+		readable[field] = true
+	}
+	for i := range revisions {
+		changes := revisions[i].Changes[:0]
+		for _, change := range revisions[i].Changes {
+			if readable[change.Field] {
+				changes = append(changes, change)
+			}
+		}
+		revisions[i].Changes = changes
+	}
+
+	if err := recordAudit(c, config.DB, models.AuditRead, []string{"history"}, patient.ID); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patient history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": revisions})
+}
+
+// GetPatientVersion returns a patient record as it was at a past version
+func GetPatientVersion(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+
+	var revision models.PatientRevision
+	err = config.DB.Where("patient_id = ? AND version = ?", patient.ID, version).First(&revision).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patient version"})
+		return
+	}
+
+	fields := readablePatientFields(c)
+	revision.Snapshot = projectSnapshot(revision.Snapshot, fields)
+
+	if err := recordAudit(c, config.DB, models.AuditRead, fields, patient.ID); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patient version"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": revision})
+}
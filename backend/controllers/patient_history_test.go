@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteRevisionMatchesVersion(t *testing.T) {
+	setupTestDB(t)
+
+	receptionist := createTestUser(t, models.RoleReceptionist)
+	// A patient from before revisions were recorded has version 1 and no
+	// history
+	patient := createTestPatient(t, receptionist.ID, time.Date(1970, 7, 4, 0, 0, 0, 0, time.UTC))
+
+	c, w := newTestContext(http.MethodDelete, "/patients/1", nil, &receptionist, patientParams(patient.ID))
+	DeletePatient(c)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var deleted models.Patient
+	require.NoError(t, config.DB.Unscoped().First(&deleted, patient.ID).Error)
+	assert.Equal(t, 2, deleted.Version)
+
+	var revisions []models.PatientRevision
+	require.NoError(t, config.DB.Where("patient_id = ?", patient.ID).Find(&revisions).Error)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, deleted.Version, revisions[0].Version)
+	assert.Equal(t, models.AuditDelete, revisions[0].Action)
+}
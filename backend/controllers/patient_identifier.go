@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"gorm.io/gorm"
+)
+
+const patientIdentifierIndex = "idx_patient_identifiers_value"
+
+type AddIdentifierRequest struct {
+	Type   models.IdentifierType `json:"type" binding:"required"`
+	Issuer string                `json:"issuer" binding:"required,max=100"`
+	Value  string                `json:"value" binding:"required,max=100" redact:"phi"`
+}
+
+// normalizeIdentifier trims an identifier value and upper-cases it so that
+// lookups do not depend on how it was typed
+func normalizeIdentifier(value string) string {
+	return strings.ToUpper(strings.TrimSpace(value))
+}
+
+// nextMRN issues the next medical record number of the configured clinic.
+// The sequence row is incremented atomically, so concurrent registrations
+// never share a number.
+func nextMRN(tx *gorm.DB) (string, error) {
+	format := utils.LoadMRNFormat()
+	var sequence int64
+	err := tx.Raw(`INSERT INTO mrn_sequences (clinic, last_value) VALUES (?, 1)
+		ON CONFLICT (clinic) DO UPDATE SET last_value = mrn_sequences.last_value + 1
+		RETURNING last_value`, format.Clinic).Scan(&sequence).Error
+	if err != nil {
+		return "", err
+	}
+	return format.Format(sequence), nil
+}
+
+// AssignMissingMRNs gives an MRN to every patient registered before MRNs
+// were introduced, oldest first, including patients in the trash
+func AssignMissingMRNs(db *gorm.DB) (int, error) {
+	var patientIDs []uint
+	if err := db.Unscoped().Model(&models.Patient{}).Where("mrn = '' OR mrn IS NULL").Order("id").Pluck("id", &patientIDs).Error; err != nil {
+		return 0, err
+	}
+	if len(patientIDs) == 0 {
+		return 0, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, id := range patientIDs {
+			mrn, err := nextMRN(tx)
+			if err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.Patient{}).Where("id = ?", id).UpdateColumn("mrn", mrn).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(patientIDs), nil
+}
+
+// GetPatientIdentifiers lists a patient's external identifiers
+func GetPatientIdentifiers(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+
+	var identifiers []models.PatientIdentifier
+	if err := config.DB.Where("patient_id = ?", patient.ID).Order("id").Find(&identifiers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch identifiers"})
+		return
+	}
+	if err := recordAudit(c, config.DB, models.AuditRead, []string{"identifiers"}, patient.ID); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch identifiers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": identifiers})
+}
+
+// AddPatientIdentifier records a national ID, insurance number or other
+// external identifier. The same value cannot belong to two patients for
+// one type and issuer.
+func AddPatientIdentifier(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	var req AddIdentifierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Type.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid identifier type. Use national_id, insurance, passport, drivers_license or other"})
+		return
+	}
+
+	identifier := models.PatientIdentifier{
+		Type:      req.Type,
+		Issuer:    strings.TrimSpace(req.Issuer),
+		Value:     normalizeIdentifier(req.Value),
+		CreatedBy: c.GetUint("userID"),
+	}
+	if identifier.Issuer == "" || identifier.Value == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Issuer and value cannot be blank"})
+		return
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+	identifier.PatientID = patient.ID
+
+	var conflict bool
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.PatientIdentifier{}).
+			Where("type = ? AND issuer = ? AND value = ?", identifier.Type, identifier.Issuer, identifier.Value).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			conflict = true
+			return nil
+		}
+		if err := tx.Create(&identifier).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditUpdate, []string{"identifiers"}, patient.ID)
+	})
+	var pgErr *pgconn.PgError
+	if conflict || errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == patientIdentifierIndex {
+		c.JSON(http.StatusConflict, gin.H{"error": "This identifier is already registered to a patient"})
+		return
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to add patient identifier", "patientId", patient.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add identifier"})
+		return
+	}
+
+	utils.Logger(c).Info("patient identifier added", "patientId", patient.ID, "identifierType", identifier.Type)
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    identifier,
+		"message": "Identifier added successfully",
+	})
+}
+
+// RemovePatientIdentifier deletes one of a patient's external identifiers
+func RemovePatientIdentifier(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	identifierID, err := strconv.ParseUint(c.Param("identifierId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid identifier ID"})
+		return
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+
+	var removed int64
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND patient_id = ?", identifierID, patient.ID).Delete(&models.PatientIdentifier{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		removed = result.RowsAffected
+		return recordAudit(c, tx, models.AuditUpdate, []string{"identifiers"}, patient.ID)
+	})
+	if err != nil {
+		utils.Logger(c).Error("failed to remove patient identifier", "patientId", patient.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove identifier"})
+		return
+	}
+	if removed == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identifier not found"})
+		return
+	}
+
+	utils.Logger(c).Info("patient identifier removed", "patientId", patient.ID, "identifierId", identifierID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Identifier removed successfully",
+	})
+}
+
+// LookupPatients finds the patients known by an identifier value. Without
+// type= the value is matched against MRNs and every identifier type;
+// type=mrn checks the MRN's check digit first. issuer= narrows identifier
+// matches. Only patients the user may access are returned.
+func LookupPatients(c *gin.Context) {
+	value := normalizeIdentifier(c.Query("value"))
+	if value == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "value is required"})
+		return
+	}
+
+	idType := models.IdentifierType(c.Query("type"))
+	issuer := strings.TrimSpace(c.Query("issuer"))
+	if idType != "" && idType != models.IdentifierMRN && !idType.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid identifier type. Use mrn, national_id, insurance, passport, drivers_license or other"})
+		return
+	}
+	if idType == models.IdentifierMRN && !utils.ValidMRNCheckDigit(value) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MRN check digit is invalid"})
+		return
+	}
+
+	identifiers := config.DB.Model(&models.PatientIdentifier{}).Select("patient_id").Where("value = ?", value)
+	if idType != "" {
+		identifiers = identifiers.Where("type = ?", idType)
+	}
+	if issuer != "" {
+		identifiers = identifiers.Where("issuer = ?", issuer)
+	}
+
+	query := scopePatients(c, config.DB.Model(&models.Patient{}))
+	switch {
+	case idType == models.IdentifierMRN:
+		query = query.Where("patients.mrn = ?", value)
+	case idType == "" && issuer == "":
+		query = query.Where("patients.mrn = ? OR patients.id IN (?)", value, identifiers)
+	default:
+		query = query.Where("patients.id IN (?)", identifiers)
+	}
+
+	var patients []models.Patient
+	if err := query.Order("patients.id").Limit(100).Find(&patients).Error; err != nil {
+		utils.Logger(c).Error("failed to look up patients", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up patients"})
+		return
+	}
+
+	fields := readablePatientFields(c)
+	patientIDs := make([]uint, len(patients))
+	data := make([]models.PatientSnapshot, len(patients))
+	for i := range patients {
+		var err error
+		patientIDs[i] = patients[i].ID
+		if data[i], err = projectPatient(&patients[i], fields); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up patients"})
+			return
+		}
+	}
+	if err := recordAudit(c, config.DB, models.AuditRead, fields, patientIDs...); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up patients"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
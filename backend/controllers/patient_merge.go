@@ -0,0 +1,496 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"gorm.io/gorm"
+)
+
+type MergePatientsRequest struct {
+	SourceID uint `json:"sourceId" binding:"required"`
+	// Fields are copied from the source onto the surviving patient
+	Fields []string `json:"fields"`
+}
+
+var (
+	errMergeSourceChanged = errors.New("merge source was modified concurrently")
+	errUnmergeConflict    = errors.New("merge was already undone")
+)
+
+// patientRelation is a table with rows that belong to a patient. Merges
+// move the rows to the surviving patient, unmerges move them back and purges
+// delete them.
+type patientRelation struct {
+	model interface{}
+	// movable narrows the rows moved to the target, for rows that may not
+	// exist twice on one patient. Rows left behind stay with the source.
+	movable func(tx *gorm.DB, query *gorm.DB, targetID uint) *gorm.DB
+}
+
+var patientRelations = map[string]patientRelation{
+	"careTeam": {
+		model: &models.CareTeamMember{},
+		movable: func(tx *gorm.DB, query *gorm.DB, targetID uint) *gorm.DB {
+			members := tx.Model(&models.CareTeamMember{}).Select("user_id").Where("patient_id = ?", targetID)
+			primary := tx.Model(&models.CareTeamMember{}).Select("1").
+				Where("patient_id = ? AND role = ?", targetID, models.CareTeamPrimaryPhysician)
+			return query.Where("user_id NOT IN (?)", members).
+				Where("role <> ? OR NOT EXISTS (?)", models.CareTeamPrimaryPhysician, primary)
+		},
+	},
+	"identifiers": {model: &models.PatientIdentifier{}},
+	"allergies": {
+		model: &models.Allergy{},
+		movable: func(tx *gorm.DB, query *gorm.DB, targetID uint) *gorm.DB {
+			active := tx.Model(&models.Allergy{}).Select("lower(substance)").
+				Where("patient_id = ? AND status = ?", targetID, models.AllergyActive)
+			return query.Where("status <> ? OR lower(substance) NOT IN (?)", models.AllergyActive, active)
+		},
+	},
+	"diagnoses": {model: &models.Diagnosis{}},
+	"encounters": {
+		model: &models.Encounter{},
+		movable: func(tx *gorm.DB, query *gorm.DB, targetID uint) *gorm.DB {
+			doctors := tx.Model(&models.Encounter{}).Select("doctor_id").
+				Where("patient_id = ? AND status = ?", targetID, models.EncounterOpen)
+			return query.Where("status <> ? OR doctor_id NOT IN (?)", models.EncounterOpen, doctors)
+		},
+	},
+	"notes":       {model: &models.ClinicalNote{}},
+	"noteAddenda": {model: &models.NoteAddendum{}},
+	"vitals":      {model: &models.VitalSign{}},
+	// Actions belong to their grant and follow it
+	"emergencyAccess": {model: &models.EmergencyAccess{}},
+}
+
+// moveRelatedRecords re-points the related rows of the source patient to
+// the target and returns their IDs per relation
+func moveRelatedRecords(tx *gorm.DB, sourceID, targetID uint) (map[string][]uint, error) {
+	moved := make(map[string][]uint)
+	for name, relation := range patientRelations {
+		query := tx.Model(relation.model).Where("patient_id = ?", sourceID)
+		if relation.movable != nil {
+			query = relation.movable(tx, query, targetID)
+		}
+		var ids []uint
+		if err := query.Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		if err := tx.Model(relation.model).Where("id IN ?", ids).Update("patient_id", targetID).Error; err != nil {
+			return nil, err
+		}
+		moved[name] = ids
+	}
+	return moved, nil
+}
+
+// patientMergeResponse is a merge with the values the copied fields had on
+// the target before it, limited to the fields the caller may read
+type patientMergeResponse struct {
+	models.PatientMerge
+	TargetBefore models.PatientSnapshot `json:"targetBefore"`
+}
+
+// GetPatientMerges lists the merges a patient took part in, newest first
+func GetPatientMerges(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+
+	var merges []models.PatientMerge
+	if err := config.DB.Where("target_id = ? OR source_id = ?", patient.ID, patient.ID).Order("id DESC").Find(&merges).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch merges"})
+		return
+	}
+
+	// The copied fields' former values are shown like the patient itself,
+	// and reading them is a read of both patients of the merge
+	readable := readablePatientFields(c)
+	copied := make(map[string]bool)
+	data := make([]patientMergeResponse, len(merges))
+	patientIDs := []uint{patient.ID}
+	seen := map[uint]bool{patient.ID: true}
+	for i := range merges {
+		data[i] = patientMergeResponse{PatientMerge: merges[i], TargetBefore: projectSnapshot(merges[i].TargetBefore, readable)}
+		for _, field := range merges[i].Fields {
+			copied[field] = true
+		}
+		for _, id := range []uint{merges[i].TargetID, merges[i].SourceID} {
+			if !seen[id] {
+				seen[id] = true
+				patientIDs = append(patientIDs, id)
+			}
+		}
+	}
+	audited := []string{"merges"}
+	for _, field := range readable {
+		if copied[field] {
+			audited = append(audited, field)
+		}
+	}
+	if err := recordAudit(c, config.DB, models.AuditRead, audited, patientIDs...); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch merges"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// MergePatient merges a duplicate (sourceId) into the patient in the URL.
+// The source's care team, identifiers and clinical records move to the
+// surviving patient, the
+// fields listed in fields are copied from the source, and the source is
+// retired. Requires If-Match with the surviving patient's ETag.
+func MergePatient(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	var req MergePatientsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if uint64(req.SourceID) == patientID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A patient cannot be merged into itself"})
+		return
+	}
+
+	fields := make([]string, 0, len(req.Fields))
+	var unknown []string
+	for _, field := range req.Fields {
+		if _, known := patientFieldPermissions[field]; !known {
+			unknown = append(unknown, field)
+			continue
+		}
+		fields = append(fields, field)
+	}
+	if len(unknown) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or read-only fields: " + strings.Join(unknown, ", ")})
+		return
+	}
+	sort.Strings(fields)
+	if rejectRetiredPatientFields(c, fields) || rejectForbiddenPatientFields(c, fields) {
+		return
+	}
+
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header with the patient's ETag is required"})
+		return
+	}
+
+	var target, source models.Patient
+	if !findScopedPatient(c, patientID, &target) || !findScopedPatient(c, uint64(req.SourceID), &source) {
+		return
+	}
+	if !utils.MatchesETag(ifMatch, target.Version) {
+		respondStalePatient(c, &target)
+		return
+	}
+	targetBefore, sourceBefore := target, source
+
+	targetDoc, err := patientDocument(&target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge patients"})
+		return
+	}
+	sourceDoc, err := patientDocument(&source)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge patients"})
+		return
+	}
+	previous := make(models.PatientSnapshot, len(fields))
+	for _, field := range fields {
+		previous[field] = targetDoc[field]
+		targetDoc[field] = sourceDoc[field]
+	}
+	if err := applyPatientDocument(&target, targetDoc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge patients"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	now := time.Now()
+	target.Version = targetBefore.Version + 1
+	target.UpdatedBy = userID
+	source.Version = sourceBefore.Version + 1
+	source.UpdatedBy = userID
+
+	merge := models.PatientMerge{
+		TargetID:     target.ID,
+		SourceID:     source.ID,
+		Fields:       fields,
+		TargetBefore: previous,
+		Score:        utils.ScoreDuplicate(personDetails(&targetBefore), personDetails(&sourceBefore)).Score,
+		MergedBy:     userID,
+		MergedAt:     now,
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Retire the source first so that its email is free for the target
+		result := tx.Model(&models.Patient{}).
+			Where("id = ? AND version = ?", source.ID, sourceBefore.Version).
+			Updates(map[string]interface{}{
+				"deleted_at":     now,
+				"deleted_by":     userID,
+				"merged_into_id": target.ID,
+				"updated_by":     userID,
+				"version":        source.Version,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errMergeSourceChanged
+		}
+
+		result = tx.Model(&models.Patient{}).
+			Where("id = ? AND version = ?", target.ID, targetBefore.Version).
+			Select("*").Omit("id", "created_at", "created_by").
+			Updates(&target)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errPatientVersionConflict
+		}
+
+		moved, err := moveRelatedRecords(tx, source.ID, target.ID)
+		if err != nil {
+			return err
+		}
+		merge.Moved = moved
+		if err := tx.Create(&merge).Error; err != nil {
+			return err
+		}
+		if err := recordPatientRevision(c, tx, models.AuditMerge, &sourceBefore, &source); err != nil {
+			return err
+		}
+		if err := recordPatientRevision(c, tx, models.AuditMerge, &targetBefore, &target); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditMerge, fields, target.ID, source.ID)
+	})
+	if errors.Is(err, errPatientVersionConflict) {
+		var current models.Patient
+		if err := config.DB.First(&current, target.ID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+			return
+		}
+		respondStalePatient(c, &current)
+		return
+	}
+	if errors.Is(err, errMergeSourceChanged) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The duplicate patient was changed during the merge. Review it and retry."})
+		return
+	}
+	if isDuplicatePatientEmail(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A patient with this email already exists"})
+		return
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to merge patients", "patientId", target.ID, "sourceId", source.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge patients"})
+		return
+	}
+
+	utils.Logger(c).Info("patients merged", "patientId", target.ID, "sourceId", source.ID, "mergeId", merge.ID)
+	c.Header("ETag", utils.ETag(target.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"patient": readablePatient(c, &target),
+			"merge":   merge,
+		},
+		"message": "Patients merged successfully",
+	})
+}
+
+// UnmergePatient undoes a merge into the patient in the URL. The retired
+// patient comes back with the rows that were moved from it. Copied fields
+// get their previous value back unless they were edited since the merge.
+// Requires If-Match with the surviving patient's ETag.
+func UnmergePatient(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	mergeID, err := strconv.ParseUint(c.Param("mergeId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge ID"})
+		return
+	}
+
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header with the patient's ETag is required"})
+		return
+	}
+
+	var target models.Patient
+	if !findScopedPatient(c, patientID, &target) {
+		return
+	}
+
+	var merge models.PatientMerge
+	if err := config.DB.Where("id = ? AND target_id = ? AND unmerged_at IS NULL", mergeID, target.ID).First(&merge).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merge not found"})
+		return
+	}
+	if !utils.MatchesETag(ifMatch, target.Version) {
+		respondStalePatient(c, &target)
+		return
+	}
+
+	var source models.Patient
+	if err := config.DB.Unscoped().Where("merged_into_id = ?", target.ID).First(&source, merge.SourceID).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "The merged patient no longer exists"})
+		return
+	}
+	targetBefore, sourceBefore := target, source
+
+	targetDoc, err := patientDocument(&target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to undo merge"})
+		return
+	}
+	sourceDoc, err := patientDocument(&source)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to undo merge"})
+		return
+	}
+	var reverted []string
+	for _, field := range merge.Fields {
+		if reflect.DeepEqual(targetDoc[field], sourceDoc[field]) {
+			targetDoc[field] = merge.TargetBefore[field]
+			reverted = append(reverted, field)
+		}
+	}
+	if err := applyPatientDocument(&target, targetDoc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to undo merge"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	now := time.Now()
+	target.Version = targetBefore.Version + 1
+	target.UpdatedBy = userID
+	source.Version = sourceBefore.Version + 1
+	source.UpdatedBy = userID
+	source.DeletedAt = gorm.DeletedAt{}
+	source.DeletedBy = nil
+	source.MergedIntoID = nil
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PatientMerge{}).
+			Where("id = ? AND unmerged_at IS NULL", merge.ID).
+			Updates(map[string]interface{}{"unmerged_at": now, "unmerged_by": userID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errUnmergeConflict
+		}
+
+		// Give the fields back before the source returns with its email
+		result = tx.Model(&models.Patient{}).
+			Where("id = ? AND version = ?", target.ID, targetBefore.Version).
+			Select("*").Omit("id", "created_at", "created_by").
+			Updates(&target)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errPatientVersionConflict
+		}
+
+		if err := tx.Unscoped().Model(&models.Patient{}).
+			Where("id = ?", source.ID).
+			Updates(map[string]interface{}{
+				"deleted_at":     nil,
+				"deleted_by":     nil,
+				"merged_into_id": nil,
+				"updated_by":     userID,
+				"version":        source.Version,
+			}).Error; err != nil {
+			return err
+		}
+
+		for name, ids := range merge.Moved {
+			relation, known := patientRelations[name]
+			if !known {
+				continue
+			}
+			if err := tx.Model(relation.model).
+				Where("id IN ? AND patient_id = ?", ids, target.ID).
+				Update("patient_id", source.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := recordPatientRevision(c, tx, models.AuditUnmerge, &targetBefore, &target); err != nil {
+			return err
+		}
+		if err := recordPatientRevision(c, tx, models.AuditUnmerge, &sourceBefore, &source); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditUnmerge, reverted, target.ID, source.ID)
+	})
+	if errors.Is(err, errPatientVersionConflict) {
+		var current models.Patient
+		if err := config.DB.First(&current, target.ID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+			return
+		}
+		respondStalePatient(c, &current)
+		return
+	}
+	if errors.Is(err, errUnmergeConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This merge was already undone"})
+		return
+	}
+	if isDuplicatePatientEmail(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another patient now uses the merged patient's email. Change it before undoing the merge."})
+		return
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to undo merge", "patientId", target.ID, "mergeId", merge.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to undo merge"})
+		return
+	}
+
+	utils.Logger(c).Info("patients unmerged", "patientId", target.ID, "sourceId", source.ID, "mergeId", merge.ID)
+	c.Header("ETag", utils.ETag(target.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"patient":         readablePatient(c, &target),
+			"restoredPatient": readablePatient(c, &source),
+			"revertedFields":  reverted,
+		},
+		"message": "Merge undone successfully",
+	})
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mergeTestPatients merges source into target as user, copying fields, and
+// returns the merge
+func mergeTestPatients(t *testing.T, user *models.User, target, source *models.Patient, fields ...string) models.PatientMerge {
+	t.Helper()
+	req := MergePatientsRequest{SourceID: source.ID, Fields: fields}
+	c, w := newTestContext(http.MethodPost, "/patients/1/merge", req, user, patientParams(target.ID))
+	c.Request.Header.Set("If-Match", utils.ETag(target.Version))
+	MergePatient(c)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var merge models.PatientMerge
+	require.NoError(t, config.DB.Where("target_id = ? AND source_id = ?", target.ID, source.ID).Last(&merge).Error)
+	return merge
+}
+
+// patientOf returns the patient a row of model belongs to
+func patientOf(t *testing.T, model interface{}, id uint) uint {
+	t.Helper()
+	var patientID uint
+	require.NoError(t, config.DB.Model(model).Where("id = ?", id).Pluck("patient_id", &patientID).Error)
+	return patientID
+}
+
+func TestMergePatient(t *testing.T) {
+	setupTestDB(t)
+
+	receptionist := createTestUser(t, models.RoleReceptionist)
+	doctor := createTestUser(t, models.RoleDoctor)
+	target := createTestPatient(t, receptionist.ID, time.Date(1982, 3, 17, 0, 0, 0, 0, time.UTC))
+	source := createTestPatient(t, receptionist.ID, time.Date(1982, 3, 17, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, config.DB.Model(&source).UpdateColumn("phone", "555-0177").Error)
+
+	identifier := models.PatientIdentifier{PatientID: source.ID, Type: models.IdentifierInsurance, Issuer: "Acme Health", Value: fmt.Sprint(time.Now().UnixNano()), CreatedBy: receptionist.ID}
+	require.NoError(t, config.DB.Create(&identifier).Error)
+	diagnosis := models.Diagnosis{PatientID: source.ID, Code: "I10", Description: "Essential (primary) hypertension", Status: models.DiagnosisActive, DiagnosedBy: doctor.ID, UpdatedBy: doctor.ID}
+	require.NoError(t, config.DB.Create(&diagnosis).Error)
+	vital := models.VitalSign{PatientID: source.ID, Type: models.VitalPulse, Value: 72, Unit: "bpm", TakenAt: time.Now(), RecordedBy: doctor.ID}
+	require.NoError(t, config.DB.Create(&vital).Error)
+
+	merge := mergeTestPatients(t, &receptionist, &target, &source, "phone")
+
+	assert.Equal(t, target.ID, patientOf(t, &models.PatientIdentifier{}, identifier.ID))
+	assert.Equal(t, target.ID, patientOf(t, &models.Diagnosis{}, diagnosis.ID))
+	assert.Equal(t, target.ID, patientOf(t, &models.VitalSign{}, vital.ID))
+	assert.Equal(t, []uint{diagnosis.ID}, merge.Moved["diagnoses"])
+	assert.Equal(t, models.PatientSnapshot{"phone": "555-0100"}, merge.TargetBefore)
+
+	var merged models.Patient
+	require.NoError(t, config.DB.First(&merged, target.ID).Error)
+	assert.Equal(t, "555-0177", merged.Phone)
+	assert.Equal(t, target.Version+1, merged.Version)
+
+	var retired models.Patient
+	require.NoError(t, config.DB.Unscoped().First(&retired, source.ID).Error)
+	assert.True(t, retired.DeletedAt.Valid)
+	require.NotNil(t, retired.MergedIntoID)
+	assert.Equal(t, target.ID, *retired.MergedIntoID)
+}
+
+func TestUnmergePatient(t *testing.T) {
+	setupTestDB(t)
+
+	receptionist := createTestUser(t, models.RoleReceptionist)
+	doctor := createTestUser(t, models.RoleDoctor)
+	target := createTestPatient(t, receptionist.ID, time.Date(1991, 12, 1, 0, 0, 0, 0, time.UTC))
+	source := createTestPatient(t, receptionist.ID, time.Date(1991, 12, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, config.DB.Model(&source).UpdateColumn("phone", "555-0177").Error)
+	allergy := models.Allergy{PatientID: source.ID, Substance: "Latex", Category: models.AllergyEnvironment, Status: models.AllergyActive, RecordedBy: doctor.ID, UpdatedBy: doctor.ID}
+	require.NoError(t, config.DB.Create(&allergy).Error)
+	kept := models.Allergy{PatientID: target.ID, Substance: "Peanut", Category: models.AllergyFood, Status: models.AllergyActive, RecordedBy: doctor.ID, UpdatedBy: doctor.ID}
+	require.NoError(t, config.DB.Create(&kept).Error)
+
+	merge := mergeTestPatients(t, &receptionist, &target, &source, "phone")
+	require.NoError(t, config.DB.First(&target, target.ID).Error)
+
+	c, w := newTestContext(http.MethodPost, "/patients/1/merges/1/unmerge", nil, &receptionist,
+		patientParams(target.ID, gin.Param{Key: "mergeId", Value: fmt.Sprint(merge.ID)}))
+	c.Request.Header.Set("If-Match", utils.ETag(target.Version))
+	UnmergePatient(c)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var restoredTarget, restoredSource models.Patient
+	require.NoError(t, config.DB.First(&restoredTarget, target.ID).Error)
+	assert.Equal(t, "555-0100", restoredTarget.Phone, "the copied phone is given back")
+	require.NoError(t, config.DB.First(&restoredSource, source.ID).Error, "the duplicate is no longer retired")
+	assert.Nil(t, restoredSource.MergedIntoID)
+	assert.Equal(t, "555-0177", restoredSource.Phone)
+
+	assert.Equal(t, source.ID, patientOf(t, &models.Allergy{}, allergy.ID))
+	assert.Equal(t, target.ID, patientOf(t, &models.Allergy{}, kept.ID))
+
+	require.NoError(t, config.DB.First(&merge, merge.ID).Error)
+	assert.NotNil(t, merge.UnmergedAt)
+}
+
+func TestMergeMovableFilters(t *testing.T) {
+	setupTestDB(t)
+
+	receptionist := createTestUser(t, models.RoleReceptionist)
+	doctor := createTestUser(t, models.RoleDoctor)
+	colleague := createTestUser(t, models.RoleDoctor)
+	physician := createTestUser(t, models.RoleDoctor)
+	target := createTestPatient(t, receptionist.ID, time.Date(1964, 7, 23, 0, 0, 0, 0, time.UTC))
+	source := createTestPatient(t, receptionist.ID, time.Date(1964, 7, 23, 0, 0, 0, 0, time.UTC))
+
+	allergy := func(patient *models.Patient, substance string, status models.AllergyStatus) models.Allergy {
+		allergy := models.Allergy{PatientID: patient.ID, Substance: substance, Category: models.AllergyDrug, Status: status, RecordedBy: doctor.ID, UpdatedBy: doctor.ID}
+		require.NoError(t, config.DB.Create(&allergy).Error)
+		return allergy
+	}
+	allergy(&target, "Penicillin", models.AllergyActive)
+	duplicateAllergy := allergy(&source, "penicillin", models.AllergyActive)
+	resolvedAllergy := allergy(&source, "Penicillin", models.AllergyResolved)
+	newAllergy := allergy(&source, "Aspirin", models.AllergyActive)
+
+	openTestEncounter(t, &doctor, &target)
+	secondOpen := openTestEncounter(t, &doctor, &source)
+	otherOpen := openTestEncounter(t, &colleague, &source)
+
+	member := func(patient *models.Patient, user *models.User, role models.CareTeamRole) models.CareTeamMember {
+		member := models.CareTeamMember{PatientID: patient.ID, UserID: user.ID, Role: role, AssignedBy: receptionist.ID}
+		require.NoError(t, config.DB.Create(&member).Error)
+		return member
+	}
+	member(&target, &doctor, models.CareTeamPrimaryPhysician)
+	duplicateMember := member(&source, &doctor, models.CareTeamConsulting)
+	secondPrimary := member(&source, &physician, models.CareTeamPrimaryPhysician)
+	newMember := member(&source, &colleague, models.CareTeamConsulting)
+
+	mergeTestPatients(t, &receptionist, &target, &source)
+
+	tests := []struct {
+		name  string
+		model interface{}
+		id    uint
+		want  uint
+	}{
+		{name: "active allergy to a substance already active stays", model: &models.Allergy{}, id: duplicateAllergy.ID, want: source.ID},
+		{name: "resolved allergy moves", model: &models.Allergy{}, id: resolvedAllergy.ID, want: target.ID},
+		{name: "other allergy moves", model: &models.Allergy{}, id: newAllergy.ID, want: target.ID},
+		{name: "second open encounter of a doctor stays", model: &models.Encounter{}, id: secondOpen.ID, want: source.ID},
+		{name: "open encounter of another doctor moves", model: &models.Encounter{}, id: otherOpen.ID, want: target.ID},
+		{name: "care team member already on the target stays", model: &models.CareTeamMember{}, id: duplicateMember.ID, want: source.ID},
+		{name: "second primary physician stays", model: &models.CareTeamMember{}, id: secondPrimary.ID, want: source.ID},
+		{name: "new care team member moves", model: &models.CareTeamMember{}, id: newMember.ID, want: target.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, patientOf(t, tt.model, tt.id))
+		})
+	}
+}
+
+func TestGetPatientMerges(t *testing.T) {
+	setupTestDB(t)
+
+	receptionist := createTestUser(t, models.RoleReceptionist)
+	target := createTestPatient(t, receptionist.ID, time.Date(1977, 5, 9, 0, 0, 0, 0, time.UTC))
+	source := createTestPatient(t, receptionist.ID, time.Date(1977, 5, 9, 0, 0, 0, 0, time.UTC))
+	merge := models.PatientMerge{
+		TargetID:     target.ID,
+		SourceID:     source.ID,
+		Fields:       []string{"phone", "diagnosis"},
+		TargetBefore: models.PatientSnapshot{"phone": "555-0199", "diagnosis": "Asthma"},
+		MergedBy:     receptionist.ID,
+		MergedAt:     time.Now(),
+	}
+	require.NoError(t, config.DB.Create(&merge).Error)
+
+	c, w := newTestContext(http.MethodGet, "/patients/1/merges", nil, &receptionist, patientParams(target.ID))
+	GetPatientMerges(c)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Data []struct {
+			ID           uint                   `json:"id"`
+			TargetBefore models.PatientSnapshot `json:"targetBefore"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Data, 1)
+	assert.Equal(t, models.PatientSnapshot{"phone": "555-0199"}, body.Data[0].TargetBefore, "receptionists do not read clinical fields")
+
+	for _, id := range []uint{target.ID, source.ID} {
+		var event models.AuditEvent
+		require.NoError(t, config.DB.Where("patient_id = ? AND action = ?", id, models.AuditRead).Last(&event).Error)
+		assert.Equal(t, []string{"merges", "phone"}, event.Fields)
+	}
+}
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+)
+
+const (
+	defaultPatientPageSize = 10
+	maxPatientPageSize     = 100
+)
+
+// patientPage is a validated page request of a patient list: either a page
+// number or a cursor
+type patientPage struct {
+	page   int
+	limit  int
+	cursor *patientCursor
+	count  bool
+}
+
+// patientCursor is the position encoded in the nextCursor and prevCursor
+// tokens: the sort values of the first or last patient of a page
+type patientCursor struct {
+	// Key identifies the sort order and search the cursor was issued for
+	Key    string        `json:"k"`
+	Values []interface{} `json:"v"`
+	Prev   bool          `json:"p,omitempty"`
+}
+
+// rankedPatient is a patient row with the relevance it was ranked by
+type rankedPatient struct {
+	models.Patient
+	Relevance float64 `gorm:"->;column:relevance"`
+}
+
+// parsePage validates the page and limit parameters of a list. limit
+// defaults to defaultLimit. It reports whether it responded.
+func parsePage(c *gin.Context, defaultLimit, maxLimit int) (page, limit int, responded bool) {
+	page, limit = 1, defaultLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be a whole number from 1 to %d", maxLimit)})
+			return 0, 0, true
+		}
+		limit = n
+	}
+	if value := c.Query("page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a whole number from 1"})
+			return 0, 0, true
+		}
+		page = n
+	}
+	return page, limit, false
+}
+
+// parsePatientPage validates the page, limit, cursor and count parameters.
+// It reports whether it responded.
+func parsePatientPage(c *gin.Context) (patientPage, bool) {
+	req := patientPage{page: 1, limit: defaultPatientPageSize, count: true}
+	badRequest := func(message string) (patientPage, bool) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return patientPage{}, true
+	}
+
+	page, limit, responded := parsePage(c, defaultPatientPageSize, maxPatientPageSize)
+	if responded {
+		return patientPage{}, true
+	}
+	req.page, req.limit = page, limit
+	if value := c.Query("count"); value != "" {
+		count, err := strconv.ParseBool(value)
+		if err != nil {
+			return badRequest("count must be true or false")
+		}
+		req.count = count
+	}
+	if value := c.Query("cursor"); value != "" {
+		if c.Query("page") != "" {
+			return badRequest("Use either page or cursor, not both")
+		}
+		var cursor patientCursor
+		if err := utils.DecodeCursor(value, &cursor); err != nil {
+			return badRequest("Invalid cursor")
+		}
+		req.cursor = &cursor
+		req.page = 0
+	}
+	return req, false
+}
+
+// patientCursorKey fingerprints a sort order and search term, so that a
+// cursor is only used with the list it was issued for
+func patientCursorKey(keys []patientSortKey, search string) string {
+	parts := make([]string, 0, len(keys)+1)
+	for _, key := range keys {
+		if key.desc {
+			parts = append(parts, "-"+key.name)
+		} else {
+			parts = append(parts, key.name)
+		}
+	}
+	parts = append(parts, strings.ToLower(strings.TrimSpace(search)))
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x1f")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// patientSortValue returns the value a patient is sorted by for a key
+func patientSortValue(row *rankedPatient, name string) interface{} {
+	switch name {
+	case "relevance":
+		return row.Relevance
+	case "firstName":
+		return row.FirstName
+	case "lastName":
+		return row.LastName
+	case "dateOfBirth":
+		return row.DateOfBirth
+	case "gender":
+		return row.Gender
+	case "bloodGroup":
+		return row.BloodGroup
+	case "mrn":
+		return row.MRN
+	case "createdAt":
+		return row.CreatedAt
+	case "updatedAt":
+		return row.UpdatedAt
+	}
+	return row.ID
+}
+
+// newPatientCursor encodes the position of a patient in a list
+func newPatientCursor(row *rankedPatient, keys []patientSortKey, cursorKey string, prev bool) (string, error) {
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i] = patientSortValue(row, key.name)
+	}
+	return utils.EncodeCursor(patientCursor{Key: cursorKey, Values: values, Prev: prev})
+}
+
+// cursorValues converts the JSON values of a cursor back to the types of
+// the sort keys
+func cursorValues(cursor *patientCursor, keys []patientSortKey) ([]interface{}, error) {
+	if len(cursor.Values) != len(keys) {
+		return nil, errors.New("cursor does not match the sort order")
+	}
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		switch key.name {
+		case "relevance", "id":
+			number, ok := cursor.Values[i].(float64)
+			if !ok {
+				return nil, errors.New("invalid cursor value")
+			}
+			values[i] = number
+			if key.name == "id" {
+				values[i] = uint(number)
+			}
+		case "dateOfBirth", "createdAt", "updatedAt":
+			text, _ := cursor.Values[i].(string)
+			t, err := time.Parse(time.RFC3339Nano, text)
+			if err != nil {
+				return nil, errors.New("invalid cursor value")
+			}
+			values[i] = t
+		default:
+			text, ok := cursor.Values[i].(string)
+			if !ok {
+				return nil, errors.New("invalid cursor value")
+			}
+			values[i] = text
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+	acceptPatch           = mergePatchContentType + ", " + jsonPatchContentType
+)
+
+// patientDocument is the JSON document a patch is applied to. It has the
+// same shape as a CreatePatient request.
+func patientDocument(patient *models.Patient) (map[string]interface{}, error) {
+	req := PatientRequest{
+		FirstName:        patient.FirstName,
+		LastName:         patient.LastName,
+		Email:            patient.Email,
+		Phone:            patient.Phone,
+		DateOfBirth:      patient.DateOfBirth.Format("2006-01-02"),
+		Gender:           patient.Gender,
+		Address:          patient.Address,
+		EmergencyContact: patient.EmergencyContact,
+		EmergencyPhone:   patient.EmergencyPhone,
+		BloodGroup:       patient.BloodGroup,
+		Allergies:        patient.Allergies,
+		Diagnosis:        patient.Diagnosis,
+		Notes:            patient.Notes,
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// changedDocumentFields lists the members that were added, removed or changed
+func changedDocumentFields(before, after map[string]interface{}) []string {
+	var changed []string
+	for field, value := range after {
+		if old, ok := before[field]; !ok || !reflect.DeepEqual(old, value) {
+			changed = append(changed, field)
+		}
+	}
+	for field := range before {
+		if _, ok := after[field]; !ok {
+			changed = append(changed, field)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// PatchPatient updates a patient with an RFC 7396 merge patch
+// (application/merge-patch+json or application/json), where null clears a
+// field, or an RFC 6902 JSON Patch (application/json-patch+json). The
+// patched record must pass the same validation as CreatePatient.
+func PatchPatient(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	if !hasPermission(c, models.PermPatientWriteDemographics) && !hasPermission(c, models.PermPatientWriteClinical) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	contentType := c.ContentType()
+	if contentType != mergePatchContentType && contentType != jsonPatchContentType && contentType != binding.MIMEJSON {
+		c.Header("Accept-Patch", acceptPatch)
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Use " + mergePatchContentType + " or " + jsonPatchContentType})
+		return
+	}
+
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header with the patient's ETag is required"})
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+	if !utils.MatchesETag(ifMatch, patient.Version) {
+		respondStalePatient(c, &patient)
+		return
+	}
+	before := patient
+
+	current, err := patientDocument(&patient)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update patient"})
+		return
+	}
+
+	// Fields the user may not read are left out of the document so that
+	// "test" operations cannot probe them, and put back after patching
+	hidden := make(map[string]interface{})
+	for field, value := range current {
+		if !hasPermission(c, patientReadPermission(field)) {
+			hidden[field] = value
+			delete(current, field)
+		}
+	}
+
+	var patched interface{}
+	if contentType == jsonPatchContentType {
+		var ops []utils.PatchOperation
+		if err := json.Unmarshal(body, &ops); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON Patch must be an array of operations"})
+			return
+		}
+		if patched, err = utils.ApplyJSONPatch(current, ops); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Cannot apply patch: " + err.Error()})
+			return
+		}
+	} else {
+		var patch interface{}
+		if err := json.Unmarshal(body, &patch); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+			return
+		}
+		if _, ok := patch.(map[string]interface{}); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Merge patch must be a JSON object"})
+			return
+		}
+		patched = utils.MergePatch(current, patch)
+	}
+
+	result, ok := patched.(map[string]interface{})
+	if !ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Patched patient must be a JSON object"})
+		return
+	}
+
+	var unknown []string
+	for field := range result {
+		if _, known := patientFieldPermissions[field]; !known {
+			unknown = append(unknown, field)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or read-only fields: " + strings.Join(unknown, ", ")})
+		return
+	}
+
+	changed := changedDocumentFields(current, result)
+	if len(changed) == 0 {
+		c.Header("ETag", utils.ETag(patient.Version))
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    readablePatient(c, &patient),
+			"message": "No changes",
+		})
+		return
+	}
+
+	if rejectRetiredPatientFields(c, changed) || rejectForbiddenPatientFields(c, changed) {
+		return
+	}
+
+	for field, value := range hidden {
+		if _, set := result[field]; !set {
+			result[field] = value
+		}
+	}
+
+	// Validate the merged record exactly like a new one
+	data, err := json.Marshal(result)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update patient"})
+		return
+	}
+	var req PatientRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Patient fields must be strings"})
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dob, err := time.Parse("2006-01-02", req.DateOfBirth)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date of birth format. Use YYYY-MM-DD"})
+		return
+	}
+
+	if req.Email != patient.Email && req.Email != "" {
+		var existing models.Patient
+		if err := config.DB.Where("email = ? AND id <> ?", req.Email, patient.ID).First(&existing).Error; err == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A patient with this email already exists"})
+			return
+		}
+	}
+
+	applyPatientRequest(&patient, &req, dob)
+	patient.UpdatedBy = c.GetUint("userID")
+
+	savePatientUpdate(c, &before, &patient, changed)
+}
+
+// applyPatientRequest sets every field of a patient from a validated
+// request document
+func applyPatientRequest(patient *models.Patient, req *PatientRequest, dob time.Time) {
+	patient.FirstName = req.FirstName
+	patient.LastName = req.LastName
+	patient.Email = req.Email
+	patient.Phone = req.Phone
+	patient.DateOfBirth = dob
+	patient.Gender = req.Gender
+	patient.Address = req.Address
+	patient.EmergencyContact = req.EmergencyContact
+	patient.EmergencyPhone = req.EmergencyPhone
+	patient.BloodGroup = req.BloodGroup
+	patient.Allergies = req.Allergies
+	patient.Diagnosis = req.Diagnosis
+	patient.Notes = req.Notes
+}
+
+// applyPatientDocument sets a patient from a document in the shape built by
+// patientDocument
+func applyPatientDocument(patient *models.Patient, doc map[string]interface{}) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var req PatientRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return err
+	}
+	dob, err := time.Parse("2006-01-02", req.DateOfBirth)
+	if err != nil {
+		return err
+	}
+	applyPatientRequest(patient, &req, dob)
+	return nil
+}
@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+)
+
+// patientReadFields lists every patient field a read can return
+var patientReadFields = func() []string {
+	fields := make([]string, 0, len(patientFieldPermissions))
+	for field := range patientFieldPermissions {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}()
+
+// patientMetaFields are bookkeeping fields returned with every projection
+var patientMetaFields = []string{"id", "mrn", "version", "createdAt", "updatedAt", "createdBy", "updatedBy"}
+
+// patientInclude loads a related resource embedded with include=
+type patientInclude struct {
+	perm models.Permission
+	load func(c *gin.Context, patient *models.Patient) (interface{}, error)
+}
+
+// patientIncludes lists the related resources GetPatient can embed
+var patientIncludes = map[string]patientInclude{
+	"careTeam": {
+		perm: models.PermPatientRead,
+		load: func(c *gin.Context, patient *models.Patient) (interface{}, error) {
+			var members []models.CareTeamMember
+			err := config.DB.Preload("User").Where("patient_id = ?", patient.ID).Order("id").Find(&members).Error
+			return members, err
+		},
+	},
+	"identifiers": {
+		perm: models.PermPatientRead,
+		load: func(c *gin.Context, patient *models.Patient) (interface{}, error) {
+			var identifiers []models.PatientIdentifier
+			err := config.DB.Where("patient_id = ?", patient.ID).Order("id").Find(&identifiers).Error
+			return identifiers, err
+		},
+	},
+	"allergies": {
+		perm: models.PermPatientRead,
+		load: func(c *gin.Context, patient *models.Patient) (interface{}, error) {
+			var allergies []models.Allergy
+			err := config.DB.Where("patient_id = ?", patient.ID).Order("id").Find(&allergies).Error
+			return allergies, err
+		},
+	},
+	"diagnoses": {
+		perm: models.PermPatientReadClinical,
+		load: func(c *gin.Context, patient *models.Patient) (interface{}, error) {
+			var diagnoses []models.Diagnosis
+			err := config.DB.Preload("Doctor").Where("patient_id = ?", patient.ID).Order("status, id").Find(&diagnoses).Error
+			return diagnoses, err
+		},
+	},
+	"encounters": {
+		perm: models.PermPatientReadClinical,
+		load: func(c *gin.Context, patient *models.Patient) (interface{}, error) {
+			var encounters []models.Encounter
+			if err := config.DB.Preload("Doctor").Where("patient_id = ?", patient.ID).Order("started_at DESC, id DESC").Find(&encounters).Error; err != nil {
+				return nil, err
+			}
+			if err := attachEncounterVitals(encounters); err != nil {
+				return nil, err
+			}
+			return encounters, attachEncounterDiagnoses(encounters)
+		},
+	},
+	"notes": {
+		perm: models.PermPatientReadClinical,
+		load: func(c *gin.Context, patient *models.Patient) (interface{}, error) {
+			var notes []models.ClinicalNote
+			query := visibleNotes(c, config.DB.Preload("Author").Where("patient_id = ?", patient.ID))
+			if err := query.Order("created_at DESC, id DESC").Find(&notes).Error; err != nil {
+				return nil, err
+			}
+			return notes, attachNoteAddenda(notes)
+		},
+	},
+	// vitals is the latest observation of each vital sign
+	"vitals": {
+		perm: models.PermPatientReadClinical,
+		load: func(c *gin.Context, patient *models.Patient) (interface{}, error) {
+			var vitals []models.VitalSign
+			err := config.DB.Raw(`SELECT DISTINCT ON (type) * FROM vital_signs WHERE patient_id = ?
+				ORDER BY type, taken_at DESC, id DESC`, patient.ID).Scan(&vitals).Error
+			return vitals, err
+		},
+	},
+}
+
+// patientReadPermission returns the permission needed to read a patient
+// field. Fields that need patient:write_clinical to write need
+// patient:read_clinical to read.
+func patientReadPermission(field string) models.Permission {
+	if patientFieldPermissions[field] == models.PermPatientWriteClinical {
+		return models.PermPatientReadClinical
+	}
+	return models.PermPatientRead
+}
+
+// readablePatientFields lists the patient fields the current user may read
+func readablePatientFields(c *gin.Context) []string {
+	var fields []string
+	for _, field := range patientReadFields {
+		if hasPermission(c, patientReadPermission(field)) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// splitList parses a comma-separated query parameter
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// requestedPatientFields resolves the fields= projection. Without it every
+// field the user may read is returned. Unknown fields answer 400 and fields
+// the user may not read answer 403. It reports whether it responded.
+func requestedPatientFields(c *gin.Context) ([]string, bool) {
+	readable := readablePatientFields(c)
+	value := c.Query("fields")
+	if value == "" {
+		return readable, false
+	}
+
+	var fields, unknown, forbidden []string
+	for _, field := range splitList(value) {
+		if _, known := patientFieldPermissions[field]; !known {
+			unknown = append(unknown, field)
+			continue
+		}
+		if !hasPermission(c, patientReadPermission(field)) {
+			forbidden = append(forbidden, field)
+			continue
+		}
+		fields = append(fields, field)
+	}
+
+	if len(unknown) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown fields: " + strings.Join(unknown, ", ")})
+		return nil, true
+	}
+	if len(forbidden) > 0 {
+		sort.Strings(forbidden)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":           "You are not allowed to read these fields: " + strings.Join(forbidden, ", "),
+			"forbiddenFields": forbidden,
+		})
+		return nil, true
+	}
+	sort.Strings(fields)
+	return fields, false
+}
+
+// projectSnapshot keeps the metadata and the given fields of a patient
+// snapshot
+func projectSnapshot(snapshot models.PatientSnapshot, fields []string) models.PatientSnapshot {
+	projected := make(models.PatientSnapshot, len(fields)+len(patientMetaFields))
+	for _, field := range patientMetaFields {
+		if value, ok := snapshot[field]; ok {
+			projected[field] = value
+		}
+	}
+	for _, field := range fields {
+		if value, ok := snapshot[field]; ok {
+			projected[field] = value
+		}
+	}
+	return projected
+}
+
+// projectPatient returns the metadata and the given fields of a patient
+func projectPatient(patient *models.Patient, fields []string) (models.PatientSnapshot, error) {
+	snapshot, err := models.NewPatientSnapshot(patient)
+	if err != nil {
+		return nil, err
+	}
+	return projectSnapshot(snapshot, fields), nil
+}
+
+// readablePatient projects a patient onto the fields the current user may
+// read, for responses that return the record
+func readablePatient(c *gin.Context, patient *models.Patient) models.PatientSnapshot {
+	data, err := projectPatient(patient, readablePatientFields(c))
+	if err != nil {
+		utils.Logger(c).Error("failed to serialise patient", "patientId", patient.ID, "error", err)
+		return models.PatientSnapshot{"id": patient.ID, "version": patient.Version}
+	}
+	return data
+}
+
+// GetPatient returns one patient with its allergy banner. Supports fields=
+// to choose the returned fields and include= to embed related resources.
+func GetPatient(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	fields, responded := requestedPatientFields(c)
+	if responded {
+		return
+	}
+
+	includes := splitList(c.Query("include"))
+	for _, name := range includes {
+		if name == "documents" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Patient documents are not stored yet, so they cannot be included"})
+			return
+		}
+		include, known := patientIncludes[name]
+		if !known {
+			supported := make([]string, 0, len(patientIncludes))
+			for name := range patientIncludes {
+				supported = append(supported, name)
+			}
+			sort.Strings(supported)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown include " + name + ". Supported: " + strings.Join(supported, ", ")})
+			return
+		}
+		if !hasPermission(c, include.perm) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to include " + name})
+			return
+		}
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+
+	data, err := projectPatient(&patient, fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patient"})
+		return
+	}
+
+	// The allergy banner comes with every read, whatever fields are chosen
+	banner, err := loadAllergyBanner(&patient)
+	if err != nil {
+		utils.Logger(c).Error("failed to load allergy banner", "patientId", patient.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patient"})
+		return
+	}
+	data["allergyBanner"] = banner
+
+	audited := append(append([]string{}, fields...), "allergyBanner")
+	for _, name := range includes {
+		embedded, err := patientIncludes[name].load(c, &patient)
+		if err != nil {
+			utils.Logger(c).Error("failed to load patient include", "include", name, "patientId", patient.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patient"})
+			return
+		}
+		data[name] = embedded
+		audited = append(audited, name)
+	}
+
+	if err := recordAudit(c, config.DB, models.AuditRead, audited, patient.ID); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch patient"})
+		return
+	}
+
+	c.Header("ETag", utils.ETag(patient.Version))
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
@@ -0,0 +1,272 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// fuzzyNameThreshold is the trigram word similarity from which a name
+// matches a search term, so that "jhon smiht" still finds John Smith
+const fuzzyNameThreshold = 0.4
+
+// likeEscaper escapes the LIKE wildcards of a search term, so that "_" and
+// "%" match themselves
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// patientBloodGroups lists the accepted blood groups
+var patientBloodGroups = map[string]bool{
+	"A+": true, "A-": true, "B+": true, "B-": true,
+	"AB+": true, "AB-": true, "O+": true, "O-": true,
+}
+
+// patientSortColumns maps the sort= names of GetPatients to the expressions
+// sorted by
+var patientSortColumns = map[string]string{
+	"firstName":   "patients.first_name",
+	"lastName":    "patients.last_name",
+	"dateOfBirth": "patients.date_of_birth",
+	"gender":      "patients.gender",
+	"bloodGroup":  "COALESCE(patients.blood_group, '')",
+	"mrn":         "patients.mrn",
+	"createdAt":   "patients.created_at",
+	"updatedAt":   "patients.updated_at",
+}
+
+// patientSortFields lists the accepted sort= names; relevance needs search
+var patientSortFields = func() []string {
+	fields := []string{"relevance"}
+	for field := range patientSortColumns {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields[1:])
+	return fields
+}()
+
+var patientGenders = map[string]bool{"male": true, "female": true, "other": true}
+
+// filterPatients applies the filter query parameters of GetPatients. Invalid
+// values answer 400. It reports whether it responded.
+func filterPatients(c *gin.Context, query *gorm.DB) (*gorm.DB, bool) {
+	badRequest := func(message string) (*gorm.DB, bool) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return nil, true
+	}
+
+	if value := c.Query("phone"); value != "" {
+		digits := utils.NormalizePhone(value)
+		if len(digits) < 4 {
+			return badRequest("phone must contain at least 4 digits")
+		}
+		query = query.Where("regexp_replace(patients.phone, '[^0-9]', '', 'g') LIKE ?", "%"+digits)
+	}
+	if value := c.Query("mrn"); value != "" {
+		query = query.Where("patients.mrn = ?", normalizeIdentifier(value))
+	}
+
+	for _, param := range []string{"dobFrom", "dobTo"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		dob, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return badRequest("Invalid " + param + ". Use YYYY-MM-DD")
+		}
+		if param == "dobFrom" {
+			query = query.Where("patients.date_of_birth >= ?", dob)
+		} else {
+			query = query.Where("patients.date_of_birth <= ?", dob)
+		}
+	}
+
+	// An age range is a date of birth range counted back from today
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for _, param := range []string{"ageMin", "ageMax"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		age, err := strconv.Atoi(value)
+		if err != nil || age < 0 || age > 150 {
+			return badRequest("Invalid " + param + ". Use a whole number of years from 0 to 150")
+		}
+		if param == "ageMin" {
+			query = query.Where("patients.date_of_birth <= ?", today.AddDate(-age, 0, 0))
+		} else {
+			query = query.Where("patients.date_of_birth > ?", today.AddDate(-age-1, 0, 0))
+		}
+	}
+
+	if value := c.Query("gender"); value != "" {
+		genders := splitList(strings.ToLower(value))
+		for _, gender := range genders {
+			if !patientGenders[gender] {
+				return badRequest("Invalid gender. Use male, female or other")
+			}
+		}
+		query = query.Where("patients.gender IN ?", genders)
+	}
+	if value := c.Query("bloodGroup"); value != "" {
+		groups := splitList(strings.ToUpper(value))
+		for _, group := range groups {
+			if !patientBloodGroups[group] {
+				return badRequest("Invalid bloodGroup. Use A+, A-, B+, B-, AB+, AB-, O+ or O-")
+			}
+		}
+		query = query.Where("upper(patients.blood_group) IN ?", groups)
+	}
+	if value := c.Query("createdBy"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return badRequest("Invalid createdBy")
+		}
+		query = query.Where("patients.created_by = ?", id)
+	}
+
+	dateFilters := []struct {
+		param, condition string
+		endOfDay         bool
+	}{
+		{"createdFrom", "patients.created_at >= ?", false},
+		{"createdTo", "patients.created_at <= ?", true},
+		{"updatedFrom", "patients.updated_at >= ?", false},
+		{"updatedTo", "patients.updated_at <= ?", true},
+	}
+	for _, filter := range dateFilters {
+		value := c.Query(filter.param)
+		if value == "" {
+			continue
+		}
+		t, err := parseTimeFilter(value, filter.endOfDay)
+		if err != nil {
+			return badRequest("Invalid " + filter.param + ". Use YYYY-MM-DD or RFC 3339")
+		}
+		query = query.Where(filter.condition, t)
+	}
+
+	return query, false
+}
+
+// setFuzzyNameThreshold sets the word similarity threshold of the <%
+// operator for the rest of the transaction
+func setFuzzyNameThreshold(tx *gorm.DB) error {
+	threshold := strconv.FormatFloat(fuzzyNameThreshold, 'f', -1, 64)
+	return tx.Exec("SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)", threshold).Error
+}
+
+// searchPatients matches a search term, case-insensitively, against names
+// (with trigram similarity, so typos still match), email, MRN and phone. It
+// returns the relevance expression to rank the matches with: exact MRN or
+// email matches first, then by name similarity. The name match uses the <%
+// operator so that it can use the trigram index; query must run in a
+// transaction prepared with setFuzzyNameThreshold.
+func searchPatients(query *gorm.DB, search string) (*gorm.DB, clause.Expr) {
+	term := strings.ToLower(strings.TrimSpace(search))
+	name := config.PatientNameExpr
+	like := "%" + likeEscaper.Replace(term) + "%"
+
+	conditions := config.DB.
+		Where(name+" LIKE ?", like).
+		Or("lower(patients.email) LIKE ?", like).
+		Or("patients.mrn = ?", normalizeIdentifier(term)).
+		Or("? <% "+name, term)
+	if digits := utils.NormalizePhone(term); len(digits) >= 4 {
+		conditions = conditions.Or("regexp_replace(patients.phone, '[^0-9]', '', 'g') LIKE ?", "%"+digits+"%")
+	}
+
+	relevance := clause.Expr{
+		SQL: "CASE WHEN patients.mrn = ? OR lower(patients.email) = ? THEN 1 ELSE 0 END + " +
+			"(word_similarity(?, " + name + ") + similarity(" + name + ", ?)) / 2",
+		Vars: []interface{}{normalizeIdentifier(term), term, term, term},
+	}
+	return query.Where(conditions), relevance
+}
+
+// patientSortKey is one ORDER BY term of a patient list
+type patientSortKey struct {
+	name string
+	expr string
+	vars []interface{}
+	desc bool
+}
+
+// patientSortKeys resolves the sort= parameter. Without it, searches are
+// ranked by relevance and other lists ordered by ID. The patient ID is
+// always the last key so that the order is total, which keeps pages stable
+// and cursors unambiguous. It reports whether it responded.
+func patientSortKeys(c *gin.Context, relevance *clause.Expr) ([]patientSortKey, bool) {
+	fields, err := utils.ParseSort(c.Query("sort"), patientSortFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort: " + err.Error()})
+		return nil, true
+	}
+	if len(fields) == 0 && relevance != nil {
+		fields = []utils.SortField{{Name: "relevance", Desc: true}}
+	}
+
+	keys := make([]patientSortKey, 0, len(fields)+1)
+	for _, field := range fields {
+		if field.Name == "relevance" {
+			if relevance == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Sorting by relevance needs a search term"})
+				return nil, true
+			}
+			keys = append(keys, patientSortKey{name: field.Name, expr: "(" + relevance.SQL + ")", vars: relevance.Vars, desc: field.Desc})
+			continue
+		}
+		keys = append(keys, patientSortKey{name: field.Name, expr: patientSortColumns[field.Name], desc: field.Desc})
+	}
+	return append(keys, patientSortKey{name: "id", expr: "patients.id"}), false
+}
+
+// orderPatients orders a patient query by its sort keys, or in the opposite
+// direction with reverse. Apply it after counting, since relevance is
+// selected as an extra column.
+func orderPatients(query *gorm.DB, keys []patientSortKey, reverse bool) *gorm.DB {
+	for _, key := range keys {
+		direction := " ASC"
+		if key.desc != reverse {
+			direction = " DESC"
+		}
+		if key.name == "relevance" {
+			query = query.Select("patients.*, "+key.expr+" AS relevance", key.vars...).Order("relevance" + direction)
+			continue
+		}
+		query = query.Order(key.expr + direction)
+	}
+	return query
+}
+
+// seekPatients keeps the patients after a position in the sort order, or
+// before it with reverse. With keys (a, b) ascending that is
+// a > x OR (a = x AND b > y).
+func seekPatients(query *gorm.DB, keys []patientSortKey, values []interface{}, reverse bool) *gorm.DB {
+	var terms []string
+	var vars []interface{}
+	for i, key := range keys {
+		op := " > ?"
+		if key.desc != reverse {
+			op = " < ?"
+		}
+		term := make([]string, 0, i+1)
+		for j, previous := range keys[:i] {
+			term = append(term, previous.expr+" = ?")
+			vars = append(vars, previous.vars...)
+			vars = append(vars, values[j])
+		}
+		term = append(term, key.expr+op)
+		vars = append(vars, key.vars...)
+		vars = append(vars, values[i])
+		terms = append(terms, "("+strings.Join(term, " AND ")+")")
+	}
+	return query.Where("("+strings.Join(terms, " OR ")+")", vars...)
+}
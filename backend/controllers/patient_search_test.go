@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// searchTestPatients lists patients as user with the given query and
+// returns the last names found
+func searchTestPatients(t *testing.T, user *models.User, query url.Values) (int, []string) {
+	t.Helper()
+	c, w := newTestContext(http.MethodGet, "/patients?"+query.Encode(), nil, user, nil)
+	GetPatients(c)
+	if w.Code != http.StatusOK {
+		return w.Code, nil
+	}
+
+	var body struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	names := make([]string, len(body.Data))
+	for i, patient := range body.Data {
+		names[i], _ = patient["lastName"].(string)
+	}
+	return w.Code, names
+}
+
+func TestSearchPatients(t *testing.T) {
+	setupTestDB(t)
+
+	receptionist := createTestUser(t, models.RoleReceptionist)
+	lastName := fmt.Sprintf("Quistgaard%d", time.Now().UnixNano())
+	patient := createTestPatient(t, receptionist.ID, time.Date(1968, 3, 14, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, config.DB.Model(&patient).Updates(map[string]interface{}{"first_name": "Jonathan", "last_name": lastName}).Error)
+
+	tests := []struct {
+		name       string
+		query      url.Values
+		wantStatus int
+		wantFound  bool
+	}{
+		{name: "exact name", query: url.Values{"search": {"jonathan " + lastName}}, wantStatus: http.StatusOK, wantFound: true},
+		{name: "misspelled name", query: url.Values{"search": {"jonathon " + lastName}}, wantStatus: http.StatusOK, wantFound: true},
+		{name: "percent matches itself", query: url.Values{"search": {"%"}}, wantStatus: http.StatusOK},
+		{name: "unknown blood group", query: url.Values{"bloodGroup": {"C+"}}, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Set("limit", "100")
+			status, names := searchTestPatients(t, &receptionist, tt.query)
+			require.Equal(t, tt.wantStatus, status)
+			if tt.wantFound {
+				assert.Contains(t, names, lastName)
+			} else {
+				assert.NotContains(t, names, lastName)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+)
+
+// patientFieldPermissions maps every writable patient field, by JSON name,
+// to the permission required to set it
+var patientFieldPermissions = map[string]models.Permission{
+	"firstName":        models.PermPatientWriteDemographics,
+	"lastName":         models.PermPatientWriteDemographics,
+	"email":            models.PermPatientWriteDemographics,
+	"phone":            models.PermPatientWriteDemographics,
+	"dateOfBirth":      models.PermPatientWriteDemographics,
+	"gender":           models.PermPatientWriteDemographics,
+	"address":          models.PermPatientWriteDemographics,
+	"emergencyContact": models.PermPatientWriteDemographics,
+	"emergencyPhone":   models.PermPatientWriteDemographics,
+	"bloodGroup":       models.PermPatientWriteDemographics,
+	"allergies":        models.PermPatientWriteDemographics,
+	"diagnosis":        models.PermPatientWriteClinical,
+	"notes":            models.PermPatientWriteClinical,
+}
+
+// hasPermission reports whether the current user's role grants perm
+func hasPermission(c *gin.Context, perm models.Permission) bool {
+	role, ok := c.Get("userRole")
+	if !ok {
+		return false
+	}
+	userRole, ok := role.(models.UserRole)
+	return ok && config.HasPermission(userRole, perm)
+}
+
+// providedFields returns the JSON names of the non-empty string fields of a
+// request struct
+func providedFields(req interface{}) []string {
+	v := reflect.Indirect(reflect.ValueOf(req))
+	t := v.Type()
+
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		if v.Field(i).Kind() != reflect.String || v.Field(i).String() == "" {
+			continue
+		}
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// forbiddenPatientFields returns the fields the current user may not write
+func forbiddenPatientFields(c *gin.Context, fields []string) []string {
+	var forbidden []string
+	for _, field := range fields {
+		perm, known := patientFieldPermissions[field]
+		if !known || !hasPermission(c, perm) {
+			forbidden = append(forbidden, field)
+		}
+	}
+	sort.Strings(forbidden)
+	return forbidden
+}
+
+// retiredPatientFields are free-text patient fields replaced by clinical
+// records, with where those are written now
+var retiredPatientFields = map[string]string{
+	"notes":     "Record notes with POST /patients/:id/notes",
+	"diagnosis": "Record diagnoses with POST /patients/:id/diagnoses",
+}
+
+// rejectRetiredPatientFields answers 400 when a retired free-text field of
+// a patient is written. It reports whether it responded.
+func rejectRetiredPatientFields(c *gin.Context, fields []string) bool {
+	for _, field := range fields {
+		if hint, retired := retiredPatientFields[field]; retired {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The " + field + " field is no longer written. " + hint})
+			return true
+		}
+	}
+	return false
+}
+
+// rejectForbiddenPatientFields answers 403 naming the fields the user may
+// not write. It reports whether it responded.
+func rejectForbiddenPatientFields(c *gin.Context, fields []string) bool {
+	forbidden := forbiddenPatientFields(c, fields)
+	if len(forbidden) == 0 {
+		return false
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":           "You are not allowed to modify these fields: " + strings.Join(forbidden, ", "),
+		"forbiddenFields": forbidden,
+	})
+	return true
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"gorm.io/gorm"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required" redact:"secret"`
+}
+
+var errRefreshTokenReused = errors.New("refresh token reuse detected")
+
+// issueSession starts a new login session for the user and returns the
+// first access/refresh token pair for it.
+func issueSession(c *gin.Context, user *models.User) (string, string, error) {
+	sessionID, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:        sessionID,
+		UserID:    user.ID,
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+		ExpiresAt: now.Add(utils.RefreshTokenTTL()),
+	}
+
+	var refreshToken string
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		var err error
+		refreshToken, _, err = createRefreshToken(tx, &session)
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	accessToken, err := utils.GenerateToken(user, session.ID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+func createRefreshToken(tx *gorm.DB, session *models.Session) (string, *models.RefreshToken, error) {
+	token, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	record := models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: session.ExpiresAt,
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", nil, err
+	}
+
+	return token, &record, nil
+}
+
+// revokeSession marks a session as revoked. Revoking an already revoked
+// session is a no-op.
+func revokeSession(tx *gorm.DB, sessionID, note string) error {
+	now := time.Now()
+	return tx.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{"revoked_at": &now, "revoke_note": note}).Error
+}
+
+// revokeUserSessions revokes every active session belonging to a user
+func revokeUserSessions(tx *gorm.DB, userID uint, note string) error {
+	now := time.Now()
+	return tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{"revoked_at": &now, "revoke_note": note}).Error
+}
+
+// revokeOtherSessions revokes every active session of a user except keepID
+func revokeOtherSessions(tx *gorm.DB, userID uint, keepID, note string) error {
+	now := time.Now()
+	return tx.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Updates(map[string]interface{}{"revoked_at": &now, "revoke_note": note}).Error
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. Presenting a refresh token that was already used revokes
+// the whole session.
+func RefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	var stored models.RefreshToken
+	if err := config.DB.Where("token_hash = ?", utils.HashToken(req.RefreshToken)).First(&stored).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	var session models.Session
+	if err := config.DB.First(&session, "id = ?", stored.SessionID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	now := time.Now()
+	if !session.IsActive(now) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired or been revoked"})
+		return
+	}
+
+	if stored.UsedAt != nil {
+		utils.Logger(c).Warn("refresh token reuse detected, revoking session", "sessionId", session.ID, "userId", session.UserID)
+		if err := revokeSession(config.DB, session.ID, "refresh token reuse"); err != nil {
+			utils.Logger(c).Error("failed to revoke session", "sessionId", session.ID, "error", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	if now.After(stored.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has expired"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, session.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if !user.IsActive() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is deactivated"})
+		return
+	}
+
+	var refreshToken string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Only one request may consume a given refresh token
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRefreshTokenReused
+		}
+
+		token, record, err := createRefreshToken(tx, &session)
+		if err != nil {
+			return err
+		}
+		refreshToken = token
+
+		return tx.Model(&models.RefreshToken{}).Where("id = ?", stored.ID).Update("replaced_by", record.ID).Error
+	})
+	if errors.Is(err, errRefreshTokenReused) {
+		utils.Logger(c).Warn("concurrent refresh token reuse detected, revoking session", "sessionId", session.ID, "userId", session.UserID)
+		if err := revokeSession(config.DB, session.ID, "refresh token reuse"); err != nil {
+			utils.Logger(c).Error("failed to revoke session", "sessionId", session.ID, "error", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to rotate refresh token", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	accessToken, err := utils.GenerateToken(&user, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":        accessToken,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(utils.AccessTokenTTL().Seconds()),
+	})
+}
+
+// Logout revokes the session the current access token belongs to
+func Logout(c *gin.Context) {
+	sessionID := c.GetString("sessionID")
+	if sessionID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session not found in context"})
+		return
+	}
+
+	if err := revokeSession(config.DB, sessionID, "logout"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Logged out successfully",
+	})
+}
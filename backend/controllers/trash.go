@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTrashRetention = 30 * 24 * time.Hour
+	patientEmailIndex     = "idx_patients_email_unique"
+)
+
+// trashFields are the demographics shown for deleted patients so that they
+// can be identified before restoring
+var trashFields = []string{"firstName", "lastName", "email", "dateOfBirth"}
+
+var errPatientEmailTaken = errors.New("email belongs to another patient")
+
+// TrashRetention is how long soft-deleted patients are kept before they are
+// purged, configured with PATIENT_TRASH_RETENTION (e.g. "720h")
+func TrashRetention() time.Duration {
+	return utils.DurationFromEnv("PATIENT_TRASH_RETENTION", defaultTrashRetention)
+}
+
+// isUniqueViolation reports whether err is a violation of the named unique
+// index
+func isUniqueViolation(err error, index string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == index
+}
+
+// isDuplicatePatientEmail reports whether err is a violation of the unique
+// email index on active patients
+func isDuplicatePatientEmail(err error) bool {
+	return isUniqueViolation(err, patientEmailIndex)
+}
+
+// GetPatientTrash lists soft-deleted patients, most recently deleted first,
+// with the date each one will be purged. Supports page and limit.
+func GetPatientTrash(c *gin.Context) {
+	page, limit, responded := parsePage(c, 20, 100)
+	if responded {
+		return
+	}
+
+	query := config.DB.Unscoped().Model(&models.Patient{}).Where("deleted_at IS NOT NULL AND merged_into_id IS NULL")
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count deleted patients"})
+		return
+	}
+
+	var patients []models.Patient
+	if err := query.Order("deleted_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&patients).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deleted patients"})
+		return
+	}
+
+	retention := TrashRetention()
+	data := make([]models.PatientSnapshot, len(patients))
+	patientIDs := make([]uint, len(patients))
+	for i := range patients {
+		snapshot, err := projectPatient(&patients[i], trashFields)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deleted patients"})
+			return
+		}
+		snapshot["deletedAt"] = patients[i].DeletedAt.Time
+		snapshot["deletedBy"] = patients[i].DeletedBy
+		snapshot["purgeAfter"] = patients[i].DeletedAt.Time.Add(retention)
+		data[i] = snapshot
+		patientIDs[i] = patients[i].ID
+	}
+
+	if err := recordAudit(c, config.DB, models.AuditRead, trashFields, patientIDs...); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deleted patients"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+		"pagination": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// RestorePatient takes a patient out of the trash. It fails with 409 when
+// another active patient has registered with the same email meanwhile.
+func RestorePatient(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	var patient models.Patient
+	if err := config.DB.Unscoped().Where("deleted_at IS NOT NULL AND merged_into_id IS NULL").First(&patient, patientID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted patient not found"})
+		return
+	}
+	deleted := patient
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if patient.Email != "" {
+			var count int64
+			if err := tx.Model(&models.Patient{}).Where("email = ?", patient.Email).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return errPatientEmailTaken
+			}
+		}
+
+		patient.DeletedAt = gorm.DeletedAt{}
+		patient.DeletedBy = nil
+		patient.UpdatedBy = c.GetUint("userID")
+		patient.Version = deleted.Version + 1
+		if err := tx.Unscoped().Model(&patient).Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"deleted_by": nil,
+			"updated_by": patient.UpdatedBy,
+			"version":    patient.Version,
+		}).Error; err != nil {
+			return err
+		}
+		if err := recordPatientRevision(c, tx, models.AuditRestore, &deleted, &patient); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, models.AuditRestore, nil, patient.ID)
+	})
+	if errors.Is(err, errPatientEmailTaken) || isDuplicatePatientEmail(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another patient with this email exists. Change that patient's email before restoring."})
+		return
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to restore patient", "patientId", patient.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore patient"})
+		return
+	}
+
+	utils.Logger(c).Info("patient restored", "patientId", patient.ID)
+	c.Header("ETag", utils.ETag(patient.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    readablePatient(c, &patient),
+		"message": "Patient restored successfully",
+	})
+}
+
+// PurgeDeletedPatients permanently removes patients that were soft-deleted
+// before the retention period, together with their revisions, related
+// records, break-glass grants and the duplicates merged into them, directly
+// or through other duplicates. Merged duplicates are otherwise kept so that
+// merges can be undone. Audit events are kept and
+// each purge is recorded in the audit log.
+func PurgeDeletedPatients(db *gorm.DB, retention time.Duration) (int, error) {
+	var patientIDs []uint
+	if err := db.Unscoped().Model(&models.Patient{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ? AND merged_into_id IS NULL", time.Now().Add(-retention)).
+		Pluck("id", &patientIDs).Error; err != nil {
+		return 0, err
+	}
+	if len(patientIDs) == 0 {
+		return 0, nil
+	}
+	// Duplicates can have been merged into a duplicate that was merged in
+	// turn, so the merged patients are followed down the whole chain
+	var mergedIDs []uint
+	if err := db.Raw(`WITH RECURSIVE merged AS (
+		SELECT id FROM patients WHERE merged_into_id IN ?
+		UNION
+		SELECT patients.id FROM patients JOIN merged ON patients.merged_into_id = merged.id
+	) SELECT id FROM merged`, patientIDs).Scan(&mergedIDs).Error; err != nil {
+		return 0, err
+	}
+	patientIDs = append(patientIDs, mergedIDs...)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Signed notes and addenda are otherwise kept by their triggers
+		if err := config.AllowNotePurge(tx); err != nil {
+			return err
+		}
+		if err := tx.Where("patient_id IN ?", patientIDs).Delete(&models.PatientRevision{}).Error; err != nil {
+			return err
+		}
+		// Actions reference their grant, so they go before the grants
+		grants := tx.Model(&models.EmergencyAccess{}).Select("id").Where("patient_id IN ?", patientIDs)
+		if err := tx.Where("emergency_access_id IN (?)", grants).Delete(&models.EmergencyAccessAction{}).Error; err != nil {
+			return err
+		}
+		for _, relation := range patientRelations {
+			if err := tx.Where("patient_id IN ?", patientIDs).Delete(relation.model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("target_id IN ? OR source_id IN ?", patientIDs, patientIDs).Delete(&models.PatientMerge{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("id IN ?", patientIDs).Delete(&models.Patient{}).Error; err != nil {
+			return err
+		}
+
+		events := make([]models.AuditEvent, len(patientIDs))
+		for i := range patientIDs {
+			events[i] = models.AuditEvent{
+				ActorRole: "system",
+				Action:    models.AuditPurge,
+				PatientID: &patientIDs[i],
+				Route:     "trash retention",
+			}
+		}
+		return appendAuditEvents(tx, events)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(patientIDs), nil
+}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// trashTestPatient stores a patient that was deleted at deletedAt
+func trashTestPatient(t *testing.T, user *models.User, deletedAt time.Time) models.Patient {
+	t.Helper()
+	patient := createTestPatient(t, user.ID, time.Date(1970, 4, 12, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, config.DB.Model(&patient).Updates(map[string]interface{}{
+		"deleted_at": deletedAt,
+		"deleted_by": user.ID,
+	}).Error)
+	return patient
+}
+
+func TestRestorePatient(t *testing.T) {
+	setupTestDB(t)
+
+	admin := createTestUser(t, models.RoleAdmin)
+	patient := trashTestPatient(t, &admin, time.Now())
+
+	c, w := newTestContext(http.MethodPost, "/patients/1/restore", nil, &admin, patientParams(patient.ID))
+	RestorePatient(c)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var restored models.Patient
+	require.NoError(t, config.DB.First(&restored, patient.ID).Error)
+	assert.Nil(t, restored.DeletedBy)
+	assert.Equal(t, patient.Version+1, restored.Version)
+
+	var revision models.PatientRevision
+	require.NoError(t, config.DB.Where("patient_id = ?", patient.ID).Order("id DESC").First(&revision).Error)
+	assert.Equal(t, restored.Version, revision.Version)
+
+	// Only patients in the trash can be restored
+	c, w = newTestContext(http.MethodPost, "/patients/1/restore", nil, &admin, patientParams(patient.ID))
+	RestorePatient(c)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRestorePatientEmailTaken(t *testing.T) {
+	setupTestDB(t)
+
+	admin := createTestUser(t, models.RoleAdmin)
+	email := fmt.Sprintf("restore-%d@example.com", time.Now().UnixNano())
+	deleted := trashTestPatient(t, &admin, time.Now())
+	require.NoError(t, config.DB.Unscoped().Model(&deleted).Update("email", email).Error)
+	active := createTestPatient(t, admin.ID, time.Date(1985, 7, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, config.DB.Model(&active).Update("email", email).Error)
+
+	c, w := newTestContext(http.MethodPost, "/patients/1/restore", nil, &admin, patientParams(deleted.ID))
+	RestorePatient(c)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestPurgeDeletedPatients(t *testing.T) {
+	setupTestDB(t)
+
+	admin := createTestUser(t, models.RoleAdmin)
+	doctor := createTestUser(t, models.RoleDoctor)
+	expired := trashTestPatient(t, &admin, time.Now().Add(-31*24*time.Hour))
+	recent := trashTestPatient(t, &admin, time.Now().Add(-time.Hour))
+
+	grant := models.EmergencyAccess{
+		PatientID: expired.ID,
+		UserID:    doctor.ID,
+		Reason:    "Patient arrived unresponsive by ambulance",
+		ExpiresAt: time.Now().Add(-30 * 24 * time.Hour),
+	}
+	require.NoError(t, config.DB.Create(&grant).Error)
+	require.NoError(t, config.DB.Create(&models.EmergencyAccessAction{EmergencyAccessID: grant.ID, Action: "read"}).Error)
+	assignTestCareTeam(t, &expired, &doctor)
+	signedAt := time.Now().Add(-40 * 24 * time.Hour)
+	note := models.ClinicalNote{PatientID: expired.ID, AuthorID: &doctor.ID, Title: "Consultation", Assessment: "Head injury", Status: models.NoteSigned, SignedAt: &signedAt}
+	require.NoError(t, config.DB.Create(&note).Error)
+	require.NoError(t, config.DB.Create(&models.NoteAddendum{NoteID: note.ID, PatientID: expired.ID, AuthorID: doctor.ID, Text: "CT normal"}).Error)
+
+	_, err := PurgeDeletedPatients(config.DB, 30*24*time.Hour)
+	require.NoError(t, err)
+
+	err = config.DB.Unscoped().First(&models.Patient{}, expired.ID).Error
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.NoError(t, config.DB.Unscoped().First(&models.Patient{}, recent.ID).Error)
+
+	remaining := []struct {
+		name  string
+		query *gorm.DB
+	}{
+		{name: "grants", query: config.DB.Model(&models.EmergencyAccess{}).Where("id = ?", grant.ID)},
+		{name: "actions", query: config.DB.Model(&models.EmergencyAccessAction{}).Where("emergency_access_id = ?", grant.ID)},
+		{name: "care team", query: config.DB.Model(&models.CareTeamMember{}).Where("patient_id = ?", expired.ID)},
+		{name: "signed notes", query: config.DB.Model(&models.ClinicalNote{}).Where("patient_id = ?", expired.ID)},
+		{name: "addenda", query: config.DB.Model(&models.NoteAddendum{}).Where("patient_id = ?", expired.ID)},
+	}
+	for _, r := range remaining {
+		var count int64
+		require.NoError(t, r.query.Count(&count).Error)
+		assert.Zero(t, count, r.name)
+	}
+
+	// The purge itself is audited
+	var events int64
+	config.DB.Model(&models.AuditEvent{}).Where("patient_id = ? AND action = ?", expired.ID, models.AuditPurge).Count(&events)
+	assert.Equal(t, int64(1), events)
+}
+
+func TestPurgeMergedChain(t *testing.T) {
+	setupTestDB(t)
+
+	admin := createTestUser(t, models.RoleAdmin)
+	// A was merged into B and B into C, which was then deleted
+	c := trashTestPatient(t, &admin, time.Now().Add(-31*24*time.Hour))
+	b := createTestPatient(t, admin.ID, time.Date(1970, 4, 12, 0, 0, 0, 0, time.UTC))
+	a := createTestPatient(t, admin.ID, time.Date(1970, 4, 12, 0, 0, 0, 0, time.UTC))
+	for _, link := range []struct{ source, target *models.Patient }{{&a, &b}, {&b, &c}} {
+		require.NoError(t, config.DB.Model(link.source).Updates(map[string]interface{}{
+			"deleted_at":     time.Now().Add(-60 * 24 * time.Hour),
+			"merged_into_id": link.target.ID,
+		}).Error)
+		merge := models.PatientMerge{TargetID: link.target.ID, SourceID: link.source.ID, MergedBy: admin.ID, MergedAt: time.Now()}
+		require.NoError(t, config.DB.Create(&merge).Error)
+	}
+
+	count, err := PurgeDeletedPatients(config.DB, 30*24*time.Hour)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, count, 3)
+
+	for name, id := range map[string]uint{"A": a.ID, "B": b.ID, "C": c.ID} {
+		err := config.DB.Unscoped().First(&models.Patient{}, id).Error
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound, name)
+	}
+	var merges int64
+	require.NoError(t, config.DB.Model(&models.PatientMerge{}).Where("source_id IN ?", []uint{a.ID, b.ID}).Count(&merges).Error)
+	assert.Zero(t, merges)
+}
+
+func TestInvalidPageRejected(t *testing.T) {
+	admin := models.User{ID: 1, Role: models.RoleAdmin}
+	handlers := map[string]gin.HandlerFunc{
+		"trash":     GetPatientTrash,
+		"audit":     GetAuditEvents,
+		"emergency": GetEmergencyAccessReviews,
+	}
+	for name, handler := range handlers {
+		for _, query := range []string{"page=0", "page=two", "limit=0", "limit=1000", "limit=ten"} {
+			t.Run(name+" "+query, func(t *testing.T) {
+				c, w := newTestContext(http.MethodGet, "/admin/list?"+query, nil, &admin, nil)
+				handler(c)
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+			})
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type CreateUserRequest struct {
+	Name  string          `json:"name" binding:"required"`
+	Email string          `json:"email" binding:"required,email"`
+	Role  models.UserRole `json:"role" binding:"required"`
+	// Trainee marks doctors whose notes need a co-signature
+	Trainee bool `json:"trainee"`
+	// Password is optional. Without it the user gets a reset token to set their own.
+	Password string `json:"password" redact:"secret"`
+}
+
+type UpdateUserRequest struct {
+	Name    string `json:"name"`
+	Email   string `json:"email" binding:"omitempty,email"`
+	Trainee *bool  `json:"trainee"`
+}
+
+type ChangeRoleRequest struct {
+	Role models.UserRole `json:"role" binding:"required"`
+}
+
+var errLastAdmin = errors.New("cannot remove the last active admin")
+
+func parseUserID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// ensureOtherActiveAdmin fails when userID is the only active admin
+func ensureOtherActiveAdmin(tx *gorm.DB, userID uint) error {
+	var count int64
+	if err := tx.Model(&models.User{}).
+		Where("role = ? AND deactivated_at IS NULL AND id <> ?", models.RoleAdmin, userID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errLastAdmin
+	}
+	return nil
+}
+
+// CreateUser creates a staff account
+func CreateUser(c *gin.Context) {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !req.Role.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+	if req.Trainee && req.Role != models.RoleDoctor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only doctors can be trainees"})
+		return
+	}
+
+	req.Email = normalizeEmail(req.Email)
+	var existing models.User
+	if err := config.DB.Unscoped().Where("email = ?", req.Email).First(&existing).Error; err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A user with this email already exists"})
+		return
+	}
+
+	password := req.Password
+	if password != "" {
+		if err := utils.CurrentPasswordPolicy().Validate(password, req.Name, req.Email); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		// Unguessable placeholder until the user sets a password with the reset token
+		random, err := utils.GenerateOpaqueToken(32)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+			return
+		}
+		password = random
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	user := models.User{
+		Name:         req.Name,
+		Email:        req.Email,
+		PasswordHash: string(hash),
+		Role:         req.Role,
+		Trainee:      req.Trainee,
+	}
+
+	var resetToken string
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if req.Password != "" {
+			return nil
+		}
+		var err error
+		resetToken, _, err = issuePasswordReset(tx, user.ID, c.GetUint("userID"))
+		return err
+	})
+	if err != nil {
+		utils.Logger(c).Error("failed to create user", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	utils.Logger(c).Info("user created", "targetUserId", user.ID, "role", user.Role)
+
+	response := gin.H{
+		"success": true,
+		"data":    user,
+		"message": "User created successfully",
+	}
+	if resetToken != "" {
+		response["passwordResetToken"] = resetToken
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetUsers lists staff accounts. Supports page, limit, role, status
+// (active/deactivated) and search query parameters.
+func GetUsers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := config.DB.Model(&models.User{})
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+	switch c.Query("status") {
+	case "active":
+		query = query.Where("deactivated_at IS NULL")
+	case "deactivated":
+		query = query.Where("deactivated_at IS NOT NULL")
+	}
+	if search := strings.ToLower(c.Query("search")); search != "" {
+		query = query.Where("LOWER(name) LIKE ? OR LOWER(email) LIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count users"})
+		return
+	}
+
+	var users []models.User
+	if err := query.Order("id").Offset((page - 1) * limit).Limit(limit).Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": users,
+		"pagination": gin.H{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": (int(total) + limit - 1) / limit,
+		},
+	})
+}
+
+// GetUser returns one staff account
+func GetUser(c *gin.Context) {
+	userID, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+// UpdateUser changes a user's name or email
+func UpdateUser(c *gin.Context) {
+	userID, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if req.Name != "" {
+		user.Name = req.Name
+	}
+	if req.Email != "" {
+		email := normalizeEmail(req.Email)
+		var existing models.User
+		if err := config.DB.Unscoped().Where("email = ? AND id <> ?", email, user.ID).First(&existing).Error; err == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A user with this email already exists"})
+			return
+		}
+		user.Email = email
+	}
+	if req.Trainee != nil {
+		if *req.Trainee && user.Role != models.RoleDoctor {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only doctors can be trainees"})
+			return
+		}
+		user.Trainee = *req.Trainee
+	}
+
+	if err := config.DB.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    user,
+		"message": "User updated successfully",
+	})
+}
+
+// ChangeUserRole assigns a new role. The last active admin cannot be demoted.
+func ChangeUserRole(c *gin.Context) {
+	userID, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	var req ChangeRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !req.Role.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	var user models.User
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		if user.Role == models.RoleAdmin && req.Role != models.RoleAdmin {
+			if err := ensureOtherActiveAdmin(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&user).Update("role", req.Role).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if errors.Is(err, errLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change role"})
+		return
+	}
+
+	utils.Logger(c).Info("user role changed", "targetUserId", user.ID, "role", req.Role)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    user,
+		"message": "Role changed successfully",
+	})
+}
+
+// DeactivateUser disables an account and signs it out everywhere. Existing
+// access tokens stop working on the next request.
+func DeactivateUser(c *gin.Context) {
+	userID, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	if userID == c.GetUint("userID") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot deactivate your own account"})
+		return
+	}
+
+	var user models.User
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		if !user.IsActive() {
+			return nil
+		}
+		if user.Role == models.RoleAdmin {
+			if err := ensureOtherActiveAdmin(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		if err := tx.Model(&user).Update("deactivated_at", &now).Error; err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID, "account deactivated")
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if errors.Is(err, errLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate user"})
+		return
+	}
+
+	utils.Logger(c).Info("user deactivated", "targetUserId", user.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    user,
+		"message": "User deactivated successfully",
+	})
+}
+
+// ActivateUser re-enables a deactivated account
+func ActivateUser(c *gin.Context) {
+	userID, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := config.DB.Model(&user).Update("deactivated_at", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate user"})
+		return
+	}
+
+	utils.Logger(c).Info("user activated", "targetUserId", user.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    user,
+		"message": "User activated successfully",
+	})
+}
@@ -0,0 +1,430 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/medibridge/utils"
+	"gorm.io/gorm"
+)
+
+// VitalObservationRequest is one measurement. Unit defaults to the
+// canonical unit of the type.
+type VitalObservationRequest struct {
+	Type  models.VitalType `json:"type" binding:"required"`
+	Value *float64         `json:"value" binding:"required"`
+	Unit  string           `json:"unit"`
+}
+
+type RecordVitalsRequest struct {
+	// TakenAt defaults to now
+	TakenAt string `json:"takenAt"`
+	// EncounterID links the observations to an open encounter of the
+	// patient with the calling doctor
+	EncounterID  *uint                     `json:"encounterId"`
+	Observations []VitalObservationRequest `json:"observations" binding:"required,min=1,max=20,dive"`
+}
+
+// vitalPoint is one observation in a vital sign series
+type vitalPoint struct {
+	ID          uint             `json:"id"`
+	Value       float64          `json:"value"`
+	Flag        models.VitalFlag `json:"flag"`
+	TakenAt     time.Time        `json:"takenAt"`
+	EncounterID *uint            `json:"encounterId"`
+}
+
+// vitalSeries is the observations of one vital sign type, oldest first
+type vitalSeries struct {
+	Type   models.VitalType `json:"type"`
+	Unit   string           `json:"unit"`
+	Points []vitalPoint     `json:"points"`
+}
+
+// imperialUnits are the units of the series returned with units=imperial
+var imperialUnits = map[models.VitalType]string{
+	models.VitalTemperature: "°F",
+	models.VitalHeight:      "in",
+	models.VitalWeight:      "lb",
+}
+
+const adultAge = 18
+
+// withBMI adds a BMI observation to observations with a height or weight
+// of an adult. The other measurement comes from the same observations or
+// the patient's latest one before takenAt.
+func withBMI(tx *gorm.DB, patient *models.Patient, takenAt time.Time, observations []models.VitalSign) ([]models.VitalSign, error) {
+	if models.AgeAt(patient.DateOfBirth, takenAt) < adultAge {
+		return observations, nil
+	}
+
+	var height, weight *float64
+	for i := range observations {
+		switch observations[i].Type {
+		case models.VitalHeight:
+			height = &observations[i].Value
+		case models.VitalWeight:
+			weight = &observations[i].Value
+		case models.VitalBMI:
+			return observations, nil
+		}
+	}
+	if height == nil && weight == nil {
+		return observations, nil
+	}
+
+	latest := func(t models.VitalType) (*float64, error) {
+		var observation models.VitalSign
+		err := tx.Where("patient_id = ? AND type = ? AND taken_at <= ?", patient.ID, t, takenAt).
+			Order("taken_at DESC, id DESC").First(&observation).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &observation.Value, nil
+	}
+	var err error
+	if height == nil {
+		height, err = latest(models.VitalHeight)
+	} else if weight == nil {
+		weight, err = latest(models.VitalWeight)
+	}
+	if err != nil || height == nil || weight == nil {
+		return observations, err
+	}
+
+	return append(observations, models.VitalSign{
+		Type:  models.VitalBMI,
+		Value: models.BMI(*height, *weight),
+		Unit:  models.VitalBMI.Unit(),
+	}), nil
+}
+
+// vitalsRecord is observations of a patient taken at the same time,
+// optionally during an encounter
+type vitalsRecord struct {
+	patient      *models.Patient
+	encounterID  *uint
+	takenAt      time.Time
+	observations []models.VitalSign
+}
+
+// save adds a calculated BMI to the observations, flags them against the
+// normal ranges for the patient's age when taken, saves them and records
+// the audit entry
+func (r *vitalsRecord) save(c *gin.Context, tx *gorm.DB) error {
+	if len(r.observations) == 0 {
+		return nil
+	}
+	observations, err := withBMI(tx, r.patient, r.takenAt, r.observations)
+	if err != nil {
+		return err
+	}
+
+	age := models.AgeAt(r.patient.DateOfBirth, r.takenAt)
+	for i := range observations {
+		observations[i].PatientID = r.patient.ID
+		observations[i].EncounterID = r.encounterID
+		observations[i].TakenAt = r.takenAt
+		observations[i].RecordedBy = c.GetUint("userID")
+		observations[i].Flag = models.FlagVital(observations[i].Type, observations[i].Value, age)
+	}
+	if err := tx.Create(&observations).Error; err != nil {
+		return err
+	}
+	r.observations = observations
+	return recordAudit(c, tx, models.AuditCreate, []string{"vitals"}, r.patient.ID)
+}
+
+// legacyEncounterVitals is an encounter with the vitals_* columns
+// encounters had before vital sign observations were their only store
+type legacyEncounterVitals struct {
+	ID        uint
+	PatientID uint
+	DoctorID  uint
+	StartedAt time.Time
+	Vitals    models.EncounterVitals `gorm:"embedded;embeddedPrefix:vitals_"`
+}
+
+// legacyEncounterVitalColumns are the columns the vitals of encounters
+// were stored in
+var legacyEncounterVitalColumns = []string{
+	"vitals_systolic_bp", "vitals_diastolic_bp", "vitals_pulse", "vitals_temperature", "vitals_spo2", "vitals_height_cm", "vitals_weight_kg",
+}
+
+// ConvertEncounterVitals records the vitals_* columns of encounters as
+// observations taken when the encounter started, with a BMI for adults,
+// and drops the columns. Encounters that already have observations were
+// recorded twice and are skipped. It returns the number of encounters
+// converted.
+func ConvertEncounterVitals(db *gorm.DB) (int, error) {
+	if !db.Migrator().HasColumn(&models.Encounter{}, legacyEncounterVitalColumns[0]) {
+		return 0, nil
+	}
+
+	var encounters []legacyEncounterVitals
+	if err := db.Table("encounters").Where(`(vitals_systolic_bp IS NOT NULL OR vitals_diastolic_bp IS NOT NULL OR vitals_pulse IS NOT NULL OR
+		vitals_temperature IS NOT NULL OR vitals_spo2 IS NOT NULL OR vitals_height_cm IS NOT NULL OR vitals_weight_kg IS NOT NULL)`).
+		Where("NOT EXISTS (SELECT 1 FROM vital_signs WHERE vital_signs.encounter_id = encounters.id)").
+		Find(&encounters).Error; err != nil {
+		return 0, err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := range encounters {
+			var patient models.Patient
+			if err := tx.Unscoped().Select("id", "date_of_birth").First(&patient, encounters[i].PatientID).Error; err != nil {
+				return err
+			}
+			observations, err := withBMI(tx, &patient, encounters[i].StartedAt, encounters[i].Vitals.Observations())
+			if err != nil {
+				return err
+			}
+			age := models.AgeAt(patient.DateOfBirth, encounters[i].StartedAt)
+			for j := range observations {
+				observations[j].PatientID = patient.ID
+				observations[j].EncounterID = &encounters[i].ID
+				observations[j].TakenAt = encounters[i].StartedAt
+				observations[j].RecordedBy = encounters[i].DoctorID
+				observations[j].Flag = models.FlagVital(observations[j].Type, observations[j].Value, age)
+			}
+			if err := tx.Create(&observations).Error; err != nil {
+				return err
+			}
+		}
+		for _, column := range legacyEncounterVitalColumns {
+			if err := tx.Migrator().DropColumn(&models.Encounter{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(encounters), nil
+}
+
+// respondVitalError answers 400 for a value of t in unit that ConvertVital
+// did not accept
+func respondVitalError(c *gin.Context, t models.VitalType, unit string, err error) {
+	switch {
+	case errors.Is(err, models.ErrVitalUnknownType):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type. Use systolic_bp, diastolic_bp, pulse, temperature, spo2, height or weight"})
+	case errors.Is(err, models.ErrVitalUnit):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unit " + unit + " is not accepted for " + string(t)})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Implausible " + string(t) + " value"})
+	}
+}
+
+// parseVitalTypes parses a comma-separated list of vital sign types. An
+// empty list is all types.
+func parseVitalTypes(value string) ([]models.VitalType, bool) {
+	if value == "" {
+		return nil, true
+	}
+	var types []models.VitalType
+	for _, name := range strings.Split(value, ",") {
+		t := models.VitalType(strings.TrimSpace(name))
+		if !t.Valid() {
+			return nil, false
+		}
+		types = append(types, t)
+	}
+	return types, true
+}
+
+// GetVitals returns a patient's vital signs as one time series per type,
+// oldest observation first, for charting. Supports type (comma-separated),
+// from and to (YYYY-MM-DD or RFC 3339) on the time taken, encounterId, and
+// units=imperial for °F, inches and pounds.
+func GetVitals(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	types, ok := parseVitalTypes(c.Query("type"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type. Use systolic_bp, diastolic_bp, pulse, temperature, spo2, height, weight or bmi"})
+		return
+	}
+	query := config.DB
+	if len(types) > 0 {
+		query = query.Where("type IN ?", types)
+	}
+	for _, param := range []string{"from", "to"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := parseTimeFilter(value, param == "to")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ". Use YYYY-MM-DD or RFC 3339"})
+			return
+		}
+		if param == "from" {
+			query = query.Where("taken_at >= ?", t)
+		} else {
+			query = query.Where("taken_at <= ?", t)
+		}
+	}
+	if value := c.Query("encounterId"); value != "" {
+		encounterID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid encounterId"})
+			return
+		}
+		query = query.Where("encounter_id = ?", encounterID)
+	}
+	units := c.DefaultQuery("units", "metric")
+	if units != "metric" && units != "imperial" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid units. Use metric or imperial"})
+		return
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+
+	var observations []models.VitalSign
+	if err := query.Where("patient_id = ?", patient.ID).Order("taken_at, id").Find(&observations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vital signs"})
+		return
+	}
+	if err := recordAudit(c, config.DB, models.AuditRead, []string{"vitals"}, patient.ID); err != nil {
+		utils.Logger(c).Error("failed to record audit event", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vital signs"})
+		return
+	}
+
+	byType := make(map[models.VitalType]*vitalSeries)
+	for _, observation := range observations {
+		unit := ""
+		if units == "imperial" {
+			unit = imperialUnits[observation.Type]
+		}
+		value, name := models.DisplayVital(observation.Type, observation.Value, unit)
+		s := byType[observation.Type]
+		if s == nil {
+			s = &vitalSeries{Type: observation.Type, Unit: name}
+			byType[observation.Type] = s
+		}
+		s.Points = append(s.Points, vitalPoint{
+			ID:          observation.ID,
+			Value:       value,
+			Flag:        observation.Flag,
+			TakenAt:     observation.TakenAt,
+			EncounterID: observation.EncounterID,
+		})
+	}
+	series := []vitalSeries{}
+	for _, t := range models.VitalTypes {
+		if s := byType[t]; s != nil {
+			series = append(series, *s)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": series})
+}
+
+// RecordVitals records vital sign observations of a patient. Values are
+// converted to the canonical unit of their type, a BMI is calculated from
+// the height and weight of adults, and each observation is flagged against
+// the normal range for the patient's age.
+func RecordVitals(c *gin.Context) {
+	patientID, ok := parsePatientID(c)
+	if !ok {
+		return
+	}
+
+	var req RecordVitalsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	takenAt := time.Now()
+	if req.TakenAt != "" {
+		t, err := parseEncounterTime(req.TakenAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid takenAt. Use a past RFC 3339 time"})
+			return
+		}
+		takenAt = t
+	}
+
+	observations := make([]models.VitalSign, 0, len(req.Observations))
+	seen := make(map[models.VitalType]bool)
+	for _, o := range req.Observations {
+		if o.Type == models.VitalBMI {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bmi is calculated from height and weight"})
+			return
+		}
+		if seen[o.Type] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only one " + string(o.Type) + " observation can be recorded at a time"})
+			return
+		}
+		seen[o.Type] = true
+		value, err := models.ConvertVital(o.Type, *o.Value, o.Unit)
+		if err != nil {
+			respondVitalError(c, o.Type, o.Unit, err)
+			return
+		}
+		observations = append(observations, models.VitalSign{Type: o.Type, Value: value, Unit: o.Type.Unit()})
+	}
+
+	var patient models.Patient
+	if !findScopedPatient(c, patientID, &patient) {
+		return
+	}
+
+	record := vitalsRecord{patient: &patient, takenAt: takenAt, observations: observations}
+	var err error
+	if req.EncounterID != nil {
+		var encounter models.Encounter
+		if err := config.DB.Where("patient_id = ?", patient.ID).First(&encounter, *req.EncounterID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "encounterId is not an encounter of this patient"})
+			return
+		}
+		if !ownsEncounter(c, &encounter) {
+			return
+		}
+		if takenAt.Before(encounter.StartedAt) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "takenAt cannot be before the encounter started"})
+			return
+		}
+		record.encounterID = &encounter.ID
+		err = saveOpenEncounter(c, &encounter, &record)
+		if errors.Is(err, errEncounterClosed) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Encounter is closed and can no longer be changed"})
+			return
+		}
+	} else {
+		err = config.DB.Transaction(func(tx *gorm.DB) error {
+			return record.save(c, tx)
+		})
+	}
+	if err != nil {
+		utils.Logger(c).Error("failed to record vital signs", "patientId", patient.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record vital signs"})
+		return
+	}
+
+	utils.Logger(c).Info("vital signs recorded", "patientId", patient.ID, "count", len(record.observations))
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    record.observations,
+		"message": "Vital signs recorded successfully",
+	})
+}
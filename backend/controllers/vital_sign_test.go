@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/medibridge/config"
+	"github.com/medibridge/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncounterVitalObservations(t *testing.T) {
+	pulse := 72
+	temperature := 100.4
+	weight := 154.0
+	tests := []struct {
+		name       string
+		req        EncounterVitalsRequest
+		want       map[models.VitalType]float64
+		wantStatus int
+	}{
+		{
+			name: "canonical units",
+			req:  EncounterVitalsRequest{Pulse: &pulse},
+			want: map[models.VitalType]float64{models.VitalPulse: 72},
+		},
+		{
+			name: "imperial units",
+			req:  EncounterVitalsRequest{Temperature: &temperature, WeightKg: &weight, Units: map[models.VitalType]string{models.VitalTemperature: "°F", models.VitalWeight: "lb"}},
+			want: map[models.VitalType]float64{models.VitalTemperature: 38, models.VitalWeight: 69.85},
+		},
+		{
+			name:       "fahrenheit without its unit",
+			req:        EncounterVitalsRequest{Temperature: &temperature},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unit of another type",
+			req:        EncounterVitalsRequest{WeightKg: &weight, Units: map[models.VitalType]string{models.VitalWeight: "in"}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unit of bmi",
+			req:        EncounterVitalsRequest{Pulse: &pulse, Units: map[models.VitalType]string{models.VitalBMI: "kg/m2"}},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newTestContext(http.MethodPost, "/patients/1/encounters", nil, &models.User{ID: 1, Role: models.RoleDoctor}, nil)
+			observations, ok := vitalObservations(c, &tt.req)
+			if tt.wantStatus != 0 {
+				assert.False(t, ok)
+				assert.Equal(t, tt.wantStatus, w.Code)
+				return
+			}
+			require.True(t, ok)
+			got := make(map[models.VitalType]float64)
+			for _, observation := range observations {
+				got[observation.Type] = observation.Value
+			}
+			assert.Len(t, got, len(tt.want))
+			for typ, value := range tt.want {
+				assert.InDelta(t, value, got[typ], 0.01, typ)
+			}
+		})
+	}
+}
+
+// recordTestVital stores an observation of a patient taken at takenAt
+func recordTestVital(t *testing.T, patient *models.Patient, encounterID *uint, typ models.VitalType, value float64, takenAt time.Time) {
+	t.Helper()
+	observation := models.VitalSign{PatientID: patient.ID, EncounterID: encounterID, Type: typ, Value: value, Unit: typ.Unit(), TakenAt: takenAt, RecordedBy: patient.CreatedBy}
+	require.NoError(t, config.DB.Create(&observation).Error)
+}
+
+func TestGetVitals(t *testing.T) {
+	setupTestDB(t)
+
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, doctor.ID, time.Date(1960, 1, 15, 0, 0, 0, 0, time.UTC))
+	assignTestCareTeam(t, &patient, &doctor)
+	encounter := openTestEncounter(t, &doctor, &patient)
+
+	recordTestVital(t, &patient, nil, models.VitalTemperature, 37, time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	recordTestVital(t, &patient, nil, models.VitalTemperature, 38.5, time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC))
+	recordTestVital(t, &patient, nil, models.VitalWeight, 70, time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC))
+	recordTestVital(t, &patient, &encounter.ID, models.VitalPulse, 80, time.Now())
+
+	tests := []struct {
+		name   string
+		query  string
+		want   map[models.VitalType][]float64
+		units  map[models.VitalType]string
+		status int
+	}{
+		{
+			name:  "all",
+			query: "",
+			want:  map[models.VitalType][]float64{models.VitalTemperature: {37, 38.5}, models.VitalWeight: {70}, models.VitalPulse: {80}},
+		},
+		{
+			name:  "type",
+			query: "?type=temperature,weight",
+			want:  map[models.VitalType][]float64{models.VitalTemperature: {37, 38.5}, models.VitalWeight: {70}},
+		},
+		{
+			name:  "from and to",
+			query: "?from=2024-03-05&to=2024-03-10",
+			want:  map[models.VitalType][]float64{models.VitalTemperature: {38.5}, models.VitalWeight: {70}},
+		},
+		{
+			name:  "encounter",
+			query: "?encounterId=" + fmt.Sprint(encounter.ID),
+			want:  map[models.VitalType][]float64{models.VitalPulse: {80}},
+		},
+		{
+			name:  "imperial",
+			query: "?type=temperature,weight&units=imperial",
+			want:  map[models.VitalType][]float64{models.VitalTemperature: {98.6, 101.3}, models.VitalWeight: {154.32}},
+			units: map[models.VitalType]string{models.VitalTemperature: "°F", models.VitalWeight: "lb"},
+		},
+		{name: "invalid type", query: "?type=glucose", status: http.StatusBadRequest},
+		{name: "invalid units", query: "?units=kelvin", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newTestContext(http.MethodGet, "/patients/1/vitals"+tt.query, nil, &doctor, patientParams(patient.ID))
+			GetVitals(c)
+			if tt.status != 0 {
+				assert.Equal(t, tt.status, w.Code)
+				return
+			}
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var body struct {
+				Data []vitalSeries `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Len(t, body.Data, len(tt.want))
+			for _, series := range body.Data {
+				want := tt.want[series.Type]
+				require.Len(t, series.Points, len(want), series.Type)
+				for i, point := range series.Points {
+					assert.InDelta(t, want[i], point.Value, 0.01, series.Type)
+				}
+				unit := series.Type.Unit()
+				if tt.units[series.Type] != "" {
+					unit = tt.units[series.Type]
+				}
+				assert.Equal(t, unit, series.Unit)
+			}
+		})
+	}
+}
+
+func TestRecordVitalsInOthersEncounter(t *testing.T) {
+	setupTestDB(t)
+
+	doctor := createTestUser(t, models.RoleDoctor)
+	colleague := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, doctor.ID, time.Date(1975, 4, 20, 0, 0, 0, 0, time.UTC))
+	assignTestCareTeam(t, &patient, &doctor)
+	assignTestCareTeam(t, &patient, &colleague)
+	encounter := openTestEncounter(t, &doctor, &patient)
+
+	pulse := 88.0
+	req := RecordVitalsRequest{EncounterID: &encounter.ID, Observations: []VitalObservationRequest{{Type: models.VitalPulse, Value: &pulse}}}
+	c, w := newTestContext(http.MethodPost, "/patients/1/vitals", req, &colleague, patientParams(patient.ID))
+	RecordVitals(c)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	c, w = newTestContext(http.MethodPost, "/patients/1/vitals", req, &doctor, patientParams(patient.ID))
+	RecordVitals(c)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	// The encounter's vitals are derived from its observations
+	c, w = newTestContext(http.MethodGet, "/patients/1/encounters/1", nil, &doctor, encounterParams(patient.ID, encounter.ID))
+	GetEncounter(c)
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data models.Encounter `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.NotNil(t, body.Data.Vitals.Pulse)
+	assert.Equal(t, 88, *body.Data.Vitals.Pulse)
+}
+
+func TestConvertEncounterVitals(t *testing.T) {
+	setupTestDB(t)
+
+	doctor := createTestUser(t, models.RoleDoctor)
+	patient := createTestPatient(t, doctor.ID, time.Date(1968, 9, 3, 0, 0, 0, 0, time.UTC))
+	encounter := openTestEncounter(t, &doctor, &patient)
+	for _, column := range legacyEncounterVitalColumns {
+		kind := "bigint"
+		if column == "vitals_temperature" || column == "vitals_height_cm" || column == "vitals_weight_kg" {
+			kind = "double precision"
+		}
+		require.NoError(t, config.DB.Exec("ALTER TABLE encounters ADD COLUMN IF NOT EXISTS "+column+" "+kind).Error)
+	}
+	require.NoError(t, config.DB.Exec("UPDATE encounters SET vitals_height_cm = 180, vitals_weight_kg = 81 WHERE id = ?", encounter.ID).Error)
+
+	_, err := ConvertEncounterVitals(config.DB)
+	require.NoError(t, err)
+
+	var observations []models.VitalSign
+	require.NoError(t, config.DB.Where("encounter_id = ?", encounter.ID).Find(&observations).Error)
+	values := make(map[models.VitalType]float64)
+	for _, observation := range observations {
+		values[observation.Type] = observation.Value
+	}
+	assert.Equal(t, map[models.VitalType]float64{models.VitalHeight: 180, models.VitalWeight: 81, models.VitalBMI: 25}, values)
+	assert.False(t, config.DB.Migrator().HasColumn(&models.Encounter{}, "vitals_weight_kg"), "the old columns are dropped")
+}
@@ -3,8 +3,10 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/medibridge/config"
 	"github.com/medibridge/models"
 	"github.com/medibridge/utils"
 )
@@ -32,8 +34,27 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Tokens stay bound to their login session so logout and
+		// refresh-token reuse take effect before the token expires
+		var session models.Session
+		if claims.SessionID == "" || config.DB.First(&session, "id = ?", claims.SessionID).Error != nil ||
+			!session.IsActive(time.Now()) || session.UserID != claims.UserID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired or been revoked"})
+			c.Abort()
+			return
+		}
+
+		// Load the account so deactivation and role changes apply immediately
+		var user models.User
+		if err := config.DB.Select("id", "role", "deactivated_at").First(&user, claims.UserID).Error; err != nil || !user.IsActive() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is deactivated"})
+			c.Abort()
+			return
+		}
+
 		c.Set("userID", claims.UserID)
-		c.Set("userRole", claims.Role)
+		c.Set("userRole", user.Role)
+		c.Set("sessionID", claims.SessionID)
 		c.Next()
 	}
 }
@@ -58,4 +79,31 @@ func RoleMiddleware(roles ...models.UserRole) gin.HandlerFunc {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
 		c.Abort()
 	}
-} 
\ No newline at end of file
+}
+
+// RequirePermission allows the request only when the user's role grants
+// every listed permission
+func RequirePermission(perms ...models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRole, exists := c.Get("userRole")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found"})
+			c.Abort()
+			return
+		}
+
+		role := userRole.(models.UserRole)
+		for _, perm := range perms {
+			if !config.HasPermission(role, perm) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error":             "Insufficient permissions",
+					"missingPermission": perm,
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/medibridge/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		role       models.UserRole
+		perms      []models.Permission
+		wantStatus int
+	}{
+		{"Receptionist can delete patients", models.RoleReceptionist, []models.Permission{models.PermPatientDelete}, http.StatusOK},
+		{"Doctor cannot delete patients", models.RoleDoctor, []models.Permission{models.PermPatientDelete}, http.StatusForbidden},
+		{"Doctor can write clinical fields", models.RoleDoctor, []models.Permission{models.PermPatientRead, models.PermPatientWriteClinical}, http.StatusOK},
+		{"Admin cannot read patients", models.RoleAdmin, []models.Permission{models.PermPatientRead}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", func(c *gin.Context) {
+				c.Set("userRole", tt.role)
+				c.Next()
+			}, RequirePermission(tt.perms...), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusForbidden {
+				var response map[string]string
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Contains(t, response, "missingPermission")
+			}
+		})
+	}
+}
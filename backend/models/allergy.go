@@ -0,0 +1,145 @@
+package models
+
+import (
+	"time"
+)
+
+// AllergyCategory is what kind of substance a patient reacts to
+type AllergyCategory string
+
+const (
+	AllergyDrug        AllergyCategory = "drug"
+	AllergyFood        AllergyCategory = "food"
+	AllergyEnvironment AllergyCategory = "environment"
+	// AllergyUnstructured marks entries converted from the old free-text
+	// allergies field. They still need to be coded by a clinician.
+	AllergyUnstructured AllergyCategory = "unstructured"
+)
+
+// Valid reports whether c is a category that can be recorded. Unstructured
+// entries are only created by the conversion of free text.
+func (c AllergyCategory) Valid() bool {
+	switch c {
+	case AllergyDrug, AllergyFood, AllergyEnvironment:
+		return true
+	}
+	return false
+}
+
+// AllergySeverity is how severe a patient's reaction is
+type AllergySeverity string
+
+const (
+	AllergyMild     AllergySeverity = "mild"
+	AllergyModerate AllergySeverity = "moderate"
+	AllergySevere   AllergySeverity = "severe"
+)
+
+// rank orders severities from unknown (0) to severe (3)
+func (s AllergySeverity) rank() int {
+	switch s {
+	case AllergyMild:
+		return 1
+	case AllergyModerate:
+		return 2
+	case AllergySevere:
+		return 3
+	}
+	return 0
+}
+
+// Valid reports whether s is a known severity. An empty severity means
+// unknown.
+func (s AllergySeverity) Valid() bool {
+	return s == "" || s.rank() > 0
+}
+
+// AllergyStatus is whether an allergy is still relevant
+type AllergyStatus string
+
+const (
+	AllergyActive         AllergyStatus = "active"
+	AllergyInactive       AllergyStatus = "inactive"
+	AllergyResolved       AllergyStatus = "resolved"
+	AllergyEnteredInError AllergyStatus = "entered_in_error"
+)
+
+// Valid reports whether s is a known status
+func (s AllergyStatus) Valid() bool {
+	switch s {
+	case AllergyActive, AllergyInactive, AllergyResolved, AllergyEnteredInError:
+		return true
+	}
+	return false
+}
+
+// Allergy is one recorded allergy or intolerance of a patient
+type Allergy struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	PatientID  uint            `gorm:"not null;index" json:"patientId"`
+	Substance  string          `gorm:"not null" json:"substance" redact:"phi"`
+	Category   AllergyCategory `gorm:"not null" json:"category"`
+	Reaction   string          `json:"reaction" redact:"phi"`
+	Severity   AllergySeverity `json:"severity"`
+	Status     AllergyStatus   `gorm:"not null;default:active" json:"status"`
+	Onset      *time.Time      `json:"onset"`
+	RecordedBy uint            `gorm:"not null" json:"recordedBy"`
+	UpdatedBy  uint            `gorm:"not null" json:"updatedBy"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	UpdatedAt  time.Time       `json:"updatedAt"`
+}
+
+// AllergyBannerItem is one active allergy shown in the allergy banner
+type AllergyBannerItem struct {
+	Substance string          `json:"substance" redact:"phi"`
+	Category  AllergyCategory `json:"category"`
+	Severity  AllergySeverity `json:"severity"`
+	Reaction  string          `json:"reaction" redact:"phi"`
+}
+
+// AllergyBanner summarises a patient's active allergies for display at the
+// top of the record, most severe first
+type AllergyBanner struct {
+	Count           int                 `json:"count"`
+	HighestSeverity AllergySeverity     `json:"highestSeverity"`
+	DrugAllergies   int                 `json:"drugAllergies"`
+	Unstructured    int                 `json:"unstructured"`
+	Items           []AllergyBannerItem `json:"items"`
+}
+
+// NewAllergyBanner builds the banner from a patient's allergies, ignoring
+// those that are not active
+func NewAllergyBanner(allergies []Allergy) AllergyBanner {
+	banner := AllergyBanner{Items: []AllergyBannerItem{}}
+	for _, allergy := range allergies {
+		if allergy.Status != AllergyActive {
+			continue
+		}
+		banner.Count++
+		if allergy.Severity.rank() > banner.HighestSeverity.rank() {
+			banner.HighestSeverity = allergy.Severity
+		}
+		switch allergy.Category {
+		case AllergyDrug:
+			banner.DrugAllergies++
+		case AllergyUnstructured:
+			banner.Unstructured++
+		}
+
+		item := AllergyBannerItem{
+			Substance: allergy.Substance,
+			Category:  allergy.Category,
+			Severity:  allergy.Severity,
+			Reaction:  allergy.Reaction,
+		}
+		// Insert after items that are at least as severe
+		i := len(banner.Items)
+		for i > 0 && banner.Items[i-1].Severity.rank() < item.Severity.rank() {
+			i--
+		}
+		banner.Items = append(banner.Items, AllergyBannerItem{})
+		copy(banner.Items[i+1:], banner.Items[i:])
+		banner.Items[i] = item
+	}
+	return banner
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAllergyBanner(t *testing.T) {
+	allergies := []Allergy{
+		{Substance: "Peanuts", Category: AllergyFood, Severity: AllergyModerate, Status: AllergyActive},
+		{Substance: "Latex", Category: AllergyEnvironment, Status: AllergyActive},
+		{Substance: "Penicillin", Category: AllergyDrug, Severity: AllergySevere, Reaction: "Anaphylaxis", Status: AllergyActive},
+		{Substance: "Codeine", Category: AllergyDrug, Severity: AllergyMild, Status: AllergyResolved},
+		{Substance: "shellfish, dust", Category: AllergyUnstructured, Status: AllergyActive},
+		{Substance: "Aspirin", Category: AllergyDrug, Severity: AllergySevere, Status: AllergyEnteredInError},
+	}
+
+	banner := NewAllergyBanner(allergies)
+
+	assert.Equal(t, 4, banner.Count)
+	assert.Equal(t, AllergySevere, banner.HighestSeverity)
+	assert.Equal(t, 1, banner.DrugAllergies)
+	assert.Equal(t, 1, banner.Unstructured)
+
+	var substances []string
+	for _, item := range banner.Items {
+		substances = append(substances, item.Substance)
+	}
+	assert.Equal(t, []string{"Penicillin", "Peanuts", "Latex", "shellfish, dust"}, substances, "most severe first, recorded order otherwise")
+}
+
+func TestNewAllergyBannerEmpty(t *testing.T) {
+	banner := NewAllergyBanner(nil)
+
+	assert.Equal(t, 0, banner.Count)
+	assert.Equal(t, AllergySeverity(""), banner.HighestSeverity)
+	assert.NotNil(t, banner.Items, "items should serialise as an empty list")
+}
+
+func TestAllergyEnums(t *testing.T) {
+	assert.True(t, AllergyDrug.Valid())
+	assert.False(t, AllergyUnstructured.Valid(), "unstructured entries cannot be recorded directly")
+	assert.True(t, AllergySeverity("").Valid(), "unknown severity")
+	assert.False(t, AllergySeverity("fatal").Valid())
+	assert.True(t, AllergyEnteredInError.Valid())
+	assert.False(t, AllergyStatus("gone").Valid())
+}
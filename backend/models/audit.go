@@ -0,0 +1,89 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditAction is the kind of access recorded in the audit log
+type AuditAction string
+
+const (
+	AuditRead   AuditAction = "read"
+	AuditCreate AuditAction = "create"
+	AuditUpdate AuditAction = "update"
+	AuditDelete AuditAction = "delete"
+	AuditExport AuditAction = "export"
+	// AuditRestore and AuditPurge record a patient leaving the trash, either
+	// restored or permanently removed
+	AuditRestore AuditAction = "restore"
+	AuditPurge   AuditAction = "purge"
+	// AuditMerge and AuditUnmerge record duplicate records being combined
+	// and split again
+	AuditMerge   AuditAction = "merge"
+	AuditUnmerge AuditAction = "unmerge"
+)
+
+// AuditEvent is one append-only entry of the PHI access log. Each entry
+// stores the hash of the previous one so that changing or removing a row
+// breaks the chain.
+type AuditEvent struct {
+	ID                uint        `gorm:"primaryKey" json:"id"`
+	OccurredAt        time.Time   `gorm:"not null;index" json:"occurredAt"`
+	ActorID           uint        `gorm:"not null;index" json:"actorId"`
+	ActorRole         UserRole    `gorm:"not null" json:"actorRole"`
+	Action            AuditAction `gorm:"not null;index" json:"action"`
+	PatientID         *uint       `gorm:"index" json:"patientId"`
+	Fields            []string    `gorm:"serializer:json" json:"fields"`
+	IP                string      `json:"ip"`
+	RequestID         string      `json:"requestId"`
+	Route             string      `json:"route"`
+	EmergencyAccessID *uint       `json:"emergencyAccessId"`
+	PrevHash          string      `gorm:"not null" json:"prevHash"`
+	Hash              string      `gorm:"not null;uniqueIndex" json:"hash"`
+}
+
+// ComputeHash returns the SHA-256 over PrevHash and every recorded value of
+// the event. The ID is left out because the database assigns it on insert.
+func (e *AuditEvent) ComputeHash() string {
+	optional := func(id *uint) string {
+		if id == nil {
+			return ""
+		}
+		return strconv.FormatUint(uint64(*id), 10)
+	}
+
+	parts := []string{
+		e.PrevHash,
+		e.OccurredAt.UTC().Format(time.RFC3339Nano),
+		strconv.FormatUint(uint64(e.ActorID), 10),
+		string(e.ActorRole),
+		string(e.Action),
+		optional(e.PatientID),
+		strings.Join(e.Fields, ","),
+		e.IP,
+		e.RequestID,
+		e.Route,
+		optional(e.EmergencyAccessID),
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAuditChain checks that events, ordered by ID, link to each other and
+// that their hashes match their contents. prevHash is the hash of the event
+// before the first one, or "" when starting at the beginning of the log. It
+// returns the ID of the first event that fails.
+func VerifyAuditChain(prevHash string, events []AuditEvent) (uint, bool) {
+	for i := range events {
+		if events[i].PrevHash != prevHash || events[i].ComputeHash() != events[i].Hash {
+			return events[i].ID, false
+		}
+		prevHash = events[i].Hash
+	}
+	return 0, true
+}
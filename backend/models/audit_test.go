@@ -0,0 +1,97 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildAuditChain(n int) []AuditEvent {
+	patientID := uint(42)
+	start := time.Date(2024, 3, 1, 9, 0, 0, 123456000, time.UTC)
+
+	events := make([]AuditEvent, n)
+	prev := ""
+	for i := range events {
+		events[i] = AuditEvent{
+			ID:         uint(i + 1),
+			OccurredAt: start.Add(time.Duration(i) * time.Minute),
+			ActorID:    7,
+			ActorRole:  RoleDoctor,
+			Action:     AuditRead,
+			PatientID:  &patientID,
+			Fields:     []string{"diagnosis", "notes"},
+			IP:         "10.0.0.1",
+			RequestID:  "req",
+			PrevHash:   prev,
+		}
+		events[i].Hash = events[i].ComputeHash()
+		prev = events[i].Hash
+	}
+	return events
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]AuditEvent) []AuditEvent
+		wantID uint
+		wantOK bool
+	}{
+		{
+			name:   "intact chain",
+			tamper: func(e []AuditEvent) []AuditEvent { return e },
+			wantOK: true,
+		},
+		{
+			name: "changed field",
+			tamper: func(e []AuditEvent) []AuditEvent {
+				e[1].Fields = []string{"notes"}
+				return e
+			},
+			wantID: 2,
+		},
+		{
+			name: "changed actor with recomputed hash",
+			tamper: func(e []AuditEvent) []AuditEvent {
+				e[1].ActorID = 8
+				e[1].Hash = e[1].ComputeHash()
+				return e
+			},
+			wantID: 3,
+		},
+		{
+			name: "deleted event",
+			tamper: func(e []AuditEvent) []AuditEvent {
+				return append(e[:1], e[2:]...)
+			},
+			wantID: 3,
+		},
+		{
+			name: "emergency grant added",
+			tamper: func(e []AuditEvent) []AuditEvent {
+				grant := uint(5)
+				e[0].EmergencyAccessID = &grant
+				return e
+			},
+			wantID: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := tt.tamper(buildAuditChain(4))
+			id, ok := VerifyAuditChain("", events)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantID, id)
+		})
+	}
+}
+
+func TestAuditHashIgnoresTimeZone(t *testing.T) {
+	events := buildAuditChain(1)
+	local := events[0]
+	local.OccurredAt = local.OccurredAt.In(time.FixedZone("UTC+2", 2*60*60))
+	assert.Equal(t, events[0].Hash, local.ComputeHash())
+}
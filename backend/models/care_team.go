@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// CareTeamRole describes how a staff member is involved in a patient's care
+type CareTeamRole string
+
+const (
+	CareTeamPrimaryPhysician CareTeamRole = "primary_physician"
+	CareTeamConsulting       CareTeamRole = "consulting"
+	CareTeamNurse            CareTeamRole = "nurse"
+)
+
+// Valid reports whether r is one of the known care-team roles
+func (r CareTeamRole) Valid() bool {
+	switch r {
+	case CareTeamPrimaryPhysician, CareTeamConsulting, CareTeamNurse:
+		return true
+	}
+	return false
+}
+
+// CareTeamMember assigns a user to a patient. Users without the
+// patient:access_all permission only see patients they are assigned to.
+type CareTeamMember struct {
+	ID         uint         `gorm:"primaryKey" json:"id"`
+	PatientID  uint         `gorm:"not null;uniqueIndex:idx_care_team_patient_user" json:"patientId"`
+	UserID     uint         `gorm:"not null;uniqueIndex:idx_care_team_patient_user;index" json:"userId"`
+	Role       CareTeamRole `gorm:"not null" json:"role"`
+	AssignedBy uint         `gorm:"not null" json:"assignedBy"`
+	CreatedAt  time.Time    `json:"createdAt"`
+	User       *User        `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
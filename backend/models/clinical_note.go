@@ -0,0 +1,217 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// NoteStatus is where a clinical note is in its lifecycle. Drafts can be
+// edited by their author; once signed the content is locked.
+type NoteStatus string
+
+const (
+	NoteDraft NoteStatus = "draft"
+	// NoteAwaitingCosign is a note signed by a trainee that still needs a
+	// supervising doctor's co-signature
+	NoteAwaitingCosign NoteStatus = "awaiting_cosign"
+	NoteSigned         NoteStatus = "signed"
+	// NoteLegacy is a note imported from the free-text notes field of a
+	// patient. Nobody wrote or signed it in this system, so it has no author
+	// and is read-only like a signed note.
+	NoteLegacy NoteStatus = "legacy"
+)
+
+// Valid reports whether s is a known status
+func (s NoteStatus) Valid() bool {
+	switch s {
+	case NoteDraft, NoteAwaitingCosign, NoteSigned, NoteLegacy:
+		return true
+	}
+	return false
+}
+
+var (
+	ErrNoteNotDraft        = errors.New("only draft notes can be changed")
+	ErrNoteEmpty           = errors.New("note has no content")
+	ErrNoteNotAwaiting     = errors.New("note is not awaiting a co-signature")
+	ErrNoteCosignOwn       = errors.New("you cannot co-sign your own note")
+	ErrNoteCosignTrainee   = errors.New("trainees cannot co-sign notes")
+	ErrNoteUnknownTemplate = errors.New("unknown note template")
+)
+
+// ClinicalNote is a SOAP-structured note written by a doctor about a
+// patient, optionally during an encounter. Legacy notes have no author.
+// Addenda is not a column but filled from the note_addenda table.
+type ClinicalNote struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	PatientID   uint           `gorm:"not null;index" json:"patientId"`
+	EncounterID *uint          `gorm:"index" json:"encounterId"`
+	AuthorID    *uint          `gorm:"index" json:"authorId"`
+	Template    string         `json:"template"`
+	Title       string         `gorm:"not null" json:"title" redact:"phi"`
+	Subjective  string         `json:"subjective" redact:"phi"`
+	Objective   string         `json:"objective" redact:"phi"`
+	Assessment  string         `json:"assessment" redact:"phi"`
+	Plan        string         `json:"plan" redact:"phi"`
+	Status      NoteStatus     `gorm:"not null;default:draft" json:"status"`
+	SignedAt    *time.Time     `json:"signedAt"`
+	CosignerID  *uint          `json:"cosignerId"`
+	CosignedAt  *time.Time     `json:"cosignedAt"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	Author      *User          `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+	Addenda     []NoteAddendum `gorm:"-" json:"addenda"`
+}
+
+// NoteAddendum is text appended to a signed note. Addenda cannot be changed
+// once written.
+type NoteAddendum struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	NoteID    uint      `gorm:"not null;index" json:"noteId"`
+	PatientID uint      `gorm:"not null;index" json:"patientId"`
+	AuthorID  uint      `gorm:"not null" json:"authorId"`
+	Text      string    `gorm:"not null" json:"text" redact:"phi"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TableName keeps the plural of addendum
+func (NoteAddendum) TableName() string {
+	return "note_addenda"
+}
+
+// WrittenBy reports whether userID is the author of the note
+func (n *ClinicalNote) WrittenBy(userID uint) bool {
+	return n.AuthorID != nil && *n.AuthorID == userID
+}
+
+// Sign locks a draft note. A note signed by a trainee waits for a
+// co-signature before it counts as signed.
+func (n *ClinicalNote) Sign(trainee bool, now time.Time) error {
+	if n.Status != NoteDraft {
+		return ErrNoteNotDraft
+	}
+	if !n.hasContent() {
+		return ErrNoteEmpty
+	}
+	n.SignedAt = &now
+	n.Status = NoteSigned
+	if trainee {
+		n.Status = NoteAwaitingCosign
+	}
+	return nil
+}
+
+// hasContent reports whether any section has text beyond the prompts of
+// the note's template
+func (n *ClinicalNote) hasContent() bool {
+	template, _ := FindNoteTemplate(n.Template)
+	sections := [][2]string{
+		{n.Subjective, template.Subjective},
+		{n.Objective, template.Objective},
+		{n.Assessment, template.Assessment},
+		{n.Plan, template.Plan},
+	}
+	for _, section := range sections {
+		text := strings.TrimSpace(section[0])
+		if text != "" && text != strings.TrimSpace(section[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Cosign completes the signature of a trainee's note by a supervising
+// doctor
+func (n *ClinicalNote) Cosign(userID uint, trainee bool, now time.Time) error {
+	if n.Status != NoteAwaitingCosign {
+		return ErrNoteNotAwaiting
+	}
+	if n.WrittenBy(userID) {
+		return ErrNoteCosignOwn
+	}
+	if trainee {
+		return ErrNoteCosignTrainee
+	}
+	n.Status = NoteSigned
+	n.CosignerID = &userID
+	n.CosignedAt = &now
+	return nil
+}
+
+// NoteTemplate prefills the sections of a new note
+type NoteTemplate struct {
+	Name       string `json:"name"`
+	Title      string `json:"title"`
+	Subjective string `json:"subjective"`
+	Objective  string `json:"objective"`
+	Assessment string `json:"assessment"`
+	Plan       string `json:"plan"`
+}
+
+// NoteFreeText is the template of notes converted from the free-text notes
+// field of a patient
+const NoteFreeText = "free_text"
+
+// NoteTemplates lists the templates notes can be started from
+var NoteTemplates = []NoteTemplate{
+	{
+		Name:       "consultation",
+		Title:      "Consultation",
+		Subjective: "Presenting complaint:\nHistory of presenting complaint:\nPast medical history:\nMedications:\n",
+		Objective:  "Observations:\nExamination:\n",
+		Assessment: "Impression:\nDifferential diagnoses:\n",
+		Plan:       "Investigations:\nTreatment:\nFollow-up:\n",
+	},
+	{
+		Name:       "follow_up",
+		Title:      "Follow-up",
+		Subjective: "Progress since last visit:\nAdherence and side effects:\n",
+		Objective:  "Observations:\nExamination:\nResults:\n",
+		Assessment: "Response to treatment:\n",
+		Plan:       "Changes to treatment:\nNext review:\n",
+	},
+	{
+		Name:       "progress",
+		Title:      "Progress note",
+		Subjective: "Overnight events:\nPatient reports:\n",
+		Objective:  "Observations:\nExamination:\nResults:\n",
+		Assessment: "Problems:\n",
+		Plan:       "Plan for today:\n",
+	},
+	{
+		Name:  NoteFreeText,
+		Title: "Patient notes",
+	},
+}
+
+// FindNoteTemplate returns the template with a name
+func FindNoteTemplate(name string) (NoteTemplate, bool) {
+	for _, template := range NoteTemplates {
+		if template.Name == name {
+			return template, true
+		}
+	}
+	return NoteTemplate{}, false
+}
+
+// ApplyTemplate fills the title and the sections of a note that are still
+// empty from a template
+func (n *ClinicalNote) ApplyTemplate(name string) error {
+	template, ok := FindNoteTemplate(name)
+	if !ok {
+		return ErrNoteUnknownTemplate
+	}
+	n.Template = template.Name
+	fill := func(field *string, value string) {
+		if strings.TrimSpace(*field) == "" {
+			*field = value
+		}
+	}
+	fill(&n.Title, template.Title)
+	fill(&n.Subjective, template.Subjective)
+	fill(&n.Objective, template.Objective)
+	fill(&n.Assessment, template.Assessment)
+	fill(&n.Plan, template.Plan)
+	return nil
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClinicalNoteSign(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		note       ClinicalNote
+		trainee    bool
+		wantErr    error
+		wantStatus NoteStatus
+	}{
+		{name: "doctor signs", note: ClinicalNote{Status: NoteDraft, Assessment: "Viral URTI"}, wantStatus: NoteSigned},
+		{name: "trainee signs", note: ClinicalNote{Status: NoteDraft, Plan: "Rest"}, trainee: true, wantStatus: NoteAwaitingCosign},
+		{name: "already signed", note: ClinicalNote{Status: NoteSigned, Plan: "Rest"}, wantErr: ErrNoteNotDraft},
+		{name: "empty", note: ClinicalNote{Status: NoteDraft, Plan: "  "}, wantErr: ErrNoteEmpty},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			note := tt.note
+			err := note.Sign(tt.trainee, now)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, note.Status)
+			assert.Equal(t, now, *note.SignedAt)
+		})
+	}
+}
+
+func TestClinicalNoteSignTemplateOnly(t *testing.T) {
+	note := ClinicalNote{Status: NoteDraft}
+	assert.NoError(t, note.ApplyTemplate("consultation"))
+	assert.ErrorIs(t, note.Sign(false, time.Now()), ErrNoteEmpty, "unchanged template prompts are not content")
+
+	note.Assessment += "Tension headache"
+	assert.NoError(t, note.Sign(false, time.Now()))
+}
+
+func TestClinicalNoteCosign(t *testing.T) {
+	now := time.Now()
+	author := uint(7)
+	awaiting := func() ClinicalNote {
+		return ClinicalNote{AuthorID: &author, Status: NoteAwaitingCosign}
+	}
+
+	note := awaiting()
+	assert.NoError(t, note.Cosign(3, false, now))
+	assert.Equal(t, NoteSigned, note.Status)
+	assert.Equal(t, uint(3), *note.CosignerID)
+
+	note = awaiting()
+	assert.ErrorIs(t, note.Cosign(7, false, now), ErrNoteCosignOwn)
+	note = awaiting()
+	assert.ErrorIs(t, note.Cosign(3, true, now), ErrNoteCosignTrainee)
+	note = ClinicalNote{AuthorID: &author, Status: NoteSigned}
+	assert.ErrorIs(t, note.Cosign(3, false, now), ErrNoteNotAwaiting)
+	note = ClinicalNote{Status: NoteLegacy}
+	assert.ErrorIs(t, note.Cosign(3, false, now), ErrNoteNotAwaiting)
+}
+
+func TestClinicalNoteWrittenBy(t *testing.T) {
+	author := uint(7)
+	note := ClinicalNote{AuthorID: &author}
+	assert.True(t, note.WrittenBy(7))
+	assert.False(t, note.WrittenBy(3))
+
+	legacy := ClinicalNote{Status: NoteLegacy}
+	assert.False(t, legacy.WrittenBy(0), "legacy notes have no author")
+}
+
+func TestClinicalNoteApplyTemplate(t *testing.T) {
+	note := ClinicalNote{Title: "Chest pain review", Subjective: "Chest pain for 2 days"}
+	assert.NoError(t, note.ApplyTemplate("follow_up"))
+
+	assert.Equal(t, "follow_up", note.Template)
+	assert.Equal(t, "Chest pain review", note.Title, "given title kept")
+	assert.Equal(t, "Chest pain for 2 days", note.Subjective, "given section kept")
+	assert.Contains(t, note.Plan, "Next review:")
+
+	assert.ErrorIs(t, note.ApplyTemplate("discharge"), ErrNoteUnknownTemplate)
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// DiagnosisStatus is whether a problem is still current
+type DiagnosisStatus string
+
+const (
+	DiagnosisActive   DiagnosisStatus = "active"
+	DiagnosisResolved DiagnosisStatus = "resolved"
+)
+
+// Valid reports whether s is a known status
+func (s DiagnosisStatus) Valid() bool {
+	return s == DiagnosisActive || s == DiagnosisResolved
+}
+
+// Diagnosis is one coded entry of a patient's problem list. EncounterID is
+// the visit it was made in, if any. Code is empty for entries converted
+// from the free-text diagnosis field until a doctor codes them.
+type Diagnosis struct {
+	ID           uint            `gorm:"primaryKey" json:"id"`
+	PatientID    uint            `gorm:"not null;index" json:"patientId"`
+	EncounterID  *uint           `gorm:"index" json:"encounterId"`
+	Code         string          `gorm:"size:10;not null;index" json:"code"`
+	Description  string          `gorm:"not null" json:"description" redact:"phi"`
+	Status       DiagnosisStatus `gorm:"not null;default:active" json:"status"`
+	OnsetDate    *time.Time      `json:"onsetDate"`
+	ResolvedDate *time.Time      `json:"resolvedDate"`
+	DiagnosedBy  uint            `gorm:"not null" json:"diagnosedBy"`
+	UpdatedBy    uint            `gorm:"not null" json:"updatedBy"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	UpdatedAt    time.Time       `json:"updatedAt"`
+	Doctor       *User           `gorm:"foreignKey:DiagnosedBy" json:"doctor,omitempty"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// EmergencyAccess is a break-glass grant that lets a user open a patient
+// outside their care teams until ExpiresAt. Every grant waits in the review
+// queue until an admin acknowledges it.
+type EmergencyAccess struct {
+	ID             uint                    `gorm:"primaryKey" json:"id"`
+	PatientID      uint                    `gorm:"not null;index" json:"patientId"`
+	UserID         uint                    `gorm:"not null;index" json:"userId"`
+	Reason         string                  `gorm:"type:text;not null" json:"reason" redact:"phi"`
+	ExpiresAt      time.Time               `gorm:"not null" json:"expiresAt"`
+	CreatedAt      time.Time               `json:"createdAt"`
+	AcknowledgedAt *time.Time              `gorm:"index" json:"acknowledgedAt"`
+	AcknowledgedBy *uint                   `json:"acknowledgedBy"`
+	ReviewNote     string                  `gorm:"type:text" json:"reviewNote"`
+	User           *User                   `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Actions        []EmergencyAccessAction `gorm:"foreignKey:EmergencyAccessID" json:"actions,omitempty"`
+}
+
+// IsActive reports whether the grant still allows access at now
+func (e *EmergencyAccess) IsActive(now time.Time) bool {
+	return now.Before(e.ExpiresAt)
+}
+
+// EmergencyAccessAction records one read or write made under a break-glass
+// grant
+type EmergencyAccessAction struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	EmergencyAccessID uint      `gorm:"not null;index" json:"emergencyAccessId"`
+	Action            string    `gorm:"not null" json:"action"`
+	Route             string    `json:"route"`
+	RequestID         string    `json:"requestId"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+)
+
+// EncounterType is the setting a patient was seen in
+type EncounterType string
+
+const (
+	EncounterOutpatient EncounterType = "outpatient"
+	EncounterInpatient  EncounterType = "inpatient"
+	EncounterEmergency  EncounterType = "emergency"
+	EncounterTelehealth EncounterType = "telehealth"
+)
+
+// Valid reports whether t is a known encounter type
+func (t EncounterType) Valid() bool {
+	switch t {
+	case EncounterOutpatient, EncounterInpatient, EncounterEmergency, EncounterTelehealth:
+		return true
+	}
+	return false
+}
+
+// EncounterStatus is whether an encounter is still in progress
+type EncounterStatus string
+
+const (
+	EncounterOpen   EncounterStatus = "open"
+	EncounterClosed EncounterStatus = "closed"
+)
+
+// Valid reports whether s is a known status
+func (s EncounterStatus) Valid() bool {
+	return s == EncounterOpen || s == EncounterClosed
+}
+
+// EncounterVitals are the latest vital signs taken during an encounter,
+// derived from its vital sign observations. Temperature is in degrees
+// Celsius.
+type EncounterVitals struct {
+	SystolicBP  *int     `json:"systolicBp"`
+	DiastolicBP *int     `json:"diastolicBp"`
+	Pulse       *int     `json:"pulse"`
+	Temperature *float64 `json:"temperature"`
+	SpO2        *int     `gorm:"column:spo2" json:"spo2"`
+	HeightCm    *float64 `json:"heightCm"`
+	WeightKg    *float64 `json:"weightKg"`
+}
+
+// Encounter is one visit of a patient: an appointment, admission or call
+// with a doctor. Clinical notes, vitals and diagnoses are recorded against
+// it; Vitals and Diagnoses are not columns but filled from the vital_signs
+// and diagnoses tables.
+type Encounter struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	PatientID uint            `gorm:"not null;index:idx_encounters_patient_started" json:"patientId"`
+	DoctorID  uint            `gorm:"not null;index" json:"doctorId"`
+	Type      EncounterType   `gorm:"not null" json:"type"`
+	Reason    string          `gorm:"not null" json:"reason" redact:"phi"`
+	Status    EncounterStatus `gorm:"not null;default:open" json:"status"`
+	StartedAt time.Time       `gorm:"not null;index:idx_encounters_patient_started" json:"startedAt"`
+	EndedAt   *time.Time      `json:"endedAt"`
+	Vitals    EncounterVitals `gorm:"-" json:"vitals"`
+	ClosedBy  *uint           `json:"closedBy"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+	Doctor    *User           `gorm:"foreignKey:DoctorID" json:"doctor,omitempty"`
+	Diagnoses []Diagnosis     `gorm:"-" json:"diagnoses,omitempty"`
+}
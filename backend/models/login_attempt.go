@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginAttempt stores failed login counters for an account or IP address
+// when the database lockout store is enabled
+type LoginAttempt struct {
+	Key         string    `gorm:"primaryKey;column:attempt_key;size:320" json:"key"`
+	Failures    int       `gorm:"not null" json:"failures"`
+	LastFailure time.Time `json:"lastFailure"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
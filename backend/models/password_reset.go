@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordResetToken is a single-use, time-limited token an admin issues so
+// a user can set a new password. Only the SHA-256 hash is stored.
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"userId"`
+	TokenHash string     `gorm:"not null;uniqueIndex;size:64" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedBy uint       `gorm:"not null" json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
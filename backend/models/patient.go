@@ -8,22 +8,33 @@ import (
 
 type Patient struct {
 	ID               uint           `gorm:"primaryKey" json:"id"`
-	FirstName        string         `gorm:"not null" json:"firstName"`
-	LastName         string         `gorm:"not null" json:"lastName"`
-	Email           string         `gorm:"not null;unique" json:"email"`
-	Phone           string         `gorm:"not null" json:"phone"`
-	DateOfBirth     time.Time      `gorm:"not null" json:"dateOfBirth"`
+	// MRN is the medical record number issued by the clinic on registration
+	MRN              string         `gorm:"size:32;uniqueIndex:idx_patients_mrn,where:mrn <> ''" json:"mrn"`
+	FirstName        string         `gorm:"not null" json:"firstName" redact:"phi"`
+	LastName         string         `gorm:"not null" json:"lastName" redact:"phi"`
+	// Email is optional and unique among patients that are not soft-deleted
+	Email           string         `gorm:"not null;default:'';uniqueIndex:idx_patients_email_unique,where:deleted_at IS NULL AND email <> ''" json:"email" redact:"phi"`
+	Phone           string         `gorm:"not null" json:"phone" redact:"phi"`
+	DateOfBirth     time.Time      `gorm:"not null" json:"dateOfBirth" redact:"phi"`
 	Gender          string         `gorm:"not null" json:"gender"`
-	Address         string         `gorm:"not null" json:"address"`
-	EmergencyContact string         `gorm:"not null" json:"emergencyContact"`
-	EmergencyPhone  string         `gorm:"not null" json:"emergencyPhone"`
-	BloodGroup      string         `json:"bloodGroup"`
-	Allergies       string         `json:"allergies"`
-	Diagnosis       string         `json:"diagnosis"`
-	Notes           string         `json:"notes"`
+	Address         string         `gorm:"not null" json:"address" redact:"phi"`
+	EmergencyContact string         `gorm:"not null" json:"emergencyContact" redact:"phi"`
+	EmergencyPhone  string         `gorm:"not null" json:"emergencyPhone" redact:"phi"`
+	BloodGroup      string         `json:"bloodGroup" redact:"phi"`
+	Allergies       string         `json:"allergies" redact:"phi"`
+	// Diagnosis and Notes are retired; start-up moves them into the problem
+	// list and clinical notes
+	Diagnosis       string         `json:"diagnosis" redact:"phi"`
+	Notes           string         `json:"notes" redact:"phi"`
 	CreatedBy       uint           `gorm:"not null" json:"createdBy"`
 	UpdatedBy       uint           `gorm:"not null" json:"updatedBy"`
+	// Version increases on every update and backs the ETag/If-Match check
+	Version         int            `gorm:"not null;default:1" json:"version"`
 	CreatedAt       time.Time      `json:"createdAt"`
 	UpdatedAt       time.Time      `json:"updatedAt"`
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+	DeletedBy       *uint          `json:"-"`
+	// MergedIntoID is set on a duplicate that was merged into another
+	// patient. Merged patients are soft-deleted but kept out of the trash.
+	MergedIntoID    *uint          `gorm:"index" json:"-"`
 } 
\ No newline at end of file
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// IdentifierType is the kind of identifier a patient is known by outside
+// the clinic
+type IdentifierType string
+
+const (
+	IdentifierNationalID     IdentifierType = "national_id"
+	IdentifierInsurance      IdentifierType = "insurance"
+	IdentifierPassport       IdentifierType = "passport"
+	IdentifierDriversLicense IdentifierType = "drivers_license"
+	IdentifierOther          IdentifierType = "other"
+)
+
+// IdentifierMRN is accepted by identifier lookups to search by the
+// clinic-issued medical record number, which is stored on the patient
+const IdentifierMRN IdentifierType = "mrn"
+
+// Valid reports whether t is one of the identifier types that can be stored
+func (t IdentifierType) Valid() bool {
+	switch t {
+	case IdentifierNationalID, IdentifierInsurance, IdentifierPassport, IdentifierDriversLicense, IdentifierOther:
+		return true
+	}
+	return false
+}
+
+// PatientIdentifier is an external identifier such as a national ID or an
+// insurance member number. A value is unique per type and issuer.
+type PatientIdentifier struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	PatientID uint           `gorm:"not null;index" json:"patientId"`
+	Type      IdentifierType `gorm:"not null;size:32;uniqueIndex:idx_patient_identifiers_value" json:"type"`
+	Issuer    string         `gorm:"not null;size:100;uniqueIndex:idx_patient_identifiers_value" json:"issuer"`
+	Value     string         `gorm:"not null;size:100;uniqueIndex:idx_patient_identifiers_value" json:"value" redact:"phi"`
+	CreatedBy uint           `gorm:"not null" json:"createdBy"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+// MRNSequence holds the last medical record number issued by a clinic
+type MRNSequence struct {
+	Clinic    string `gorm:"primaryKey;size:32"`
+	LastValue int64  `gorm:"not null"`
+}
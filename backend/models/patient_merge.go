@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// PatientMerge records a duplicate (source) patient merged into the
+// surviving (target) patient, with what was needed to undo it
+type PatientMerge struct {
+	ID       uint `gorm:"primaryKey" json:"id"`
+	TargetID uint `gorm:"not null;index" json:"targetId"`
+	SourceID uint `gorm:"not null;index" json:"sourceId"`
+	// Fields were copied from the source onto the target; TargetBefore
+	// holds their values on the target before the merge
+	Fields       []string        `gorm:"serializer:json" json:"fields"`
+	TargetBefore PatientSnapshot `gorm:"serializer:json;type:text" json:"-" redact:"phi"`
+	// Moved lists, per related resource, the row IDs re-pointed from the
+	// source to the target
+	Moved      map[string][]uint `gorm:"serializer:json;type:text" json:"moved"`
+	Score      float64           `json:"score"`
+	MergedBy   uint              `gorm:"not null" json:"mergedBy"`
+	MergedAt   time.Time         `gorm:"not null" json:"mergedAt"`
+	UnmergedBy *uint             `json:"unmergedBy"`
+	UnmergedAt *time.Time        `json:"unmergedAt"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// PatientSnapshot is a patient record as it was serialised to JSON
+type PatientSnapshot map[string]interface{}
+
+// FieldChange is one field that differs between two revisions
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// PatientRevision is a versioned copy of a patient record, written on every
+// create, update and delete
+type PatientRevision struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	PatientID uint            `gorm:"not null;uniqueIndex:idx_patient_revision_version" json:"patientId"`
+	Version   int             `gorm:"not null;uniqueIndex:idx_patient_revision_version" json:"version"`
+	Action    AuditAction     `gorm:"not null" json:"action"`
+	Snapshot  PatientSnapshot `gorm:"serializer:json;type:text;not null" json:"snapshot,omitempty" redact:"phi"`
+	Changes   []FieldChange   `gorm:"serializer:json;type:text" json:"changes" redact:"phi"`
+	ChangedBy uint            `gorm:"not null" json:"changedBy"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// snapshotMetaFields are bookkeeping fields left out of diffs
+var snapshotMetaFields = map[string]bool{
+	"id":        true,
+	"createdAt": true,
+	"createdBy": true,
+	"updatedAt": true,
+	"updatedBy": true,
+	"version":   true,
+}
+
+// NewPatientSnapshot captures the JSON representation of a patient
+func NewPatientSnapshot(patient *Patient) (PatientSnapshot, error) {
+	data, err := json.Marshal(patient)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot PatientSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// DiffSnapshots lists the fields that differ between two snapshots, sorted by
+// field name. A nil from snapshot reports every field as new.
+func DiffSnapshots(from, to PatientSnapshot) []FieldChange {
+	fields := make(map[string]bool)
+	for field := range from {
+		fields[field] = true
+	}
+	for field := range to {
+		fields[field] = true
+	}
+
+	var changes []FieldChange
+	for field := range fields {
+		if snapshotMetaFields[field] {
+			continue
+		}
+		if !reflect.DeepEqual(from[field], to[field]) {
+			changes = append(changes, FieldChange{Field: field, From: from[field], To: to[field]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
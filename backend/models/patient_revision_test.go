@@ -0,0 +1,70 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	before := &Patient{
+		ID:          1,
+		FirstName:   "Ada",
+		LastName:    "Lovelace",
+		DateOfBirth: time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC),
+		Diagnosis:   "Flu",
+		UpdatedBy:   3,
+	}
+	after := *before
+	after.Diagnosis = "Bronchitis"
+	after.Notes = "Follow up in a week"
+	after.UpdatedBy = 4
+	after.UpdatedAt = time.Now()
+
+	from, err := NewPatientSnapshot(before)
+	require.NoError(t, err)
+	to, err := NewPatientSnapshot(&after)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		from, to PatientSnapshot
+		want     []FieldChange
+	}{
+		{
+			name: "changed fields only, metadata ignored",
+			from: from,
+			to:   to,
+			want: []FieldChange{
+				{Field: "diagnosis", From: "Flu", To: "Bronchitis"},
+				{Field: "notes", From: "", To: "Follow up in a week"},
+			},
+		},
+		{
+			name: "identical snapshots",
+			from: from,
+			to:   from,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DiffSnapshots(tt.from, tt.to))
+		})
+	}
+}
+
+func TestDiffSnapshotsFromNothing(t *testing.T) {
+	to, err := NewPatientSnapshot(&Patient{FirstName: "Ada"})
+	require.NoError(t, err)
+
+	changes := DiffSnapshots(nil, to)
+	require.NotEmpty(t, changes)
+	for _, change := range changes {
+		assert.Nil(t, change.From)
+		assert.NotEqual(t, "id", change.Field)
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+// Permission is a single action a role may perform
+type Permission string
+
+const (
+	PermPatientRead Permission = "patient:read"
+	// PermPatientReadClinical is needed on top of patient:read to see
+	// clinical fields such as diagnosis and notes
+	PermPatientReadClinical      Permission = "patient:read_clinical"
+	PermPatientCreate            Permission = "patient:create"
+	PermPatientWriteDemographics Permission = "patient:write_demographics"
+	PermPatientWriteClinical     Permission = "patient:write_clinical"
+	PermPatientDelete            Permission = "patient:delete"
+	// PermPatientTrash allows listing and restoring soft-deleted patients
+	PermPatientTrash Permission = "patient:trash"
+	// PermPatientMerge allows merging duplicate patients and undoing merges
+	PermPatientMerge Permission = "patient:merge"
+	// PermPatientAccessAll lifts care-team scoping; without it a user only
+	// sees patients they are assigned to
+	PermPatientAccessAll Permission = "patient:access_all"
+	// PermPatientBreakGlass allows time-boxed emergency access to patients
+	// outside the user's care teams
+	PermPatientBreakGlass Permission = "patient:break_glass"
+	PermEmergencyReview   Permission = "emergency:review"
+	PermAuditRead         Permission = "audit:read"
+	PermCareTeamManage    Permission = "careteam:manage"
+	PermUserManage        Permission = "user:manage"
+)
+
+// AllPermissions lists every known permission
+var AllPermissions = []Permission{
+	PermPatientRead,
+	PermPatientReadClinical,
+	PermPatientCreate,
+	PermPatientWriteDemographics,
+	PermPatientWriteClinical,
+	PermPatientDelete,
+	PermPatientTrash,
+	PermPatientMerge,
+	PermPatientAccessAll,
+	PermPatientBreakGlass,
+	PermEmergencyReview,
+	PermAuditRead,
+	PermCareTeamManage,
+	PermUserManage,
+}
+
+// Valid reports whether p is a known permission
+func (p Permission) Valid() bool {
+	for _, known := range AllPermissions {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
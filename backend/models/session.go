@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// Session represents one login of a user. Every refresh token issued from
+// that login belongs to the same session, so revoking the session revokes
+// the whole token family.
+type Session struct {
+	ID         string     `gorm:"primaryKey;size:64" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"userId"`
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	RevokeNote string     `json:"revokeNote"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// IsActive reports whether the session can still be used to authenticate requests
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// RefreshToken is a single-use token that can be exchanged for a new access
+// token. Only the SHA-256 hash of the token is stored.
+type RefreshToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	SessionID  string     `gorm:"not null;index;size:64" json:"sessionId"`
+	TokenHash  string     `gorm:"not null;uniqueIndex;size:64" json:"-"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expiresAt"`
+	UsedAt     *time.Time `json:"usedAt"`
+	ReplacedBy *uint      `json:"replacedBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
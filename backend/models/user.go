@@ -11,15 +11,41 @@ type UserRole string
 const (
 	RoleDoctor       UserRole = "doctor"
 	RoleReceptionist UserRole = "receptionist"
+	RoleAdmin        UserRole = "admin"
 )
 
+// Valid reports whether r is one of the known roles
+func (r UserRole) Valid() bool {
+	switch r {
+	case RoleDoctor, RoleReceptionist, RoleAdmin:
+		return true
+	}
+	return false
+}
+
 type User struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	Name         string    `gorm:"not null" json:"name"`
-	Email        string    `gorm:"unique;not null" json:"email"`
-	PasswordHash string    `gorm:"not null" json:"-"`
-	Role         UserRole  `gorm:"not null" json:"role"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
-} 
\ No newline at end of file
+	ID           uint     `gorm:"primaryKey" json:"id"`
+	Name         string   `gorm:"not null" json:"name"`
+	Email        string   `gorm:"unique;not null" json:"email"`
+	PasswordHash string   `gorm:"not null" json:"-" redact:"secret"`
+	Role         UserRole `gorm:"not null" json:"role"`
+	// Trainee doctors need a supervising doctor to co-sign their notes
+	Trainee bool `gorm:"not null;default:false" json:"trainee"`
+	// TOTPSecret is set when enrollment starts and only trusted once MFAEnabled is true
+	TOTPSecret    string     `json:"-" redact:"secret"`
+	TOTPLastStep  int64      `json:"-"`
+	MFAEnabled    bool       `gorm:"not null;default:false" json:"mfaEnabled"`
+	MFAEnrolledAt *time.Time `json:"mfaEnrolledAt"`
+	// PasswordChangedAt is nil until the user sets their own password
+	PasswordChangedAt *time.Time `json:"passwordChangedAt"`
+	// DeactivatedAt is set when an admin disables the account
+	DeactivatedAt *time.Time     `json:"deactivatedAt"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// IsActive reports whether the account may sign in
+func (u *User) IsActive() bool {
+	return u.DeactivatedAt == nil
+}
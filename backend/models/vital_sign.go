@@ -0,0 +1,295 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// VitalType is the kind of a vital sign observation
+type VitalType string
+
+const (
+	VitalSystolicBP  VitalType = "systolic_bp"
+	VitalDiastolicBP VitalType = "diastolic_bp"
+	VitalPulse       VitalType = "pulse"
+	VitalTemperature VitalType = "temperature"
+	VitalSpO2        VitalType = "spo2"
+	VitalHeight      VitalType = "height"
+	VitalWeight      VitalType = "weight"
+	// VitalBMI is calculated from height and weight, never recorded directly
+	VitalBMI VitalType = "bmi"
+)
+
+// VitalFlag says how an observation compares to the normal range for the
+// patient's age. It is empty when there is no range, as for height.
+type VitalFlag string
+
+const (
+	VitalLow    VitalFlag = "low"
+	VitalNormal VitalFlag = "normal"
+	VitalHigh   VitalFlag = "high"
+)
+
+var (
+	ErrVitalUnknownType = errors.New("unknown vital sign type")
+	ErrVitalUnit        = errors.New("unit not accepted for this vital sign")
+	ErrVitalImplausible = errors.New("value outside the plausible range")
+)
+
+// vitalUnit converts a unit to and from the canonical unit of its type
+type vitalUnit struct {
+	toCanonical   func(float64) float64
+	fromCanonical func(float64) float64
+}
+
+func identity(v float64) float64 { return v }
+
+// vitalSpec is the canonical unit, the other accepted units and the
+// plausibility limits, in the canonical unit, of a vital sign type
+type vitalSpec struct {
+	unit     string
+	units    map[string]vitalUnit
+	min, max float64
+}
+
+var vitalSpecs = map[VitalType]vitalSpec{
+	VitalSystolicBP:  {unit: "mmHg", min: 40, max: 300},
+	VitalDiastolicBP: {unit: "mmHg", min: 20, max: 200},
+	VitalPulse:       {unit: "bpm", min: 20, max: 300},
+	VitalTemperature: {unit: "°C", min: 25, max: 45, units: map[string]vitalUnit{
+		"°F": {
+			toCanonical:   func(f float64) float64 { return (f - 32) * 5 / 9 },
+			fromCanonical: func(c float64) float64 { return c*9/5 + 32 },
+		},
+	}},
+	VitalSpO2: {unit: "%", min: 50, max: 100},
+	VitalHeight: {unit: "cm", min: 20, max: 280, units: map[string]vitalUnit{
+		"in": {
+			toCanonical:   func(in float64) float64 { return in * 2.54 },
+			fromCanonical: func(cm float64) float64 { return cm / 2.54 },
+		},
+	}},
+	VitalWeight: {unit: "kg", min: 0.3, max: 500, units: map[string]vitalUnit{
+		"lb": {
+			toCanonical:   func(lb float64) float64 { return lb * 0.45359237 },
+			fromCanonical: func(kg float64) float64 { return kg / 0.45359237 },
+		},
+	}},
+	VitalBMI: {unit: "kg/m2", min: 5, max: 150},
+}
+
+// VitalTypes lists the vital sign types in the order they are charted
+var VitalTypes = []VitalType{
+	VitalSystolicBP, VitalDiastolicBP, VitalPulse, VitalTemperature, VitalSpO2, VitalHeight, VitalWeight, VitalBMI,
+}
+
+// Valid reports whether t is a known vital sign type
+func (t VitalType) Valid() bool {
+	_, ok := vitalSpecs[t]
+	return ok
+}
+
+// Unit returns the canonical unit observations of t are stored in
+func (t VitalType) Unit() string {
+	return vitalSpecs[t].unit
+}
+
+// unitAliases are spellings accepted for the units, lower case
+var unitAliases = map[string]string{
+	"c": "°C", "°c": "°C", "celsius": "°C",
+	"f": "°F", "°f": "°F", "fahrenheit": "°F",
+	"mmhg": "mmHg", "bpm": "bpm", "%": "%",
+	"cm": "cm", "in": "in", "inch": "in", "inches": "in",
+	"kg": "kg", "lb": "lb", "lbs": "lb",
+	"kg/m2": "kg/m2",
+}
+
+// lookupUnit finds the conversion of a unit of t. An empty unit is the
+// canonical unit.
+func (t VitalType) lookupUnit(unit string) (string, vitalUnit, error) {
+	spec, ok := vitalSpecs[t]
+	if !ok {
+		return "", vitalUnit{}, ErrVitalUnknownType
+	}
+	if unit == "" {
+		return spec.unit, vitalUnit{identity, identity}, nil
+	}
+	name, ok := unitAliases[strings.ToLower(strings.TrimSpace(unit))]
+	if !ok {
+		return "", vitalUnit{}, ErrVitalUnit
+	}
+	if name == spec.unit {
+		return name, vitalUnit{identity, identity}, nil
+	}
+	conversion, ok := spec.units[name]
+	if !ok {
+		return "", vitalUnit{}, ErrVitalUnit
+	}
+	return name, conversion, nil
+}
+
+// ConvertVital converts a value in unit to the canonical unit of t and
+// checks that it is plausible
+func ConvertVital(t VitalType, value float64, unit string) (float64, error) {
+	_, conversion, err := t.lookupUnit(unit)
+	if err != nil {
+		return 0, err
+	}
+	value = conversion.toCanonical(value)
+	spec := vitalSpecs[t]
+	if math.IsNaN(value) || value < spec.min || value > spec.max {
+		return 0, ErrVitalImplausible
+	}
+	return value, nil
+}
+
+// DisplayVital converts a value in the canonical unit of t to unit and
+// returns the unit's name. Units that do not apply to t leave the value
+// canonical.
+func DisplayVital(t VitalType, value float64, unit string) (float64, string) {
+	name, conversion, err := t.lookupUnit(unit)
+	if err != nil {
+		return value, t.Unit()
+	}
+	return conversion.fromCanonical(value), name
+}
+
+// vitalRange is the normal range of a vital sign for patients younger than
+// maxAge years
+type vitalRange struct {
+	maxAge    int
+	low, high float64
+}
+
+// vitalRanges are the normal ranges by age, youngest first. The last range
+// of a type applies to adults. BMI has no range for children, whose BMI is
+// judged against growth percentiles instead.
+var vitalRanges = map[VitalType][]vitalRange{
+	VitalSystolicBP: {
+		{1, 70, 100}, {6, 80, 110}, {13, 90, 120}, {18, 90, 130}, {math.MaxInt, 90, 139},
+	},
+	VitalDiastolicBP: {
+		{1, 50, 65}, {6, 50, 70}, {13, 55, 80}, {18, 60, 85}, {math.MaxInt, 60, 89},
+	},
+	VitalPulse: {
+		{1, 100, 160}, {3, 90, 150}, {6, 80, 140}, {13, 70, 120}, {math.MaxInt, 60, 100},
+	},
+	VitalTemperature: {{math.MaxInt, 36.0, 37.9}},
+	VitalSpO2:        {{math.MaxInt, 95, 100}},
+	VitalBMI:         {{18, 0, 0}, {math.MaxInt, 18.5, 24.9}},
+}
+
+// VitalRange returns the normal range of t for a patient of age years. It
+// reports false when t has no range at that age.
+func VitalRange(t VitalType, age int) (low, high float64, ok bool) {
+	for _, r := range vitalRanges[t] {
+		if age < r.maxAge {
+			return r.low, r.high, r.high > 0
+		}
+	}
+	return 0, 0, false
+}
+
+// FlagVital compares a value in the canonical unit of t to the normal range
+// for a patient of age years
+func FlagVital(t VitalType, value float64, age int) VitalFlag {
+	low, high, ok := VitalRange(t, age)
+	switch {
+	case !ok:
+		return ""
+	case value < low:
+		return VitalLow
+	case value > high:
+		return VitalHigh
+	}
+	return VitalNormal
+}
+
+// AgeAt returns the age in whole years of someone born on dob at time t
+func AgeAt(dob, t time.Time) int {
+	age := t.Year() - dob.Year()
+	if t.Month() < dob.Month() || (t.Month() == dob.Month() && t.Day() < dob.Day()) {
+		age--
+	}
+	if age < 0 {
+		return 0
+	}
+	return age
+}
+
+// BMI returns the body mass index for a height and weight, rounded to one
+// decimal
+func BMI(heightCm, weightKg float64) float64 {
+	m := heightCm / 100
+	return math.Round(weightKg/(m*m)*10) / 10
+}
+
+// VitalSign is one observation of a vital sign of a patient, optionally
+// taken during an encounter. Value is in the canonical unit of the type;
+// Flag compares it to the normal range for the patient's age when taken.
+type VitalSign struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	PatientID   uint      `gorm:"not null;index:idx_vital_signs_patient_type_taken" json:"patientId"`
+	EncounterID *uint     `gorm:"index" json:"encounterId"`
+	Type        VitalType `gorm:"not null;index:idx_vital_signs_patient_type_taken" json:"type"`
+	Value       float64   `gorm:"not null" json:"value" redact:"phi"`
+	Unit        string    `gorm:"not null" json:"unit"`
+	Flag        VitalFlag `json:"flag"`
+	TakenAt     time.Time `gorm:"not null;index:idx_vital_signs_patient_type_taken" json:"takenAt"`
+	RecordedBy  uint      `gorm:"not null" json:"recordedBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Observations returns a vital sign observation, without patient and
+// times, for each vital sign that is set
+func (v EncounterVitals) Observations() []VitalSign {
+	var observations []VitalSign
+	add := func(t VitalType, value *float64) {
+		if value != nil {
+			observations = append(observations, VitalSign{Type: t, Value: *value, Unit: t.Unit()})
+		}
+	}
+	float := func(value *int) *float64 {
+		if value == nil {
+			return nil
+		}
+		f := float64(*value)
+		return &f
+	}
+	add(VitalSystolicBP, float(v.SystolicBP))
+	add(VitalDiastolicBP, float(v.DiastolicBP))
+	add(VitalPulse, float(v.Pulse))
+	add(VitalTemperature, v.Temperature)
+	add(VitalSpO2, float(v.SpO2))
+	add(VitalHeight, v.HeightCm)
+	add(VitalWeight, v.WeightKg)
+	return observations
+}
+
+// Apply sets the vital sign an observation is of. Whole-number signs are
+// rounded; BMI is not kept on encounters.
+func (v *EncounterVitals) Apply(s VitalSign) {
+	whole := func() *int {
+		i := int(math.Round(s.Value))
+		return &i
+	}
+	value := s.Value
+	switch s.Type {
+	case VitalSystolicBP:
+		v.SystolicBP = whole()
+	case VitalDiastolicBP:
+		v.DiastolicBP = whole()
+	case VitalPulse:
+		v.Pulse = whole()
+	case VitalTemperature:
+		v.Temperature = &value
+	case VitalSpO2:
+		v.SpO2 = whole()
+	case VitalHeight:
+		v.HeightCm = &value
+	case VitalWeight:
+		v.WeightKg = &value
+	}
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertVital(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     VitalType
+		value   float64
+		unit    string
+		want    float64
+		wantErr error
+	}{
+		{name: "canonical by default", typ: VitalPulse, value: 72, want: 72},
+		{name: "fahrenheit", typ: VitalTemperature, value: 98.6, unit: "°F", want: 37},
+		{name: "fahrenheit alias", typ: VitalTemperature, value: 103.6, unit: "F", want: 39.78},
+		{name: "celsius alias", typ: VitalTemperature, value: 37.2, unit: "celsius", want: 37.2},
+		{name: "pounds", typ: VitalWeight, value: 154, unit: "lbs", want: 69.85},
+		{name: "inches", typ: VitalHeight, value: 70, unit: "in", want: 177.8},
+		{name: "unit of another type", typ: VitalWeight, value: 70, unit: "cm", wantErr: ErrVitalUnit},
+		{name: "unknown unit", typ: VitalTemperature, value: 310, unit: "K", wantErr: ErrVitalUnit},
+		{name: "unknown type", typ: "glucose", value: 5, wantErr: ErrVitalUnknownType},
+		{name: "celsius given as fahrenheit", typ: VitalTemperature, value: 98.6, wantErr: ErrVitalImplausible},
+		{name: "implausible after conversion", typ: VitalWeight, value: 1200, unit: "lb", wantErr: ErrVitalImplausible},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertVital(tt.typ, tt.value, tt.unit)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 0.01)
+		})
+	}
+}
+
+func TestDisplayVital(t *testing.T) {
+	value, unit := DisplayVital(VitalTemperature, 37, "°F")
+	assert.InDelta(t, 98.6, value, 0.01)
+	assert.Equal(t, "°F", unit)
+
+	value, unit = DisplayVital(VitalWeight, 70, "lb")
+	assert.InDelta(t, 154.32, value, 0.01)
+	assert.Equal(t, "lb", unit)
+
+	value, unit = DisplayVital(VitalPulse, 72, "lb")
+	assert.Equal(t, 72.0, value)
+	assert.Equal(t, "bpm", unit)
+}
+
+func TestFlagVital(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   VitalType
+		value float64
+		age   int
+		want  VitalFlag
+	}{
+		{name: "adult pulse normal", typ: VitalPulse, value: 72, age: 40, want: VitalNormal},
+		{name: "adult pulse high", typ: VitalPulse, value: 130, age: 40, want: VitalHigh},
+		{name: "infant pulse normal", typ: VitalPulse, value: 130, age: 0, want: VitalNormal},
+		{name: "infant pulse low", typ: VitalPulse, value: 80, age: 0, want: VitalLow},
+		{name: "adult systolic high", typ: VitalSystolicBP, value: 150, age: 60, want: VitalHigh},
+		{name: "child systolic high", typ: VitalSystolicBP, value: 125, age: 8, want: VitalHigh},
+		{name: "fever", typ: VitalTemperature, value: 38.5, age: 30, want: VitalHigh},
+		{name: "hypoxia", typ: VitalSpO2, value: 91, age: 70, want: VitalLow},
+		{name: "adult bmi high", typ: VitalBMI, value: 31, age: 45, want: VitalHigh},
+		{name: "child bmi not flagged", typ: VitalBMI, value: 31, age: 10, want: ""},
+		{name: "height not flagged", typ: VitalHeight, value: 180, age: 30, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FlagVital(tt.typ, tt.value, tt.age))
+		})
+	}
+}
+
+func TestAgeAt(t *testing.T) {
+	dob := time.Date(1990, 6, 15, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 35, AgeAt(dob, time.Date(2026, 6, 14, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 36, AgeAt(dob, time.Date(2026, 6, 15, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 0, AgeAt(dob, time.Date(1990, 12, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 0, AgeAt(dob, time.Date(1989, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestBMI(t *testing.T) {
+	assert.Equal(t, 22.9, BMI(175, 70))
+	assert.Equal(t, 30.9, BMI(160, 79))
+}
+
+func TestEncounterVitalsObservations(t *testing.T) {
+	pulse, temperature := 88, 38.2
+	vitals := EncounterVitals{Pulse: &pulse, Temperature: &temperature}
+
+	observations := vitals.Observations()
+	assert.Equal(t, []VitalSign{
+		{Type: VitalPulse, Value: 88, Unit: "bpm"},
+		{Type: VitalTemperature, Value: 38.2, Unit: "°C"},
+	}, observations)
+
+	var applied EncounterVitals
+	for _, observation := range observations {
+		applied.Apply(observation)
+	}
+	applied.Apply(VitalSign{Type: VitalSpO2, Value: 96.6})
+	applied.Apply(VitalSign{Type: VitalBMI, Value: 24})
+	assert.Equal(t, 88, *applied.Pulse)
+	assert.Equal(t, 38.2, *applied.Temperature)
+	assert.Equal(t, 97, *applied.SpO2)
+	assert.Nil(t, applied.WeightKg)
+}
@@ -10,6 +10,11 @@ import (
 func SetupRoutes(r *gin.Engine) {
 	// Public routes
 	r.POST("/login", controllers.Login)
+	r.POST("/login/mfa", controllers.LoginMFA)
+	r.POST("/login/mfa/enroll", controllers.LoginMFAEnroll)
+	r.POST("/auth/refresh", controllers.RefreshToken)
+	r.GET("/.well-known/jwks.json", controllers.JWKS)
+	r.POST("/auth/password/reset", controllers.ResetPassword)
 
 	// Protected routes
 	authorized := r.Group("/")
@@ -17,22 +22,122 @@ func SetupRoutes(r *gin.Engine) {
 
 	// Add validation endpoint
 	authorized.GET("/auth/validate", controllers.ValidateToken)
+	authorized.POST("/auth/logout", controllers.Logout)
+	authorized.POST("/auth/password", controllers.ChangePassword)
 
-	// Receptionist routes
+	// Two-factor authentication
+	authorized.POST("/auth/mfa/enroll", controllers.EnrollMFA)
+	authorized.POST("/auth/mfa/verify", controllers.VerifyMFA)
+	authorized.POST("/auth/mfa/disable", controllers.DisableMFA)
+
+	// Admin routes
+	admin := authorized.Group("/admin")
+	admin.Use(middleware.RequirePermission(models.PermUserManage))
+	{
+		admin.POST("/lockouts/unlock", controllers.UnlockLogin)
+
+		admin.POST("/users", controllers.CreateUser)
+		admin.GET("/users", controllers.GetUsers)
+		admin.GET("/users/:id", controllers.GetUser)
+		admin.PUT("/users/:id", controllers.UpdateUser)
+		admin.PUT("/users/:id/role", controllers.ChangeUserRole)
+		admin.POST("/users/:id/deactivate", controllers.DeactivateUser)
+		admin.POST("/users/:id/activate", controllers.ActivateUser)
+		admin.POST("/users/:id/password-reset", controllers.CreatePasswordReset)
+	}
+
+	// Patient routes shared by all roles. Records outside the caller's care
+	// teams answer 404; a missing permission answers 403.
+	patients := authorized.Group("/patients")
+	{
+		patients.GET("/lookup", middleware.RequirePermission(models.PermPatientRead), controllers.LookupPatients)
+		patients.GET("/:id", middleware.RequirePermission(models.PermPatientRead), controllers.GetPatient)
+		patients.GET("/:id/care-team", middleware.RequirePermission(models.PermPatientRead), controllers.GetCareTeam)
+		patients.POST("/:id/care-team", middleware.RequirePermission(models.PermCareTeamManage), controllers.AssignCareTeamMember)
+		patients.DELETE("/:id/care-team/:userId", middleware.RequirePermission(models.PermCareTeamManage), controllers.UnassignCareTeamMember)
+		patients.GET("/:id/identifiers", middleware.RequirePermission(models.PermPatientRead), controllers.GetPatientIdentifiers)
+		patients.POST("/:id/identifiers", middleware.RequirePermission(models.PermPatientWriteDemographics), controllers.AddPatientIdentifier)
+		patients.DELETE("/:id/identifiers/:identifierId", middleware.RequirePermission(models.PermPatientWriteDemographics), controllers.RemovePatientIdentifier)
+		// Allergies are written by the front desk or clinicians; the handlers
+		// check for either write permission
+		patients.GET("/:id/allergies", middleware.RequirePermission(models.PermPatientRead), controllers.GetAllergies)
+		patients.POST("/:id/allergies", middleware.RequirePermission(models.PermPatientRead), controllers.CreateAllergy)
+		patients.PUT("/:id/allergies/:allergyId", middleware.RequirePermission(models.PermPatientRead), controllers.UpdateAllergy)
+		patients.DELETE("/:id/allergies/:allergyId", middleware.RequirePermission(models.PermPatientRead), controllers.DeleteAllergy)
+		// The problem list is clinical; writing it needs patient:write_clinical,
+		// which only doctors have by default
+		patients.GET("/:id/diagnoses", middleware.RequirePermission(models.PermPatientRead, models.PermPatientReadClinical), controllers.GetDiagnoses)
+		patients.POST("/:id/diagnoses", middleware.RequirePermission(models.PermPatientWriteClinical), controllers.CreateDiagnosis)
+		patients.PUT("/:id/diagnoses/:diagnosisId", middleware.RequirePermission(models.PermPatientWriteClinical), controllers.UpdateDiagnosis)
+		patients.DELETE("/:id/diagnoses/:diagnosisId", middleware.RequirePermission(models.PermPatientWriteClinical), controllers.DeleteDiagnosis)
+		// Encounters are clinical records, written with patient:write_clinical
+		patients.GET("/:id/encounters", middleware.RequirePermission(models.PermPatientRead, models.PermPatientReadClinical), controllers.GetEncounters)
+		patients.GET("/:id/encounters/:encounterId", middleware.RequirePermission(models.PermPatientRead, models.PermPatientReadClinical), controllers.GetEncounter)
+		patients.POST("/:id/encounters", middleware.RequirePermission(models.PermPatientWriteClinical), controllers.OpenEncounter)
+		patients.PUT("/:id/encounters/:encounterId", middleware.RequirePermission(models.PermPatientWriteClinical), controllers.UpdateEncounter)
+		patients.POST("/:id/encounters/:encounterId/close", middleware.RequirePermission(models.PermPatientWriteClinical), controllers.CloseEncounter)
+		// Clinical notes are written, signed, co-signed and amended with
+		// patient:write_clinical
+		patients.GET("/:id/notes", middleware.RequirePermission(models.PermPatientRead, models.PermPatientReadClinical), controllers.GetNotes)
+		patients.GET("/:id/notes/:noteId", middleware.RequirePermission(models.PermPatientRead, models.PermPatientReadClinical), controllers.GetNote)
+		patients.POST("/:id/notes", middleware.RequirePermission(models.PermPatientWriteClinical), controllers.CreateNote)
+		patients.PUT("/:id/notes/:noteId", middleware.RequirePermission(models.PermPatientWriteClinical), controllers.UpdateNote)
+		patients.DELETE("/:id/notes/:noteId", middleware.RequirePermission(models.PermPatientWriteClinical), controllers.DeleteNote)
+		patients.POST("/:id/notes/:noteId/sign", middleware.RequirePermission(models.PermPatientWriteClinical), controllers.SignNote)
+		patients.POST("/:id/notes/:noteId/cosign", middleware.RequirePermission(models.PermPatientWriteClinical), controllers.CosignNote)
+		patients.POST("/:id/notes/:noteId/addenda", middleware.RequirePermission(models.PermPatientWriteClinical), controllers.AddNoteAddendum)
+		// Vital signs are recorded with patient:write_clinical, also through
+		// encounters
+		patients.GET("/:id/vitals", middleware.RequirePermission(models.PermPatientRead, models.PermPatientReadClinical), controllers.GetVitals)
+		patients.POST("/:id/vitals", middleware.RequirePermission(models.PermPatientWriteClinical), controllers.RecordVitals)
+		patients.GET("/:id/duplicates", middleware.RequirePermission(models.PermPatientRead), controllers.GetPatientDuplicates)
+		patients.GET("/:id/merges", middleware.RequirePermission(models.PermPatientRead), controllers.GetPatientMerges)
+		patients.POST("/:id/merge", middleware.RequirePermission(models.PermPatientMerge), controllers.MergePatient)
+		patients.POST("/:id/merges/:mergeId/unmerge", middleware.RequirePermission(models.PermPatientMerge), controllers.UnmergePatient)
+		patients.GET("/:id/history", middleware.RequirePermission(models.PermPatientRead), controllers.GetPatientHistory)
+		patients.GET("/:id/history/:version", middleware.RequirePermission(models.PermPatientRead), controllers.GetPatientVersion)
+		patients.POST("/:id/restore", middleware.RequirePermission(models.PermPatientTrash), controllers.RestorePatient)
+		patients.POST("/:id/break-glass", middleware.RequirePermission(models.PermPatientBreakGlass), controllers.BreakGlass)
+	}
+
+	// Code catalogues
+	authorized.GET("/codes/icd10", controllers.SearchICD10Codes)
+	authorized.GET("/notes/templates", controllers.GetNoteTemplates)
+
+	// Soft-deleted patients
+	authorized.GET("/admin/patients/trash", middleware.RequirePermission(models.PermPatientTrash), controllers.GetPatientTrash)
+
+	// Emergency access review
+	review := authorized.Group("/admin/emergency-access")
+	review.Use(middleware.RequirePermission(models.PermEmergencyReview))
+	{
+		review.GET("", controllers.GetEmergencyAccessReviews)
+		review.POST("/:id/acknowledge", controllers.AcknowledgeEmergencyAccess)
+	}
+
+	// PHI access audit log
+	audit := authorized.Group("/admin/audit")
+	audit.Use(middleware.RequirePermission(models.PermAuditRead))
+	{
+		audit.GET("", controllers.GetAuditEvents)
+		audit.GET("/verify", controllers.VerifyAuditLog)
+	}
+
+	// Receptionist routes. Access is decided by permission, so roles that
+	// are granted the same permissions in PERMISSIONS_FILE may use them too.
 	receptionist := authorized.Group("/receptionist")
-	receptionist.Use(middleware.RoleMiddleware(models.RoleReceptionist))
 	{
-		receptionist.POST("/patients", controllers.CreatePatient)
-		receptionist.GET("/patients", controllers.GetPatients)
+		receptionist.POST("/patients", middleware.RequirePermission(models.PermPatientCreate), controllers.CreatePatient)
+		receptionist.GET("/patients", middleware.RequirePermission(models.PermPatientRead), controllers.GetPatients)
 		receptionist.PUT("/patients/:id", controllers.UpdatePatient)
-		receptionist.DELETE("/patients/:id", controllers.DeletePatient)
+		receptionist.PATCH("/patients/:id", controllers.PatchPatient)
+		receptionist.DELETE("/patients/:id", middleware.RequirePermission(models.PermPatientDelete), controllers.DeletePatient)
 	}
 
 	// Doctor routes
 	doctor := authorized.Group("/doctor")
-	doctor.Use(middleware.RoleMiddleware(models.RoleDoctor))
 	{
-		doctor.GET("/patients", controllers.GetPatients)
-		doctor.PATCH("/patients/:id", controllers.UpdatePatient)
+		doctor.GET("/patients", middleware.RequirePermission(models.PermPatientRead), controllers.GetPatients)
+		doctor.PATCH("/patients/:id", controllers.PatchPatient)
 	}
-} 
\ No newline at end of file
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+var (
+	cursorOnce sync.Once
+	cursorAEAD cipher.AEAD
+	cursorErr  error
+)
+
+// cursorCipher returns the AES-GCM cipher cursors are sealed with. Its key
+// is derived from CURSOR_SECRET; without it a random key is used, and
+// cursors stop working when the server restarts.
+func cursorCipher() (cipher.AEAD, error) {
+	cursorOnce.Do(func() {
+		var key [32]byte
+		if secret := os.Getenv("CURSOR_SECRET"); secret != "" {
+			key = sha256.Sum256([]byte(secret))
+		} else if _, cursorErr = rand.Read(key[:]); cursorErr != nil {
+			return
+		}
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			cursorErr = err
+			return
+		}
+		cursorAEAD, cursorErr = cipher.NewGCM(block)
+	})
+	return cursorAEAD, cursorErr
+}
+
+// EncodeCursor serialises a pagination position into an opaque URL-safe
+// token. The position is encrypted, since it holds the sort values of a
+// row, and authenticated, so that a tampered cursor is rejected.
+func EncodeCursor(position interface{}) (string, error) {
+	aead, err := cursorCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(position)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, data, nil)), nil
+}
+
+// DecodeCursor reads a token made by EncodeCursor into position
+func DecodeCursor(token string, position interface{}) error {
+	aead, err := cursorCipher()
+	if err != nil {
+		return err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return errInvalidCursor
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	data, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return errInvalidCursor
+	}
+	return json.Unmarshal(data, position)
+}
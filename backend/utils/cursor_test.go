@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCursorPosition struct {
+	Values []interface{} `json:"v"`
+	Prev   bool          `json:"p"`
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	token, err := EncodeCursor(testCursorPosition{Values: []interface{}{"Smith", 0.25, float64(42)}, Prev: true})
+	require.NoError(t, err)
+	assert.NotContains(t, token, "=", "cursor should not need URL escaping")
+
+	// The decoded token must not reveal the position
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "Smith")
+	assert.NotContains(t, string(raw), `"v"`)
+
+	var got testCursorPosition
+	require.NoError(t, DecodeCursor(token, &got))
+	assert.Equal(t, []interface{}{"Smith", 0.25, float64(42)}, got.Values)
+	assert.True(t, got.Prev)
+}
+
+func TestCursorTampered(t *testing.T) {
+	token, err := EncodeCursor(testCursorPosition{Values: []interface{}{float64(42)}})
+	require.NoError(t, err)
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	require.NoError(t, err)
+	raw[len(raw)-1] ^= 1
+
+	var got testCursorPosition
+	assert.Error(t, DecodeCursor(base64.RawURLEncoding.EncodeToString(raw), &got))
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	var got map[string]interface{}
+	assert.Error(t, DecodeCursor("not base64!", &got))
+	assert.Error(t, DecodeCursor("bm90IGpzb24", &got), "valid base64 but not a sealed cursor")
+
+	// An unsigned cursor as issued before they were encrypted
+	assert.Error(t, DecodeCursor(base64.RawURLEncoding.EncodeToString([]byte(`{"v":[42],"p":false,"k":"id"}`)), &got))
+}
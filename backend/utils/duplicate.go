@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Weights of each signal in a duplicate score. A name match alone scores at
+// most nameWeight, so a likely duplicate also needs a matching date of
+// birth, phone or email.
+const (
+	nameWeight  = 0.45
+	dobWeight   = 0.35
+	phoneWeight = 0.2
+	emailWeight = 0.2
+)
+
+// minPhoneDigits is the shortest number compared; shorter ones are usually
+// extensions or typos
+const minPhoneDigits = 7
+
+// PersonDetails are the patient details compared for duplicate detection
+type PersonDetails struct {
+	FirstName   string
+	LastName    string
+	DateOfBirth time.Time
+	Phone       string
+	Email       string
+}
+
+// DuplicateMatch is how likely two people are the same, from 0 to 1, and the
+// signals that contributed
+type DuplicateMatch struct {
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// ScoreDuplicate compares two people. Names are compared with Jaro-Winkler
+// similarity, also with first and last name swapped; a date of birth with
+// day and month swapped counts for part of a match.
+func ScoreDuplicate(a, b PersonDetails) DuplicateMatch {
+	var match DuplicateMatch
+
+	name := NameSimilarity(a.FirstName, a.LastName, b.FirstName, b.LastName)
+	match.Score += nameWeight * name
+	switch {
+	case name == 1:
+		match.Reasons = append(match.Reasons, "same name")
+	case name >= 0.85:
+		match.Reasons = append(match.Reasons, "similar name")
+	}
+
+	if !a.DateOfBirth.IsZero() && !b.DateOfBirth.IsZero() {
+		ay, am, ad := a.DateOfBirth.Date()
+		by, bm, bd := b.DateOfBirth.Date()
+		switch {
+		case ay == by && am == bm && ad == bd:
+			match.Score += dobWeight
+			match.Reasons = append(match.Reasons, "same date of birth")
+		case ay == by && int(am) == bd && ad == int(bm):
+			match.Score += dobWeight / 2
+			match.Reasons = append(match.Reasons, "date of birth with day and month swapped")
+		}
+	}
+
+	if phone := NormalizePhone(a.Phone); len(phone) >= minPhoneDigits && phone == NormalizePhone(b.Phone) {
+		match.Score += phoneWeight
+		match.Reasons = append(match.Reasons, "same phone")
+	}
+
+	if email := strings.ToLower(strings.TrimSpace(a.Email)); email != "" && email == strings.ToLower(strings.TrimSpace(b.Email)) {
+		match.Score += emailWeight
+		match.Reasons = append(match.Reasons, "same email")
+	}
+
+	match.Score = math.Min(1, math.Round(match.Score*100)/100)
+	return match
+}
+
+// NormalizePhone keeps the digits of a phone number, dropping a country
+// code by keeping at most the last 10
+func NormalizePhone(phone string) string {
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	normalized := digits.String()
+	if len(normalized) > 10 {
+		normalized = normalized[len(normalized)-10:]
+	}
+	return normalized
+}
+
+// normalizeName lower-cases a name and drops everything but letters
+func normalizeName(name string) string {
+	var letters strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) {
+			letters.WriteRune(r)
+		}
+	}
+	return letters.String()
+}
+
+// NameSimilarity compares two full names, also with the second one's first
+// and last name swapped, and returns the better similarity
+func NameSimilarity(firstA, lastA, firstB, lastB string) float64 {
+	firstA, lastA = normalizeName(firstA), normalizeName(lastA)
+	firstB, lastB = normalizeName(firstB), normalizeName(lastB)
+	direct := (JaroWinkler(firstA, firstB) + JaroWinkler(lastA, lastB)) / 2
+	swapped := (JaroWinkler(firstA, lastB) + JaroWinkler(lastA, firstB)) / 2
+	return math.Max(direct, swapped)
+}
+
+// JaroWinkler returns the Jaro-Winkler similarity of two strings, from 0
+// (nothing in common) to 1 (equal)
+func JaroWinkler(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	window := max(len(ra), len(rb))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	matchedA := make([]bool, len(ra))
+	matchedB := make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		lo, hi := max(0, i-window), min(len(rb), i+window+1)
+		for j := lo; j < hi; j++ {
+			if !matchedB[j] && ra[i] == rb[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+	j := 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if ra[i] != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(ra), len(rb)) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
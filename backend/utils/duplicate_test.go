@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJaroWinkler(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"martha", "marhta", 0.961},
+		{"dwayne", "duane", 0.84},
+		{"dixon", "dicksonx", 0.813},
+		{"same", "same", 1},
+		{"abc", "xyz", 0},
+		{"", "", 1},
+		{"abc", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			assert.InDelta(t, tt.want, JaroWinkler(tt.a, tt.b), 0.001)
+		})
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	assert.Equal(t, "5551234567", NormalizePhone("(555) 123-4567"))
+	assert.Equal(t, "5551234567", NormalizePhone("+1 555 123 4567"))
+	assert.Equal(t, "9876543210", NormalizePhone("0091-98765-43210"))
+	assert.Equal(t, "", NormalizePhone("n/a"))
+}
+
+func TestNameSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, NameSimilarity("John", "Smith", "john", "SMITH"))
+	assert.Equal(t, 1.0, NameSimilarity("John", "Smith", "Smith", "John"), "swapped first and last name")
+	assert.Greater(t, NameSimilarity("Jon", "Smyth", "John", "Smith"), 0.85)
+	assert.Less(t, NameSimilarity("Alice", "Brown", "John", "Smith"), 0.6)
+}
+
+func TestScoreDuplicate(t *testing.T) {
+	dob := time.Date(1990, time.March, 4, 0, 0, 0, 0, time.UTC)
+	base := PersonDetails{FirstName: "John", LastName: "Smith", DateOfBirth: dob, Phone: "555-123-4567", Email: "john@example.com"}
+
+	tests := []struct {
+		name        string
+		other       PersonDetails
+		minScore    float64
+		maxScore    float64
+		wantReasons []string
+	}{
+		{
+			name:        "identical",
+			other:       base,
+			minScore:    1,
+			maxScore:    1,
+			wantReasons: []string{"same name", "same date of birth", "same phone", "same email"},
+		},
+		{
+			name:        "typo in name, same birth date, formatted phone",
+			other:       PersonDetails{FirstName: "Jon", LastName: "Smyth", DateOfBirth: dob, Phone: "+1 (555) 123 4567"},
+			minScore:    0.9,
+			maxScore:    0.97,
+			wantReasons: []string{"similar name", "same date of birth", "same phone"},
+		},
+		{
+			name:        "day and month swapped",
+			other:       PersonDetails{FirstName: "John", LastName: "Smith", DateOfBirth: time.Date(1990, time.April, 3, 0, 0, 0, 0, time.UTC)},
+			minScore:    0.62,
+			maxScore:    0.63,
+			wantReasons: []string{"same name", "date of birth with day and month swapped"},
+		},
+		{
+			name:        "same name only",
+			other:       PersonDetails{FirstName: "John", LastName: "Smith", DateOfBirth: dob.AddDate(-30, 0, 0), Phone: "555-000-0000"},
+			minScore:    0.45,
+			maxScore:    0.45,
+			wantReasons: []string{"same name"},
+		},
+		{
+			name:     "different person",
+			other:    PersonDetails{FirstName: "Alice", LastName: "Brown", DateOfBirth: dob.AddDate(1, 0, 0), Phone: "12"},
+			minScore: 0,
+			maxScore: 0.3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := ScoreDuplicate(base, tt.other)
+			assert.GreaterOrEqual(t, match.Score, tt.minScore)
+			assert.LessOrEqual(t, match.Score, tt.maxScore)
+			assert.Equal(t, tt.wantReasons, match.Reasons)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ETag returns the entity tag for a record version
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// MatchesETag reports whether an If-Match header value names the given
+// version. It accepts a comma-separated list of tags and compares them
+// strongly: weak tags and "*" never match, since they cannot prove the
+// client saw this exact version.
+func MatchesETag(header string, version int) bool {
+	want := ETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimSpace(tag) == want {
+			return true
+		}
+	}
+	return false
+}
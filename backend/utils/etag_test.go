@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesETag(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		version int
+		want    bool
+	}{
+		{name: "exact", header: `"3"`, version: 3, want: true},
+		{name: "list", header: `"1", "3"`, version: 3, want: true},
+		{name: "weak", header: `W/"3"`, version: 3, want: false},
+		{name: "weak in list", header: `"1", W/"3"`, version: 3, want: false},
+		{name: "wildcard", header: "*", version: 7, want: false},
+		{name: "wildcard in list", header: `"1", *`, version: 7, want: false},
+		{name: "stale", header: `"2"`, version: 3, want: false},
+		{name: "unquoted", header: "3", version: 3, want: false},
+		{name: "empty", header: "", version: 3, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, MatchesETag(tt.header, tt.version))
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package utils
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// bundledICD10 is the catalogue of common ICD-10-CM codes shipped with the
+// server. ICD10_CATALOGUE_FILE can point to a full catalogue instead.
+//
+//go:embed data/icd10.csv
+var bundledICD10 string
+
+var icd10CodePattern = regexp.MustCompile(`^[A-Z][0-9][0-9A-Z](\.[0-9A-Z]{1,4})?$`)
+
+// ICD10Code is one entry of the ICD-10 catalogue
+type ICD10Code struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// ICD10Catalogue is an in-memory ICD-10 code list, sorted by code
+type ICD10Catalogue struct {
+	codes  []ICD10Code
+	byCode map[string]int
+}
+
+// NormalizeICD10Code upper-cases a code and adds the dot after the category,
+// so that "e119" and "E11.9" are the same code
+func NormalizeICD10Code(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) > 3 && !strings.Contains(code, ".") {
+		code = code[:3] + "." + code[3:]
+	}
+	return code
+}
+
+// ValidICD10Code reports whether code is shaped like an ICD-10 code
+func ValidICD10Code(code string) bool {
+	return icd10CodePattern.MatchString(code)
+}
+
+// ParseICD10Catalogue reads a catalogue from CSV with a code,description
+// header
+func ParseICD10Catalogue(r io.Reader) (*ICD10Catalogue, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	if len(header) < 2 || strings.TrimSpace(header[0]) != "code" || strings.TrimSpace(header[1]) != "description" {
+		return nil, errors.New("header must be code,description")
+	}
+
+	catalogue := &ICD10Catalogue{byCode: make(map[string]int)}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		code := NormalizeICD10Code(record[0])
+		if !ValidICD10Code(code) {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: invalid code %q", line, record[0])
+		}
+		if _, exists := catalogue.byCode[code]; exists {
+			continue
+		}
+		catalogue.byCode[code] = len(catalogue.codes)
+		catalogue.codes = append(catalogue.codes, ICD10Code{Code: code, Description: strings.TrimSpace(record[1])})
+	}
+
+	sort.Slice(catalogue.codes, func(i, j int) bool { return catalogue.codes[i].Code < catalogue.codes[j].Code })
+	for i, code := range catalogue.codes {
+		catalogue.byCode[code.Code] = i
+	}
+	return catalogue, nil
+}
+
+// Len returns the number of codes in the catalogue
+func (c *ICD10Catalogue) Len() int {
+	return len(c.codes)
+}
+
+// Lookup finds a code, in any spelling NormalizeICD10Code accepts
+func (c *ICD10Catalogue) Lookup(code string) (ICD10Code, bool) {
+	i, ok := c.byCode[NormalizeICD10Code(code)]
+	if !ok {
+		return ICD10Code{}, false
+	}
+	return c.codes[i], true
+}
+
+// Search returns up to limit codes matching a query: codes starting with it
+// first (the dot is optional), then codes whose description contains every
+// word of it, case-insensitively
+func (c *ICD10Catalogue) Search(query string, limit int) []ICD10Code {
+	query = strings.TrimSpace(query)
+	results := []ICD10Code{}
+	if query == "" || limit < 1 {
+		return results
+	}
+
+	prefix := strings.ReplaceAll(strings.ToUpper(query), ".", "")
+	words := strings.Fields(strings.ToLower(query))
+	var described []ICD10Code
+	for _, code := range c.codes {
+		if strings.HasPrefix(strings.ReplaceAll(code.Code, ".", ""), prefix) {
+			results = append(results, code)
+			if len(results) == limit {
+				return results
+			}
+			continue
+		}
+		if len(described) < limit && containsAll(strings.ToLower(code.Description), words) {
+			described = append(described, code)
+		}
+	}
+	results = append(results, described...)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func containsAll(text string, words []string) bool {
+	for _, word := range words {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	icd10Mu     sync.Mutex
+	activeICD10 *ICD10Catalogue
+)
+
+// LoadICD10Catalogue reads the catalogue in ICD10_CATALOGUE_FILE, or the
+// bundled one when it is not set
+func LoadICD10Catalogue() (*ICD10Catalogue, error) {
+	var source io.Reader = strings.NewReader(bundledICD10)
+	if path := os.Getenv("ICD10_CATALOGUE_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		source = file
+	}
+
+	catalogue, err := ParseICD10Catalogue(source)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ICD-10 catalogue: %w", err)
+	}
+	if catalogue.Len() == 0 {
+		return nil, errors.New("ICD-10 catalogue has no codes")
+	}
+	return catalogue, nil
+}
+
+// SetICD10Catalogue replaces the catalogue returned by CurrentICD10Catalogue
+func SetICD10Catalogue(catalogue *ICD10Catalogue) {
+	icd10Mu.Lock()
+	defer icd10Mu.Unlock()
+	activeICD10 = catalogue
+}
+
+// CurrentICD10Catalogue returns the configured catalogue, or the bundled one
+// when none was loaded
+func CurrentICD10Catalogue() *ICD10Catalogue {
+	icd10Mu.Lock()
+	defer icd10Mu.Unlock()
+
+	if activeICD10 == nil {
+		catalogue, err := ParseICD10Catalogue(strings.NewReader(bundledICD10))
+		if err != nil {
+			panic("bundled ICD-10 catalogue: " + err.Error())
+		}
+		activeICD10 = catalogue
+	}
+	return activeICD10
+}
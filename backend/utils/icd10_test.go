@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testICD10 = `code,description
+J45.909,"Unspecified asthma, uncomplicated"
+E11.9,Type 2 diabetes mellitus without complications
+E11.65,Type 2 diabetes mellitus with hyperglycemia
+E10.9,Type 1 diabetes mellitus without complications
+I10,Essential (primary) hypertension
+O24.419,"Gestational diabetes mellitus in pregnancy, unspecified control"
+`
+
+func TestNormalizeICD10Code(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"E11.9", "E11.9"},
+		{" e119 ", "E11.9"},
+		{"i10", "I10"},
+		{"s060x0a", "S06.0X0A"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, NormalizeICD10Code(tt.code), tt.code)
+	}
+
+	assert.True(t, ValidICD10Code("S06.0X0A"))
+	assert.False(t, ValidICD10Code("11.9"))
+	assert.False(t, ValidICD10Code("E11."))
+}
+
+func TestParseICD10Catalogue(t *testing.T) {
+	catalogue, err := ParseICD10Catalogue(strings.NewReader(testICD10))
+	require.NoError(t, err)
+	assert.Equal(t, 6, catalogue.Len())
+
+	code, ok := catalogue.Lookup("e1165")
+	assert.True(t, ok)
+	assert.Equal(t, "Type 2 diabetes mellitus with hyperglycemia", code.Description)
+
+	_, ok = catalogue.Lookup("E11.8")
+	assert.False(t, ok)
+
+	_, err = ParseICD10Catalogue(strings.NewReader("name,text\nE11.9,Diabetes\n"))
+	assert.Error(t, err, "wrong header")
+	_, err = ParseICD10Catalogue(strings.NewReader("code,description\n119,Diabetes\n"))
+	assert.Error(t, err, "invalid code")
+}
+
+func TestICD10Search(t *testing.T) {
+	catalogue, err := ParseICD10Catalogue(strings.NewReader(testICD10))
+	require.NoError(t, err)
+
+	codes := func(results []ICD10Code) []string {
+		out := []string{}
+		for _, result := range results {
+			out = append(out, result.Code)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []string
+	}{
+		{name: "code prefix", query: "e11", limit: 10, want: []string{"E11.65", "E11.9"}},
+		{name: "code prefix without dot", query: "E116", limit: 10, want: []string{"E11.65"}},
+		{name: "description words", query: "Diabetes type 2", limit: 10, want: []string{"E11.65", "E11.9"}},
+		{name: "description in code order", query: "diabetes", limit: 10, want: []string{"E10.9", "E11.65", "E11.9", "O24.419"}},
+		{name: "limit", query: "diabetes", limit: 2, want: []string{"E10.9", "E11.65"}},
+		{name: "no match", query: "fracture", limit: 10, want: []string{}},
+		{name: "empty query", query: "  ", limit: 10, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, codes(catalogue.Search(tt.query, tt.limit)))
+		})
+	}
+}
+
+func TestBundledICD10Catalogue(t *testing.T) {
+	catalogue, err := LoadICD10Catalogue()
+	require.NoError(t, err)
+	assert.Greater(t, catalogue.Len(), 100)
+
+	_, ok := catalogue.Lookup("I10")
+	assert.True(t, ok)
+}
@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"os"
 	"time"
@@ -9,31 +13,125 @@ import (
 	"github.com/medibridge/models"
 )
 
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 7 * 24 * time.Hour
+	mfaTokenTTL            = 5 * time.Minute
+)
+
+// Purposes of MFA challenge tokens issued by the first login step
+const (
+	PurposeMFAChallenge  = "mfa"
+	PurposeMFAEnrollment = "mfa_enroll"
+)
+
 type Claims struct {
-	UserID uint
-	Role   models.UserRole
+	UserID    uint
+	Role      models.UserRole
+	SessionID string `json:"sid"`
+	// Purpose is empty for access tokens and set for single-purpose tokens
+	// such as MFA challenges, which must never be accepted as access tokens
+	Purpose string `json:"pur,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(user *models.User) (string, error) {
+// AccessTokenTTL returns the lifetime of access tokens (ACCESS_TOKEN_TTL, default 15m)
+func AccessTokenTTL() time.Duration {
+	return DurationFromEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL)
+}
+
+// RefreshTokenTTL returns the lifetime of a login session (REFRESH_TOKEN_TTL, default 7 days)
+func RefreshTokenTTL() time.Duration {
+	return DurationFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+}
+
+// DurationFromEnv reads a positive duration such as "15m" from an environment
+// variable, or returns fallback when it is unset or invalid
+func DurationFromEnv(key string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+	}
+	return fallback
+}
+
+func GenerateToken(user *models.User, sessionID string) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		UserID: user.ID,
-		Role:   user.Role,
+		UserID:    user.ID,
+		Role:      user.Role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL())),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	keyRing, err := CurrentKeyRing()
+	if err != nil {
+		return "", err
+	}
+	return keyRing.Sign(claims)
+}
+
+// GenerateMFAToken issues a short-lived token proving the password step of a
+// login succeeded. It can only be exchanged via the second login step.
+func GenerateMFAToken(user *models.User, purpose string) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID:  user.ID,
+		Role:    user.Role,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	keyRing, err := CurrentKeyRing()
+	if err != nil {
+		return "", err
+	}
+	return keyRing.Sign(claims)
+}
+
+// ValidateMFAToken validates an MFA challenge token issued by GenerateMFAToken
+func ValidateMFAToken(tokenString string) (*Claims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != PurposeMFAChallenge && claims.Purpose != PurposeMFAEnrollment {
+		return nil, errors.New("not an MFA token")
+	}
+
+	return claims, nil
 }
 
 func ValidateToken(tokenString string) (*Claims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != "" {
+		return nil, errors.New("not an access token")
+	}
+
+	return claims, nil
+}
+
+func parseToken(tokenString string) (*Claims, error) {
+	keyRing, err := CurrentKeyRing()
+	if err != nil {
+		return nil, err
+	}
+
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, keyRing.Keyfunc,
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgEdDSA}))
 
 	if err != nil {
 		return nil, err
@@ -44,4 +142,19 @@ func ValidateToken(tokenString string) (*Claims, error) {
 	}
 
 	return claims, nil
-} 
\ No newline at end of file
+}
+
+// GenerateOpaqueToken returns a random URL-safe token with n bytes of entropy
+func GenerateOpaqueToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex SHA-256 of an opaque token for storage and lookup
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
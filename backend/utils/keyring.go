@@ -0,0 +1,333 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultJWTSecret is the development-only secret used when JWT_SECRET is not set
+const DefaultJWTSecret = "medibridge-secret-key-2024"
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+
+	defaultKeyGracePeriod = time.Hour
+)
+
+// SigningKey is one entry of the key ring. Retired keys are no longer used to
+// sign but keep validating tokens until RetiredAt plus the grace period.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	RetiredAt *time.Time
+
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// KeyRing holds the keys used to sign and validate access tokens
+type KeyRing struct {
+	mu          sync.RWMutex
+	keys        map[string]*SigningKey
+	activeID    string
+	gracePeriod time.Duration
+}
+
+// NewKeyRing builds a key ring that signs with the key identified by activeID
+func NewKeyRing(activeID string, gracePeriod time.Duration, keys ...*SigningKey) (*KeyRing, error) {
+	kr := &KeyRing{keys: make(map[string]*SigningKey), gracePeriod: gracePeriod}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("signing key is missing a kid")
+		}
+		if _, exists := kr.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate signing key %q", key.ID)
+		}
+		if key.method() == nil {
+			return nil, fmt.Errorf("signing key %q uses unsupported algorithm %q", key.ID, key.Algorithm)
+		}
+		kr.keys[key.ID] = key
+	}
+
+	active, ok := kr.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active signing key %q not found", activeID)
+	}
+	if active.RetiredAt != nil {
+		return nil, fmt.Errorf("active signing key %q is retired", activeID)
+	}
+	kr.activeID = activeID
+
+	return kr, nil
+}
+
+// NewHMACKey creates an HS256 key from a shared secret
+func NewHMACKey(kid string, secret []byte) *SigningKey {
+	return &SigningKey{ID: kid, Algorithm: AlgHS256, signKey: secret, verifyKey: secret}
+}
+
+// NewPrivateKey creates an RS256 or EdDSA key from a parsed private key
+func NewPrivateKey(kid string, privateKey crypto.PrivateKey) (*SigningKey, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{ID: kid, Algorithm: AlgRS256, signKey: key, verifyKey: &key.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: kid, Algorithm: AlgEdDSA, signKey: key, verifyKey: key.Public()}, nil
+	default:
+		return nil, fmt.Errorf("signing key %q: unsupported private key type %T", kid, privateKey)
+	}
+}
+
+// Sign signs the claims with the active key and sets the kid header
+func (kr *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	kr.mu.RLock()
+	key := kr.keys[kr.activeID]
+	kr.mu.RUnlock()
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
+}
+
+// Keyfunc resolves the validation key for a token from its kid header. The
+// token's alg must match the algorithm the key was registered with.
+func (kr *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid header")
+	}
+
+	kr.mu.RLock()
+	key, ok := kr.keys[kid]
+	var retiredAt *time.Time
+	if ok {
+		retiredAt = key.RetiredAt
+	}
+	grace := kr.gracePeriod
+	kr.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("token alg %q does not match key %q", token.Method.Alg(), kid)
+	}
+
+	if retiredAt != nil && time.Now().After(retiredAt.Add(grace)) {
+		return nil, fmt.Errorf("signing key %q is retired", kid)
+	}
+
+	return key.verifyKey, nil
+}
+
+// Rotate makes kid the active signing key and retires the previous one
+func (kr *KeyRing) Rotate(kid string) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	next, ok := kr.keys[kid]
+	if !ok {
+		return fmt.Errorf("signing key %q not found", kid)
+	}
+	if kid == kr.activeID {
+		return nil
+	}
+
+	now := time.Now()
+	kr.keys[kr.activeID].RetiredAt = &now
+	next.RetiredAt = nil
+	kr.activeID = kid
+	return nil
+}
+
+// ActiveKeyID returns the kid used for newly issued tokens
+func (kr *KeyRing) ActiveKeyID() string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.activeID
+}
+
+// JWK is a single public key in a JSON Web Key Set
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS returns the public keys that can still validate tokens. Symmetric
+// keys are never published.
+func (kr *KeyRing) JWKS() []JWK {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	now := time.Now()
+	keys := []JWK{}
+	for _, key := range kr.keys {
+		if key.RetiredAt != nil && now.After(key.RetiredAt.Add(kr.gracePeriod)) {
+			continue
+		}
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Algorithm,
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Algorithm,
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return keys
+}
+
+// keyRingFile is the on-disk format of JWT_KEYRING_FILE
+type keyRingFile struct {
+	Active string `json:"active"`
+	Keys   []struct {
+		Kid            string     `json:"kid"`
+		PrivateKeyFile string     `json:"privateKeyFile"`
+		SecretEnv      string     `json:"secretEnv"`
+		RetiredAt      *time.Time `json:"retiredAt"`
+	} `json:"keys"`
+}
+
+var (
+	keyRingMu     sync.Mutex
+	activeKeyRing *KeyRing
+)
+
+// LoadKeyRing builds the key ring from the environment. When JWT_KEYRING_FILE
+// is set the keys are read from that file, otherwise JWT_SECRET is used as a
+// single HS256 key.
+func LoadKeyRing() (*KeyRing, error) {
+	grace := DurationFromEnv("JWT_KEY_GRACE_PERIOD", defaultKeyGracePeriod)
+
+	path := os.Getenv("JWT_KEYRING_FILE")
+	if path == "" {
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			return nil, errors.New("neither JWT_KEYRING_FILE nor JWT_SECRET is set")
+		}
+		return NewKeyRing("default", grace, NewHMACKey("default", []byte(secret)))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key ring: %w", err)
+	}
+
+	var file keyRingFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing key ring: %w", err)
+	}
+
+	var keys []*SigningKey
+	for _, entry := range file.Keys {
+		var key *SigningKey
+		switch {
+		case entry.PrivateKeyFile != "":
+			keyPath := entry.PrivateKeyFile
+			if !filepath.IsAbs(keyPath) {
+				keyPath = filepath.Join(filepath.Dir(path), keyPath)
+			}
+			privateKey, err := readPrivateKey(keyPath)
+			if err != nil {
+				return nil, fmt.Errorf("signing key %q: %w", entry.Kid, err)
+			}
+			if key, err = NewPrivateKey(entry.Kid, privateKey); err != nil {
+				return nil, err
+			}
+		case entry.SecretEnv != "":
+			secret := os.Getenv(entry.SecretEnv)
+			if secret == "" {
+				return nil, fmt.Errorf("signing key %q: %s is not set", entry.Kid, entry.SecretEnv)
+			}
+			key = NewHMACKey(entry.Kid, []byte(secret))
+		default:
+			return nil, fmt.Errorf("signing key %q has neither privateKeyFile nor secretEnv", entry.Kid)
+		}
+		key.RetiredAt = entry.RetiredAt
+		keys = append(keys, key)
+	}
+
+	return NewKeyRing(file.Active, grace, keys...)
+}
+
+func readPrivateKey(path string) (crypto.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// SetKeyRing replaces the key ring used by GenerateToken and ValidateToken
+func SetKeyRing(kr *KeyRing) {
+	keyRingMu.Lock()
+	defer keyRingMu.Unlock()
+	activeKeyRing = kr
+}
+
+// CurrentKeyRing returns the configured key ring, loading it from the
+// environment on first use.
+func CurrentKeyRing() (*KeyRing, error) {
+	keyRingMu.Lock()
+	defer keyRingMu.Unlock()
+
+	if activeKeyRing == nil {
+		kr, err := LoadKeyRing()
+		if err != nil {
+			return nil, err
+		}
+		activeKeyRing = kr
+	}
+	return activeKeyRing, nil
+}
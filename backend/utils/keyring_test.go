@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/medibridge/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRingRotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	oldKey, err := NewPrivateKey("2026-09", rsaKey)
+	require.NoError(t, err)
+	newKey, err := NewPrivateKey("2026-10", edKey)
+	require.NoError(t, err)
+
+	keyRing, err := NewKeyRing("2026-09", time.Hour, oldKey, newKey)
+	require.NoError(t, err)
+	SetKeyRing(keyRing)
+	defer SetKeyRing(nil)
+
+	user := &models.User{ID: 7, Role: models.RoleDoctor}
+	oldToken, err := GenerateToken(user, "session-1")
+	require.NoError(t, err)
+
+	require.NoError(t, keyRing.Rotate("2026-10"))
+	newToken, err := GenerateToken(user, "session-1")
+	require.NoError(t, err)
+
+	// Tokens signed with the retired key keep validating during the grace window
+	claims, err := ValidateToken(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, uint(7), claims.UserID)
+
+	claims, err = ValidateToken(newToken)
+	require.NoError(t, err)
+	assert.Equal(t, "session-1", claims.SessionID)
+
+	jwks := keyRing.JWKS()
+	assert.Len(t, jwks, 2)
+
+	// After the grace window the retired key is rejected and unpublished
+	past := time.Now().Add(-2 * time.Hour)
+	oldKey.RetiredAt = &past
+	_, err = ValidateToken(oldToken)
+	assert.Error(t, err)
+	assert.Len(t, keyRing.JWKS(), 1)
+}
+
+func TestKeyRingRejectsAlgorithmMismatch(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	key, err := NewPrivateKey("rsa", rsaKey)
+	require.NoError(t, err)
+
+	keyRing, err := NewKeyRing("rsa", time.Hour, key)
+	require.NoError(t, err)
+	SetKeyRing(keyRing)
+	defer SetKeyRing(nil)
+
+	// An HS256 token keyed with the public modulus must not validate
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: 1})
+	token.Header["kid"] = "rsa"
+	forged, err := token.SignedString(rsaKey.PublicKey.N.Bytes())
+	require.NoError(t, err)
+
+	_, err = ValidateToken(forged)
+	assert.Error(t, err)
+
+	// Tokens without a kid are rejected
+	token = jwt.NewWithClaims(jwt.SigningMethodRS256, &Claims{UserID: 1})
+	unkeyed, err := token.SignedString(rsaKey)
+	require.NoError(t, err)
+
+	_, err = ValidateToken(unkeyed)
+	assert.Error(t, err)
+}
@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/medibridge/models"
+	"gorm.io/gorm"
+)
+
+// AttemptRecord tracks failed login attempts for one key (an account or an IP)
+type AttemptRecord struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// AttemptStore persists attempt records. Implementations must be safe for
+// concurrent use.
+type AttemptStore interface {
+	Get(key string) (AttemptRecord, bool, error)
+	Save(key string, record AttemptRecord) error
+	Delete(key string) error
+}
+
+// LockoutPolicy configures backoff and lockout for one kind of key
+type LockoutPolicy struct {
+	// BackoffAfter is the number of failures after which every further
+	// failure imposes an exponentially growing delay
+	BackoffAfter int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// MaxFailures locks the key for LockoutDuration
+	MaxFailures     int
+	LockoutDuration time.Duration
+	// ResetAfter forgets failures when no attempt failed for that long
+	ResetAfter time.Duration
+}
+
+// delay returns how long a key is blocked after reaching failures
+func (p LockoutPolicy) delay(failures int) time.Duration {
+	if p.MaxFailures > 0 && failures >= p.MaxFailures {
+		return p.LockoutDuration
+	}
+	if failures < p.BackoffAfter {
+		return 0
+	}
+
+	d := p.BaseDelay
+	for i := p.BackoffAfter; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// LoginGuard counts failed logins per account and per IP
+type LoginGuard struct {
+	mu            sync.Mutex
+	store         AttemptStore
+	accountPolicy LockoutPolicy
+	ipPolicy      LockoutPolicy
+	now           func() time.Time
+}
+
+// DefaultAccountPolicy locks an account for 15 minutes after 5 failures
+var DefaultAccountPolicy = LockoutPolicy{
+	BackoffAfter:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	MaxFailures:     5,
+	LockoutDuration: 15 * time.Minute,
+	ResetAfter:      time.Hour,
+}
+
+// DefaultIPPolicy tolerates more failures from one address, e.g. a shared front desk
+var DefaultIPPolicy = LockoutPolicy{
+	BackoffAfter:    10,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	MaxFailures:     50,
+	LockoutDuration: 15 * time.Minute,
+	ResetAfter:      time.Hour,
+}
+
+func NewLoginGuard(store AttemptStore, accountPolicy, ipPolicy LockoutPolicy) *LoginGuard {
+	return &LoginGuard{store: store, accountPolicy: accountPolicy, ipPolicy: ipPolicy, now: time.Now}
+}
+
+func accountKey(email string) string { return "account:" + email }
+func ipKey(ip string) string         { return "ip:" + ip }
+
+// Check returns how long the caller must wait before another attempt for
+// this account or IP is allowed. Zero means the attempt may proceed.
+func (g *LoginGuard) Check(email, ip string) (time.Duration, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	var wait time.Duration
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		record, ok, err := g.store.Get(key)
+		if err != nil {
+			return 0, err
+		}
+		if ok && record.LockedUntil.After(now) {
+			if d := record.LockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait, nil
+}
+
+// Fail records a failed attempt and returns the resulting wait time
+func (g *LoginGuard) Fail(email, ip string) (time.Duration, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	var wait time.Duration
+	for _, entry := range []struct {
+		key    string
+		policy LockoutPolicy
+	}{
+		{accountKey(email), g.accountPolicy},
+		{ipKey(ip), g.ipPolicy},
+	} {
+		record, ok, err := g.store.Get(entry.key)
+		if err != nil {
+			return 0, err
+		}
+		if !ok || (entry.policy.ResetAfter > 0 && now.Sub(record.LastFailure) > entry.policy.ResetAfter) {
+			record = AttemptRecord{}
+		}
+
+		record.Failures++
+		record.LastFailure = now
+		if d := entry.policy.delay(record.Failures); d > 0 {
+			record.LockedUntil = now.Add(d)
+			if d > wait {
+				wait = d
+			}
+		}
+
+		if err := g.store.Save(entry.key, record); err != nil {
+			return 0, err
+		}
+	}
+	return wait, nil
+}
+
+// Succeed clears the account's failures. IP counters are kept so one valid
+// account cannot be used to reset an address that is guessing passwords.
+func (g *LoginGuard) Succeed(email string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.store.Delete(accountKey(email))
+}
+
+// UnlockAccount clears the failures recorded for an account
+func (g *LoginGuard) UnlockAccount(email string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.store.Delete(accountKey(email))
+}
+
+// UnlockIP clears the failures recorded for an IP address
+func (g *LoginGuard) UnlockIP(ip string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.store.Delete(ipKey(ip))
+}
+
+// MemoryAttemptStore keeps attempt records in process memory
+type MemoryAttemptStore struct {
+	mu      sync.Mutex
+	records map[string]AttemptRecord
+	ttl     time.Duration
+}
+
+// NewMemoryAttemptStore creates an in-memory store that drops records whose
+// last failure is older than ttl
+func NewMemoryAttemptStore(ttl time.Duration) *MemoryAttemptStore {
+	return &MemoryAttemptStore{records: make(map[string]AttemptRecord), ttl: ttl}
+}
+
+func (s *MemoryAttemptStore) Get(key string) (AttemptRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[key]
+	return record, ok, nil
+}
+
+func (s *MemoryAttemptStore) Save(key string, record AttemptRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Sweep stale entries so a flood of distinct keys cannot grow the map forever
+	if len(s.records) >= 10000 {
+		now := time.Now()
+		for k, r := range s.records {
+			if now.Sub(r.LastFailure) > s.ttl && now.After(r.LockedUntil) {
+				delete(s.records, k)
+			}
+		}
+	}
+
+	s.records[key] = record
+	return nil
+}
+
+func (s *MemoryAttemptStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// DBAttemptStore keeps attempt records in the login_attempts table so they
+// are shared between server instances and survive restarts
+type DBAttemptStore struct {
+	db *gorm.DB
+}
+
+func NewDBAttemptStore(db *gorm.DB) *DBAttemptStore {
+	return &DBAttemptStore{db: db}
+}
+
+func (s *DBAttemptStore) Get(key string) (AttemptRecord, bool, error) {
+	var row models.LoginAttempt
+	err := s.db.Where("attempt_key = ?", key).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return AttemptRecord{}, false, nil
+	}
+	if err != nil {
+		return AttemptRecord{}, false, err
+	}
+	return AttemptRecord{Failures: row.Failures, LastFailure: row.LastFailure, LockedUntil: row.LockedUntil}, true, nil
+}
+
+func (s *DBAttemptStore) Save(key string, record AttemptRecord) error {
+	row := models.LoginAttempt{
+		Key:         key,
+		Failures:    record.Failures,
+		LastFailure: record.LastFailure,
+		LockedUntil: record.LockedUntil,
+	}
+	return s.db.Save(&row).Error
+}
+
+func (s *DBAttemptStore) Delete(key string) error {
+	return s.db.Where("attempt_key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
+
+// RetryAfterSeconds formats a wait time for the Retry-After header
+func RetryAfterSeconds(d time.Duration) string {
+	seconds := int((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
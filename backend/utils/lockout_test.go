@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginGuardLocksAccount(t *testing.T) {
+	now := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	guard := NewLoginGuard(NewMemoryAttemptStore(time.Hour), DefaultAccountPolicy, DefaultIPPolicy)
+	guard.now = func() time.Time { return now }
+
+	// The first failures are free, then the delay doubles
+	for i := 1; i < DefaultAccountPolicy.BackoffAfter; i++ {
+		wait, err := guard.Fail("doctor@medibridge.com", "10.0.0.1")
+		assert.NoError(t, err)
+		assert.Zero(t, wait)
+	}
+
+	wait, _ := guard.Fail("doctor@medibridge.com", "10.0.0.1")
+	assert.Equal(t, time.Second, wait)
+	wait, _ = guard.Fail("doctor@medibridge.com", "10.0.0.1")
+	assert.Equal(t, 2*time.Second, wait)
+
+	// The fifth failure locks the account
+	wait, _ = guard.Fail("doctor@medibridge.com", "10.0.0.1")
+	assert.Equal(t, DefaultAccountPolicy.LockoutDuration, wait)
+
+	wait, _ = guard.Check("doctor@medibridge.com", "10.0.0.2")
+	assert.Equal(t, DefaultAccountPolicy.LockoutDuration, wait)
+
+	// Other accounts from the same IP are not affected yet
+	wait, _ = guard.Check("receptionist@medibridge.com", "10.0.0.1")
+	assert.Zero(t, wait)
+
+	now = now.Add(DefaultAccountPolicy.LockoutDuration)
+	wait, _ = guard.Check("doctor@medibridge.com", "10.0.0.2")
+	assert.Zero(t, wait)
+}
+
+func TestLoginGuardUnlock(t *testing.T) {
+	guard := NewLoginGuard(NewMemoryAttemptStore(time.Hour), DefaultAccountPolicy, DefaultIPPolicy)
+	for i := 0; i < DefaultAccountPolicy.MaxFailures; i++ {
+		guard.Fail("doctor@medibridge.com", "10.0.0.1")
+	}
+
+	wait, _ := guard.Check("doctor@medibridge.com", "10.0.0.1")
+	assert.NotZero(t, wait)
+
+	assert.NoError(t, guard.UnlockAccount("doctor@medibridge.com"))
+	wait, _ = guard.Check("doctor@medibridge.com", "10.0.0.1")
+	assert.Zero(t, wait)
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	assert.Equal(t, "1", RetryAfterSeconds(0))
+	assert.Equal(t, "2", RetryAfterSeconds(1500*time.Millisecond))
+	assert.Equal(t, "900", RetryAfterSeconds(15*time.Minute))
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewLogger creates the application logger. Output is JSON unless
+// LOG_FORMAT=text, and every attribute passes through the redaction layer.
+func NewLogger(w io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       logLevel(),
+		ReplaceAttr: redactAttr,
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(os.Getenv("LOG_FORMAT"), "text") {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(handler)
+}
+
+func logLevel() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL"))); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// redactAttr masks attributes with sensitive names and redacts tagged
+// struct fields of any structured value before it is written
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if IsSensitiveKey(a.Key) {
+		return slog.String(a.Key, RedactedValue)
+	}
+	if a.Value.Kind() == slog.KindAny {
+		if _, isErr := a.Value.Any().(error); isErr {
+			return a
+		}
+		return slog.Any(a.Key, Redact(a.Value.Any()))
+	}
+	return a
+}
+
+// Logger returns the logger for a request, annotated with its request ID
+// and the authenticated user when known
+func Logger(c *gin.Context) *slog.Logger {
+	logger := slog.Default()
+	if requestID := c.GetString("requestID"); requestID != "" {
+		logger = logger.With("requestId", requestID)
+	}
+	if userID, exists := c.Get("userID"); exists {
+		logger = logger.With("userId", userID)
+	}
+	return logger
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const defaultMRNDigits = 7
+
+// MRNFormat describes how medical record numbers are built:
+// <prefix>-<clinic>-<sequence><check digit>, e.g. MRN-MAIN-00000422 for
+// sequence 42. The check digit is the Luhn digit of the padded sequence.
+type MRNFormat struct {
+	Prefix string
+	Clinic string
+	Digits int
+}
+
+// LoadMRNFormat reads MRN_PREFIX (default "MRN"), MRN_CLINIC (default
+// "MAIN") and MRN_DIGITS (default 7)
+func LoadMRNFormat() MRNFormat {
+	format := MRNFormat{Prefix: "MRN", Clinic: "MAIN", Digits: defaultMRNDigits}
+	if value, ok := os.LookupEnv("MRN_PREFIX"); ok {
+		format.Prefix = strings.ToUpper(strings.TrimSpace(value))
+	}
+	if value := strings.TrimSpace(os.Getenv("MRN_CLINIC")); value != "" {
+		format.Clinic = strings.ToUpper(value)
+	}
+	if value, err := strconv.Atoi(os.Getenv("MRN_DIGITS")); err == nil && value > 0 {
+		format.Digits = value
+	}
+	return format
+}
+
+// Format builds the MRN for a sequence number
+func (f MRNFormat) Format(sequence int64) string {
+	digits := fmt.Sprintf("%0*d", f.Digits, sequence)
+	number := digits + strconv.Itoa(LuhnCheckDigit(digits))
+
+	parts := make([]string, 0, 3)
+	if f.Prefix != "" {
+		parts = append(parts, f.Prefix)
+	}
+	if f.Clinic != "" {
+		parts = append(parts, f.Clinic)
+	}
+	return strings.Join(append(parts, number), "-")
+}
+
+// LuhnCheckDigit returns the Luhn (mod 10) check digit for a string of digits
+func LuhnCheckDigit(digits string) int {
+	sum := 0
+	double := true
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
+
+// ValidMRNCheckDigit reports whether the number part of an MRN ends in the
+// correct check digit. It catches most typos before a lookup.
+func ValidMRNCheckDigit(mrn string) bool {
+	number := mrn
+	if i := strings.LastIndex(mrn, "-"); i >= 0 {
+		number = mrn[i+1:]
+	}
+	if len(number) < 2 {
+		return false
+	}
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	body, check := number[:len(number)-1], int(number[len(number)-1]-'0')
+	return LuhnCheckDigit(body) == check
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLuhnCheckDigit(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   int
+	}{
+		{"7992739871", 3},
+		{"0000042", 2},
+		{"0000000", 0},
+		{"1", 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.digits, func(t *testing.T) {
+			assert.Equal(t, tt.want, LuhnCheckDigit(tt.digits))
+		})
+	}
+}
+
+func TestMRNFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   MRNFormat
+		sequence int64
+		want     string
+	}{
+		{name: "default", format: MRNFormat{Prefix: "MRN", Clinic: "MAIN", Digits: 7}, sequence: 42, want: "MRN-MAIN-00000422"},
+		{name: "no prefix", format: MRNFormat{Clinic: "NORTH", Digits: 5}, sequence: 7, want: "NORTH-000075"},
+		{name: "sequence wider than digits", format: MRNFormat{Prefix: "X", Digits: 2}, sequence: 1234, want: "X-12344"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mrn := tt.format.Format(tt.sequence)
+			assert.Equal(t, tt.want, mrn)
+			assert.True(t, ValidMRNCheckDigit(mrn))
+		})
+	}
+}
+
+func TestValidMRNCheckDigit(t *testing.T) {
+	assert.True(t, ValidMRNCheckDigit("MRN-MAIN-00000422"))
+	assert.False(t, ValidMRNCheckDigit("MRN-MAIN-00000423"), "wrong check digit")
+	assert.False(t, ValidMRNCheckDigit("MRN-MAIN-00000242"), "transposed digits")
+	assert.False(t, ValidMRNCheckDigit("MRN-MAIN-"), "no number")
+	assert.False(t, ValidMRNCheckDigit("MRN-MAIN-12A4"), "not numeric")
+}
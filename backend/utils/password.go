@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	defaultPasswordMinLength = 12
+	// bcrypt ignores everything after 72 bytes
+	passwordMaxBytes = 72
+)
+
+// PasswordPolicy validates new passwords
+type PasswordPolicy struct {
+	MinLength int
+	// breached holds upper-case hex SHA-1 hashes of known breached passwords
+	breached map[string]struct{}
+}
+
+// NewPasswordPolicy creates a policy with the given minimum length and no breached list
+func NewPasswordPolicy(minLength int) *PasswordPolicy {
+	return &PasswordPolicy{MinLength: minLength, breached: make(map[string]struct{})}
+}
+
+// LoadBreachedPasswords reads a breached-password list. Each line is either a
+// plaintext password or a SHA-1 hash in hex, optionally followed by ":count"
+// as in the Have I Been Pwned downloads.
+func (p *PasswordPolicy) LoadBreachedPasswords(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if hash, _, _ := strings.Cut(line, ":"); isSHA1Hex(hash) {
+			p.breached[strings.ToUpper(hash)] = struct{}{}
+			continue
+		}
+		p.breached[sha1Hex(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Validate returns a user-facing reason when the password is not acceptable
+func (p *PasswordPolicy) Validate(password string, disallowed ...string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters long", p.MinLength)
+	}
+	if len(password) > passwordMaxBytes {
+		return fmt.Errorf("password must be at most %d bytes long", passwordMaxBytes)
+	}
+
+	lower := strings.ToLower(password)
+	for _, value := range disallowed {
+		if value != "" && strings.Contains(lower, strings.ToLower(value)) {
+			return fmt.Errorf("password must not contain your name or email")
+		}
+	}
+
+	if _, found := p.breached[sha1Hex(password)]; found {
+		return fmt.Errorf("password appears in a list of breached passwords")
+	}
+
+	return nil
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+var (
+	passwordPolicyMu     sync.Mutex
+	activePasswordPolicy *PasswordPolicy
+)
+
+// LoadPasswordPolicy builds the policy configured by PASSWORD_MIN_LENGTH and
+// BREACHED_PASSWORDS_FILE
+func LoadPasswordPolicy() (*PasswordPolicy, error) {
+	minLength := defaultPasswordMinLength
+	if value, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && value > 0 {
+		minLength = value
+	}
+
+	policy := NewPasswordPolicy(minLength)
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		if err := policy.LoadBreachedPasswords(path); err != nil {
+			return nil, fmt.Errorf("loading breached passwords: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+// SetPasswordPolicy replaces the policy returned by CurrentPasswordPolicy
+func SetPasswordPolicy(policy *PasswordPolicy) {
+	passwordPolicyMu.Lock()
+	defer passwordPolicyMu.Unlock()
+	activePasswordPolicy = policy
+}
+
+// CurrentPasswordPolicy returns the configured policy, or the default
+// length-only policy when none was loaded
+func CurrentPasswordPolicy() *PasswordPolicy {
+	passwordPolicyMu.Lock()
+	defer passwordPolicyMu.Unlock()
+
+	if activePasswordPolicy == nil {
+		activePasswordPolicy = NewPasswordPolicy(defaultPasswordMinLength)
+	}
+	return activePasswordPolicy
+}
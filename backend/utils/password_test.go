@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := "# common passwords\npassword1234\n" +
+		// HIBP format: SHA-1 hash followed by the breach count
+		sha1Hex("letmein-letmein") + ":42\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	policy := NewPasswordPolicy(12)
+	require.NoError(t, policy.LoadBreachedPasswords(path))
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{"Too short", "short", true},
+		{"Breached plaintext entry", "password1234", true},
+		{"Breached hash entry", "letmein-letmein", true},
+		{"Contains name", "john-doe-rocks-2026", true},
+		{"Too long for bcrypt", string(make([]byte, 80)), true},
+		{"Acceptable", "correct horse battery staple", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, "john-doe", "doctor@medibridge.com")
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
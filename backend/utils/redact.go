@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// RedactedValue replaces sensitive values in logs
+const RedactedValue = "[REDACTED]"
+
+// Struct fields are redacted by tagging them, e.g.
+//
+//	Password  string `json:"password" redact:"secret"`
+//	Diagnosis string `json:"diagnosis" redact:"phi"`
+//
+// Any non-empty redact tag other than "-" masks the field.
+const redactTag = "redact"
+
+// sensitiveKeys are masked wherever they appear as a log attribute or map
+// key, even when the value is not a tagged struct
+var sensitiveKeys = map[string]bool{
+	"password":        true,
+	"currentpassword": true,
+	"newpassword":     true,
+	"passwordhash":    true,
+	"token":           true,
+	"accesstoken":     true,
+	"refreshtoken":    true,
+	"mfatoken":        true,
+	"resettoken":      true,
+	"secret":          true,
+	"totpsecret":      true,
+	"recoverycode":    true,
+	"recoverycodes":   true,
+	"authorization":   true,
+	"cookie":          true,
+}
+
+// IsSensitiveKey reports whether a log key or field name always holds a secret
+func IsSensitiveKey(key string) bool {
+	normalized := strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+	return sensitiveKeys[normalized]
+}
+
+const maxRedactDepth = 8
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Redact returns a copy of v that is safe to log. Structs are converted to
+// maps keyed by their JSON field names with tagged fields masked; maps and
+// slices are walked recursively. Scalars are returned unchanged.
+func Redact(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return redactValue(reflect.ValueOf(v), 0)
+}
+
+func redactValue(v reflect.Value, depth int) interface{} {
+	if depth > maxRedactDepth {
+		return "[TRUNCATED]"
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem(), depth+1)
+	case reflect.Struct:
+		if v.Type() == timeType || !hasExportedFields(v.Type()) {
+			return v.Interface()
+		}
+		return redactStruct(v, depth)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			if IsSensitiveKey(key) {
+				out[key] = RedactedValue
+				continue
+			}
+			out[key] = redactValue(iter.Value(), depth+1)
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return RedactedValue
+		}
+		out := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out[i] = redactValue(v.Index(i), depth+1)
+		}
+		return out
+	default:
+		if v.CanInterface() {
+			return v.Interface()
+		}
+		return nil
+	}
+}
+
+func redactStruct(v reflect.Value, depth int) map[string]interface{} {
+	t := v.Type()
+	out := make(map[string]interface{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				// Fields hidden from JSON are usually secrets too
+				continue
+			}
+			if jsonName := strings.Split(tag, ",")[0]; jsonName != "" {
+				name = jsonName
+			}
+		}
+
+		if tag := field.Tag.Get(redactTag); (tag != "" && tag != "-") || IsSensitiveKey(name) {
+			out[name] = RedactedValue
+			continue
+		}
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if embedded, ok := redactValue(v.Field(i), depth+1).(map[string]interface{}); ok {
+				for k, val := range embedded {
+					out[k] = val
+				}
+				continue
+			}
+		}
+
+		out[name] = redactValue(v.Field(i), depth+1)
+	}
+	return out
+}
+
+func hasExportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/medibridge/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactPatient(t *testing.T) {
+	patient := models.Patient{
+		ID:          42,
+		FirstName:   "Asha",
+		LastName:    "Verma",
+		Phone:       "+91 98765 43210",
+		DateOfBirth: time.Date(1980, 5, 1, 0, 0, 0, 0, time.UTC),
+		Gender:      "female",
+		Diagnosis:   "Type 2 diabetes",
+	}
+
+	redacted, ok := Redact(&patient).(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, uint(42), redacted["id"])
+	assert.Equal(t, "female", redacted["gender"])
+	for _, field := range []string{"firstName", "lastName", "phone", "dateOfBirth", "diagnosis", "notes"} {
+		assert.Equal(t, RedactedValue, redacted[field], field)
+	}
+}
+
+func TestRedactUserDropsSecrets(t *testing.T) {
+	user := models.User{ID: 1, Name: "Dr. John Doe", PasswordHash: "$2a$10$abc", TOTPSecret: "JBSWY3DP"}
+
+	redacted := Redact(user).(map[string]interface{})
+	assert.Equal(t, "Dr. John Doe", redacted["name"])
+	assert.NotContains(t, redacted, "PasswordHash")
+	assert.NotContains(t, redacted, "TOTPSecret")
+}
+
+func TestLoggerRedactsAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf)
+
+	logger.Info("login",
+		"password", "doctor@#123",
+		"body", map[string]interface{}{"email": "doctor@medibridge.com", "refreshToken": "abc"},
+		"patient", models.Patient{ID: 7, FirstName: "Asha"},
+	)
+
+	out := buf.String()
+	assert.NotContains(t, out, "doctor@#123")
+	assert.NotContains(t, out, "abc")
+	assert.NotContains(t, out, "Asha")
+	assert.Contains(t, out, "doctor@medibridge.com")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, RedactedValue, entry["password"])
+}
+
+func TestIsSensitiveKey(t *testing.T) {
+	assert.True(t, IsSensitiveKey("password"))
+	assert.True(t, IsSensitiveKey("refresh_token"))
+	assert.True(t, IsSensitiveKey("Authorization"))
+	assert.False(t, IsSensitiveKey("email"))
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortField is one key of a sort parameter
+type SortField struct {
+	Name string
+	Desc bool
+}
+
+// ParseSort parses a comma-separated sort parameter such as
+// "lastName,-createdAt", where a leading "-" sorts descending. Every name
+// must be in allowed and may appear once.
+func ParseSort(value string, allowed []string) ([]SortField, error) {
+	known := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		known[name] = true
+	}
+
+	var fields []SortField
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		field := SortField{Name: item}
+		if strings.HasPrefix(item, "-") {
+			field = SortField{Name: item[1:], Desc: true}
+		} else if strings.HasPrefix(item, "+") {
+			field.Name = item[1:]
+		}
+		if !known[field.Name] {
+			return nil, fmt.Errorf("cannot sort by %q, use one of %s", field.Name, strings.Join(allowed, ", "))
+		}
+		if seen[field.Name] {
+			return nil, fmt.Errorf("%q is sorted by more than once", field.Name)
+		}
+		seen[field.Name] = true
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
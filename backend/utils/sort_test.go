@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSort(t *testing.T) {
+	allowed := []string{"lastName", "firstName", "createdAt"}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    []SortField
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: nil},
+		{name: "single ascending", value: "lastName", want: []SortField{{Name: "lastName"}}},
+		{
+			name:  "several with directions",
+			value: "lastName, -createdAt,+firstName",
+			want:  []SortField{{Name: "lastName"}, {Name: "createdAt", Desc: true}, {Name: "firstName"}},
+		},
+		{name: "empty items skipped", value: ",lastName,,", want: []SortField{{Name: "lastName"}}},
+		{name: "unknown field", value: "diagnosis", wantErr: true},
+		{name: "repeated field", value: "lastName,-lastName", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSort(tt.value, allowed)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+import React from 'react';
+import { AlertCircle } from 'lucide-react';
+import type { AllergyBanner as AllergyBannerData } from '../../types';
+
+interface AllergyBannerProps {
+  banner?: AllergyBannerData;
+}
+
+// allergySummary lists the substances of a banner on one line
+export const allergySummary = (banner?: AllergyBannerData) =>
+  (banner?.items ?? []).map((item) => item.substance).join(', ');
+
+// AllergyBanner shows a patient's active allergies, most severe first.
+// Unstructured entries were converted from free text and are not coded yet.
+const AllergyBanner: React.FC<AllergyBannerProps> = ({ banner }) => {
+  if (!banner || banner.count === 0) {
+    return null;
+  }
+
+  return (
+    <div>
+      <label className="block text-gray-500 flex items-center">
+        <AlertCircle className="w-4 h-4 mr-1 text-red-500" />
+        Allergies
+      </label>
+      <ul className="text-red-700 bg-red-50 p-2 rounded space-y-1">
+        {banner.items.map((item, index) => (
+          <li key={index}>
+            <span className="font-medium">{item.substance}</span>
+            {item.severity && <span className="capitalize"> ({item.severity})</span>}
+            {item.reaction && <span> – {item.reaction}</span>}
+            {item.category === 'unstructured' && <span className="text-red-500"> (not coded)</span>}
+          </li>
+        ))}
+      </ul>
+    </div>
+  );
+};
+
+export default AllergyBanner;
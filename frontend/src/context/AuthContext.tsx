@@ -1,11 +1,12 @@
 import React, { createContext, useContext, useState, useEffect, type ReactNode } from 'react';
-import type { User, ApiResponse } from '../types';
+import type { User, LoginResult } from '../types';
 import { authService } from '../services/authService';
-import api from '../services/api';
+import api, { clearSession } from '../services/api';
 
 interface AuthContextType {
   user: User | null;
-  login: (email: string, password: string) => Promise<ApiResponse<User>>;
+  login: (email: string, password: string) => Promise<LoginResult>;
+  verifyMFA: (mfaToken: string, code: string, recoveryCode: string) => Promise<LoginResult>;
   logout: () => void;
   isLoading: boolean;
 }
@@ -52,16 +53,12 @@ export const AuthProvider: React.FC<AuthProviderProps> = ({ children }) => {
         } catch (error) {
           console.error('Token validation failed:', error);
           // Clear invalid token and user data
-          localStorage.removeItem('token');
-          localStorage.removeItem('user');
-          delete api.defaults.headers.common['Authorization'];
+          clearSession();
           setUser(null);
         }
       } else {
         // Clear any existing auth data
-        localStorage.removeItem('token');
-        localStorage.removeItem('user');
-        delete api.defaults.headers.common['Authorization'];
+        clearSession();
         setUser(null);
       }
       setIsLoading(false);
@@ -70,38 +67,40 @@ export const AuthProvider: React.FC<AuthProviderProps> = ({ children }) => {
     initializeAuth();
   }, []);
 
-  const login = async (email: string, password: string): Promise<ApiResponse<User>> => {
+  const login = async (email: string, password: string): Promise<LoginResult> => {
     try {
       const response = await authService.login({ email, password });
       if (response.success && response.data) {
         setUser(response.data);
-        // Set the token in API headers
-        const token = localStorage.getItem('token');
-        if (token) {
-          api.defaults.headers.common['Authorization'] = `Bearer ${token}`;
-        }
       }
       return response;
     } catch (error) {
       console.error('Login error in context:', error);
       return {
         success: false,
-        data: null as any,
+        data: null,
         error: error instanceof Error ? error.message : 'Login failed'
       };
     }
   };
 
+  const verifyMFA = async (mfaToken: string, code: string, recoveryCode: string): Promise<LoginResult> => {
+    const response = await authService.verifyMFA(mfaToken, code, recoveryCode);
+    if (response.success && response.data) {
+      setUser(response.data);
+    }
+    return response;
+  };
+
   const logout = () => {
-    localStorage.removeItem('token');
-    localStorage.removeItem('user');
-    delete api.defaults.headers.common['Authorization'];
     setUser(null);
+    authService.logout();
   };
 
   const value = {
     user,
     login,
+    verifyMFA,
     logout,
     isLoading,
   };
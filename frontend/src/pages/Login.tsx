@@ -1,48 +1,102 @@
 import React, { useState } from 'react';
 import { useForm } from 'react-hook-form';
 import { useNavigate, Navigate } from 'react-router-dom';
-import { Heart, Stethoscope, Shield } from 'lucide-react';
+import { Heart, KeyRound } from 'lucide-react';
 import { toast } from 'sonner';
 import { useAuth } from '../context/AuthContext';
-import type { LoginCredentials } from '../types';
+import { authService } from '../services/authService';
+import type { LoginCredentials, MFAChallenge, MFAEnrollment, User } from '../types';
 import LoadingSpinner from '../components/common/LoadingSpinner';
 
+interface MFAForm {
+  code: string;
+}
+
+const homePath = (role: User['role']) => (role === 'receptionist' ? '/receptionist/patients' : '/doctor/patients');
+
 const Login: React.FC = () => {
-  const { user, login } = useAuth();
+  const { user, login, verifyMFA } = useAuth();
   const navigate = useNavigate();
   const [isLoading, setIsLoading] = useState(false);
+  // Set while the second login step for two-factor authentication is shown
+  const [mfa, setMFA] = useState<MFAChallenge | null>(null);
+  const [enrollment, setEnrollment] = useState<MFAEnrollment | null>(null);
+  const [useRecoveryCode, setUseRecoveryCode] = useState(false);
+  // Recovery codes of a user who just set up two-factor authentication,
+  // shown once before continuing
+  const [recoveryCodes, setRecoveryCodes] = useState<string[] | null>(null);
 
   const {
     register,
     handleSubmit,
     formState: { errors },
   } = useForm<LoginCredentials>();
+  const mfaForm = useForm<MFAForm>();
 
-  // Redirect if already logged in
-  if (user) {
-    const redirectPath = user.role === 'receptionist' ? '/receptionist/patients' : '/doctor/patients';
-    return <Navigate to={redirectPath} replace />;
+  // Redirect if already logged in. During the second step the form
+  // navigates itself, after showing new recovery codes.
+  if (user && !mfa) {
+    return <Navigate to={homePath(user.role)} replace />;
   }
 
   const onSubmit = async (data: LoginCredentials) => {
     setIsLoading(true);
     try {
-      await login(data.email, data.password);
+      const response = await login(data.email, data.password);
+      if (!response.success) {
+        toast.error(response.error || 'Login failed');
+        return;
+      }
+      if (response.mfa) {
+        setMFA(response.mfa);
+        if (response.mfa.enrollmentRequired) {
+          setEnrollment(await authService.startMFAEnrollment(response.mfa.mfaToken));
+        }
+        return;
+      }
       toast.success('Login successful!');
-
-      // Redirect based on role
-      if (data.email.includes('receptionist')) {
-        navigate('/receptionist/patients');
-      } else {
-        navigate('/doctor/patients');
+      if (response.data) {
+        navigate(homePath(response.data.role));
       }
     } catch (error: any) {
-      toast.error(error?.response?.data?.message || 'Login failed');
+      toast.error(error?.response?.data?.error || 'Login failed');
     } finally {
       setIsLoading(false);
     }
   };
 
+  const onSubmitMFA = async ({ code }: MFAForm) => {
+    if (!mfa) {
+      return;
+    }
+    setIsLoading(true);
+    try {
+      const response = useRecoveryCode
+        ? await verifyMFA(mfa.mfaToken, '', code)
+        : await verifyMFA(mfa.mfaToken, code, '');
+      if (!response.success) {
+        toast.error(response.error || 'Invalid verification code');
+        mfaForm.reset();
+        return;
+      }
+      toast.success('Login successful!');
+      if (response.recoveryCodes?.length) {
+        setRecoveryCodes(response.recoveryCodes);
+      } else if (response.data) {
+        navigate(homePath(response.data.role));
+      }
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  const cancelMFA = () => {
+    setMFA(null);
+    setEnrollment(null);
+    setUseRecoveryCode(false);
+    mfaForm.reset();
+  };
+
   return (
     <div className="min-h-screen bg-gradient-to-br from-primary-50 to-primary-100 flex items-center justify-center p-4">
       <div className="max-w-md w-full">
@@ -64,81 +118,154 @@ const Login: React.FC = () => {
             <p className="text-gray-600">Sign in to your account to continue</p>
           </div>
 
-          <form onSubmit={handleSubmit(onSubmit)} className="space-y-6">
-            <div>
-              <label className="block text-sm font-medium text-gray-700 mb-2">
-                Email Address
-              </label>
-              <input
-                type="email"
-                {...register('email', {
-                  required: 'Email is required',
-                  pattern: {
-                    value: /^\S+@\S+$/i,
-                    message: 'Invalid email address'
-                  }
-                })}
-                className="w-full px-4 py-3 border border-gray-300 rounded-lg focus:ring-2 focus:ring-primary-500 focus:border-transparent transition-all"
-                placeholder="Enter your email"
-              />
-              {errors.email && (
-                <p className="text-red-500 text-sm mt-1">{errors.email.message}</p>
-              )}
+          {recoveryCodes && user ? (
+            <div className="space-y-6">
+              <div>
+                <h3 className="text-lg font-semibold text-gray-900 mb-2">Save your recovery codes</h3>
+                <p className="text-sm text-gray-600">
+                  Each code signs you in once if you lose your authenticator. They are only shown now.
+                </p>
+              </div>
+              <ul className="grid grid-cols-2 gap-2 p-4 bg-gray-50 rounded-lg font-mono text-sm text-gray-900">
+                {recoveryCodes.map((recoveryCode) => (
+                  <li key={recoveryCode}>{recoveryCode}</li>
+                ))}
+              </ul>
+              <button
+                type="button"
+                onClick={() => navigate(homePath(user.role))}
+                className="inline-flex items-center justify-center gap-2 px-6 py-2 rounded-xl text-white font-medium transition bg-blue-600 hover:bg-blue-700 focus:ring-2 focus:ring-blue-300"
+              >
+                I have saved them
+              </button>
             </div>
+          ) : mfa ? (
+            <form onSubmit={mfaForm.handleSubmit(onSubmitMFA)} className="space-y-6">
+              <div className="flex items-start space-x-3 p-3 bg-blue-50 rounded-lg">
+                <KeyRound className="w-5 h-5 text-blue-600 mt-0.5" />
+                <p className="text-sm text-blue-900">
+                  {enrollment
+                    ? 'Your account requires two-factor authentication. Add this key to your authenticator app, then enter the code it shows.'
+                    : useRecoveryCode
+                      ? 'Enter one of your recovery codes.'
+                      : 'Enter the 6-digit code from your authenticator app.'}
+                </p>
+              </div>
 
-            <div>
-              <label className="block text-sm font-medium text-gray-700 mb-2">
-                Password
-              </label>
-              <input
-                type="password"
-                {...register('password', {
-                  required: 'Password is required',
-                  minLength: {
-                    value: 6,
-                    message: 'Password must be at least 6 characters'
-                  }
-                })}
-                className="w-full px-4 py-3 border border-gray-300 rounded-lg focus:ring-2 focus:ring-primary-500 focus:border-transparent transition-all"
-                placeholder="Enter your password"
-              />
-              {errors.password && (
-                <p className="text-red-500 text-sm mt-1">{errors.password.message}</p>
+              {enrollment && (
+                <div className="p-3 bg-gray-50 rounded-lg">
+                  <p className="text-xs text-gray-600 mb-1">Setup key</p>
+                  <p className="font-mono text-sm text-gray-900 break-all">{enrollment.secret}</p>
+                  <a href={enrollment.provisioningUri} className="text-xs text-blue-600 hover:underline">
+                    Open in authenticator app
+                  </a>
+                </div>
               )}
-            </div>
 
-            <button
-              type="submit"
-              disabled={isLoading}
-              className={`inline-flex items-center justify-center gap-2 px-6 py-2 rounded-xl text-white font-medium transition 
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-2">
+                  {useRecoveryCode ? 'Recovery Code' : 'Verification Code'}
+                </label>
+                <input
+                  type="text"
+                  autoComplete="one-time-code"
+                  inputMode={useRecoveryCode ? 'text' : 'numeric'}
+                  autoFocus
+                  {...mfaForm.register('code', { required: 'Code is required' })}
+                  className="w-full px-4 py-3 border border-gray-300 rounded-lg focus:ring-2 focus:ring-primary-500 focus:border-transparent transition-all"
+                  placeholder={useRecoveryCode ? 'ABCD-EFGH-JKLM' : '123456'}
+                />
+                {mfaForm.formState.errors.code && (
+                  <p className="text-red-500 text-sm mt-1">{mfaForm.formState.errors.code.message}</p>
+                )}
+              </div>
+
+              <div className="flex items-center justify-between">
+                <button
+                  type="submit"
+                  disabled={isLoading}
+                  className={`inline-flex items-center justify-center gap-2 px-6 py-2 rounded-xl text-white font-medium transition 
     ${isLoading ? 'bg-gray-400 cursor-not-allowed' : 'bg-blue-600 hover:bg-blue-700 focus:ring-2 focus:ring-blue-300'}
   `}
-            >
-              {isLoading && <LoadingSpinner size="sm" />}
-              <span>Sign In</span>
-            </button>
-          </form>
-
-          {/* Demo Credentials */}
-          <div className="mt-8 pt-6 border-t border-gray-200">
-            <p className="text-sm font-medium text-gray-700 mb-4">Demo Credentials:</p>
-            <div className="space-y-3">
-              <div className="flex items-center space-x-3 p-3 bg-blue-50 rounded-lg">
-                <Shield className="w-5 h-5 text-blue-600" />
-                <div>
-                  <p className="text-sm font-medium text-blue-900">Receptionist</p>
-                  <p className="text-xs text-blue-600">receptionist@medibridge.com / reception@#123</p>
+                >
+                  {isLoading && <LoadingSpinner size="sm" />}
+                  <span>Verify</span>
+                </button>
+                <div className="flex flex-col items-end gap-1">
+                  {!enrollment && (
+                    <button
+                      type="button"
+                      onClick={() => {
+                        setUseRecoveryCode(!useRecoveryCode);
+                        mfaForm.reset();
+                      }}
+                      className="text-sm text-blue-600 hover:underline"
+                    >
+                      {useRecoveryCode ? 'Use authenticator code' : 'Use a recovery code'}
+                    </button>
+                  )}
+                  <button type="button" onClick={cancelMFA} className="text-sm text-gray-600 hover:underline">
+                    Back to sign in
+                  </button>
                 </div>
               </div>
-              <div className="flex items-center space-x-3 p-3 bg-green-50 rounded-lg">
-                <Stethoscope className="w-5 h-5 text-green-600" />
-                <div>
-                  <p className="text-sm font-medium text-green-900">Doctor</p>
-                  <p className="text-xs text-green-600">doctor@medibridge.com / doctor@#123</p>
-                </div>
+            </form>
+          ) : (
+            <form onSubmit={handleSubmit(onSubmit)} className="space-y-6">
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-2">
+                  Email Address
+                </label>
+                <input
+                  type="email"
+                  {...register('email', {
+                    required: 'Email is required',
+                    pattern: {
+                      value: /^\S+@\S+$/i,
+                      message: 'Invalid email address'
+                    }
+                  })}
+                  className="w-full px-4 py-3 border border-gray-300 rounded-lg focus:ring-2 focus:ring-primary-500 focus:border-transparent transition-all"
+                  placeholder="Enter your email"
+                />
+                {errors.email && (
+                  <p className="text-red-500 text-sm mt-1">{errors.email.message}</p>
+                )}
               </div>
-            </div>
-          </div>
+
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-2">
+                  Password
+                </label>
+                <input
+                  type="password"
+                  {...register('password', {
+                    required: 'Password is required',
+                    minLength: {
+                      value: 6,
+                      message: 'Password must be at least 6 characters'
+                    }
+                  })}
+                  className="w-full px-4 py-3 border border-gray-300 rounded-lg focus:ring-2 focus:ring-primary-500 focus:border-transparent transition-all"
+                  placeholder="Enter your password"
+                />
+                {errors.password && (
+                  <p className="text-red-500 text-sm mt-1">{errors.password.message}</p>
+                )}
+              </div>
+
+              <button
+                type="submit"
+                disabled={isLoading}
+                className={`inline-flex items-center justify-center gap-2 px-6 py-2 rounded-xl text-white font-medium transition 
+    ${isLoading ? 'bg-gray-400 cursor-not-allowed' : 'bg-blue-600 hover:bg-blue-700 focus:ring-2 focus:ring-blue-300'}
+  `}
+              >
+                {isLoading && <LoadingSpinner size="sm" />}
+                <span>Sign In</span>
+              </button>
+            </form>
+          )}
         </div>
       </div>
     </div>
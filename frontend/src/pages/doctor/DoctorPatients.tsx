@@ -11,14 +11,16 @@ import {
 } from 'lucide-react';
 import { toast } from 'sonner';
 import { patientService } from '../../services/patientService';
-import type { Patient } from '../../types';
+import type { ClinicalNote, ICD10Code, Patient } from '../../types';
 import LoadingSpinner from '../../components/common/LoadingSpinner';
+import AllergyBanner, { allergySummary } from '../../components/common/AllergyBanner';
 
 const DoctorPatients: React.FC = () => {
   const [page, setPage] = useState(1);
   const [search, setSearch] = useState('');
   const [selectedPatient, setSelectedPatient] = useState<Patient | null>(null);
-  const [diagnosis, setDiagnosis] = useState('');
+  const [diagnosisSearch, setDiagnosisSearch] = useState('');
+  const [diagnosisCode, setDiagnosisCode] = useState<ICD10Code | null>(null);
   const [notes, setNotes] = useState('');
 
   const queryClient = useQueryClient();
@@ -29,21 +31,64 @@ const DoctorPatients: React.FC = () => {
     queryFn: () => patientService.getPatients({ page, limit, search }, 'doctor'),
   });
 
+  const { data: clinicalNotes } = useQuery({
+    queryKey: ['notes', selectedPatient?.id],
+    queryFn: () => patientService.getNotes(selectedPatient!.id),
+    enabled: !!selectedPatient,
+  });
+
+  const { data: diagnoses } = useQuery({
+    queryKey: ['diagnoses', selectedPatient?.id],
+    queryFn: () => patientService.getDiagnoses(selectedPatient!.id),
+    enabled: !!selectedPatient,
+  });
+
+  const { data: codeMatches } = useQuery({
+    queryKey: ['icd10', diagnosisSearch],
+    queryFn: () => patientService.searchICD10(diagnosisSearch.trim()),
+    enabled: !diagnosisCode && diagnosisSearch.trim().length >= 2,
+  });
+
+  // A diagnosis is added to the problem list with its ICD-10 code; notes are
+  // written as a clinical note that is signed straight away
   const updatePatientMutation = useMutation({
-    mutationFn: (data: { id: number; diagnosis: string; notes: string }) =>
-      patientService.updatePatient(data.id, { diagnosis: data.diagnosis, notes: data.notes }, 'doctor'),
+    mutationFn: async (data: { id: number; code: ICD10Code | null; notes: string }) => {
+      if (data.code) {
+        await patientService.createDiagnosis(data.id, { code: data.code.code });
+      }
+      if (data.notes.trim()) {
+        const note = await patientService.createNote(data.id, { title: 'Medical notes', assessment: data.notes });
+        await patientService.signNote(data.id, note.id);
+      }
+    },
     onSuccess: () => {
       queryClient.invalidateQueries({ queryKey: ['patients'] });
+      queryClient.invalidateQueries({ queryKey: ['notes'] });
+      queryClient.invalidateQueries({ queryKey: ['diagnoses'] });
       toast.success('Medical record updated successfully');
-      setDiagnosis('');
+      setDiagnosisSearch('');
+      setDiagnosisCode(null);
       setNotes('');
     },
-    onError: (error) => {
-      toast.error('Failed to update medical record');
+    onError: (error: any) => {
+      toast.error(error.response?.data?.error || 'Failed to update medical record');
       console.error('Error updating patient:', error);
     },
   });
 
+  const noteStatusLabel = (note: ClinicalNote) => {
+    switch (note.status) {
+      case 'awaiting_cosign':
+        return 'Awaiting co-signature';
+      case 'legacy':
+        return 'Imported';
+      case 'draft':
+        return 'Draft';
+      default:
+        return 'Signed';
+    }
+  };
+
   const calculateAge = (dateOfBirth: string) => {
     const today = new Date();
     const birthDate = new Date(dateOfBirth);
@@ -56,14 +101,14 @@ const DoctorPatients: React.FC = () => {
   };
 
   const handleSaveMedicalRecord = () => {
-    if (!selectedPatient || !diagnosis.trim()) {
-      toast.error('Please enter a diagnosis');
+    if (!selectedPatient || (!diagnosisCode && !notes.trim())) {
+      toast.error('Please choose a diagnosis or enter medical notes');
       return;
     }
 
     updatePatientMutation.mutate({
       id: selectedPatient.id,
-      diagnosis,
+      code: diagnosisCode,
       notes,
     });
   };
@@ -122,15 +167,7 @@ const DoctorPatients: React.FC = () => {
                       <p className="text-gray-900">{selectedPatient.emergencyContact}</p>
                       <p className="text-gray-600">{selectedPatient.emergencyPhone}</p>
                     </div>
-                    {selectedPatient.allergies && (
-                      <div>
-                        <label className="block text-gray-500 flex items-center">
-                          <AlertCircle className="w-4 h-4 mr-1 text-red-500" />
-                          Allergies
-                        </label>
-                        <p className="text-red-700 bg-red-50 p-2 rounded">{selectedPatient.allergies}</p>
-                      </div>
-                    )}
+                    <AllergyBanner banner={selectedPatient.allergyBanner} />
                   </div>
                 </div>
               </div>
@@ -138,24 +175,56 @@ const DoctorPatients: React.FC = () => {
               {/* Medical Records */}
               <div className="lg:col-span-2">
                 <div className="space-y-6">
-                  {/* Current Medical Record */}
-                  {selectedPatient.diagnosis && (
+                  {/* Problem List */}
+                  {diagnoses && diagnoses.length > 0 && (
+                    <div className="bg-white border border-gray-200 rounded-lg p-6">
+                      <h3 className="font-semibold text-gray-900 mb-4 flex items-center">
+                        <FileText className="w-5 h-5 mr-2 text-primary-600" />
+                        Problem List
+                      </h3>
+                      <div className="space-y-3">
+                        {diagnoses.map((entry) => (
+                          <div key={entry.id} className="flex items-center justify-between text-sm">
+                            <span className="text-gray-900">
+                              <span className="font-medium">{entry.code || 'Uncoded'}</span> {entry.description}
+                            </span>
+                            <span className={entry.status === 'active' ? 'text-green-700' : 'text-gray-500'}>
+                              {entry.status === 'active' ? 'Active' : 'Resolved'} • {entry.doctor?.name ?? 'Unknown doctor'}
+                            </span>
+                          </div>
+                        ))}
+                      </div>
+                    </div>
+                  )}
+
+                  {/* Clinical Notes */}
+                  {clinicalNotes && clinicalNotes.length > 0 && (
                     <div className="bg-white border border-gray-200 rounded-lg p-6">
                       <h3 className="font-semibold text-gray-900 mb-4 flex items-center">
                         <FileText className="w-5 h-5 mr-2 text-primary-600" />
-                        Current Medical Record
+                        Medical Notes
                       </h3>
                       <div className="space-y-4">
-                        <div>
-                          <label className="block text-sm font-medium text-gray-700">Diagnosis</label>
-                          <p className="mt-1 text-gray-900">{selectedPatient.diagnosis}</p>
-                        </div>
-                        {selectedPatient.notes && (
-                          <div>
-                            <label className="block text-sm font-medium text-gray-700">Medical Notes</label>
-                            <p className="mt-1 text-gray-900 whitespace-pre-wrap">{selectedPatient.notes}</p>
+                        {clinicalNotes.map((note) => (
+                          <div key={note.id} className="border-b border-gray-100 pb-4 last:border-0 last:pb-0">
+                            <div className="flex items-center justify-between text-sm text-gray-500">
+                              <span className="font-medium text-gray-700">{note.title}</span>
+                              <span>
+                                {noteStatusLabel(note)} • {note.author?.name ?? 'Unknown author'} • {new Date(note.signedAt ?? note.createdAt).toLocaleDateString()}
+                              </span>
+                            </div>
+                            {[note.subjective, note.objective, note.assessment, note.plan]
+                              .filter((section) => section.trim())
+                              .map((section, index) => (
+                                <p key={index} className="mt-1 text-gray-900 whitespace-pre-wrap">{section}</p>
+                              ))}
+                            {note.addenda.map((addendum) => (
+                              <p key={addendum.id} className="mt-2 pl-3 border-l-2 border-gray-200 text-sm text-gray-700 whitespace-pre-wrap">
+                                Addendum {new Date(addendum.createdAt).toLocaleDateString()}: {addendum.text}
+                              </p>
+                            ))}
                           </div>
-                        )}
+                        ))}
                       </div>
                     </div>
                   )}
@@ -164,21 +233,39 @@ const DoctorPatients: React.FC = () => {
                   <div className="bg-white border border-gray-200 rounded-lg p-6">
                     <h3 className="font-semibold text-gray-900 mb-4 flex items-center">
                       <Stethoscope className="w-5 h-5 mr-2 text-primary-600" />
-                      {selectedPatient.diagnosis ? 'Update Medical Record' : 'Add Medical Record'}
+                      Add Medical Record
                     </h3>
 
                     <div className="space-y-4">
                       <div>
                         <label className="block text-sm font-medium text-gray-700 mb-2">
-                          Diagnosis *
+                          Diagnosis
                         </label>
                         <input
                           type="text"
-                          value={diagnosis}
-                          onChange={(e) => setDiagnosis(e.target.value)}
+                          value={diagnosisCode ? `${diagnosisCode.code} ${diagnosisCode.description}` : diagnosisSearch}
+                          onChange={(e) => {
+                            setDiagnosisCode(null);
+                            setDiagnosisSearch(e.target.value);
+                          }}
                           className="w-full px-4 py-3 border border-gray-300 rounded-lg focus:ring-2 focus:ring-primary-500 focus:border-transparent"
-                          placeholder="Enter diagnosis"
+                          placeholder="Search ICD-10 code or description"
                         />
+                        {!diagnosisCode && codeMatches && codeMatches.length > 0 && (
+                          <ul className="mt-1 border border-gray-200 rounded-lg divide-y divide-gray-100 max-h-48 overflow-y-auto">
+                            {codeMatches.map((match) => (
+                              <li key={match.code}>
+                                <button
+                                  type="button"
+                                  onClick={() => setDiagnosisCode(match)}
+                                  className="w-full px-4 py-2 text-left text-sm hover:bg-gray-50"
+                                >
+                                  <span className="font-medium">{match.code}</span> {match.description}
+                                </button>
+                              </li>
+                            ))}
+                          </ul>
+                        )}
                       </div>
 
                       <div>
@@ -334,10 +421,10 @@ const DoctorPatients: React.FC = () => {
                         </span>
                       </td>
                       <td className="px-6 py-4 whitespace-nowrap">
-                        {patient.allergies ? (
+                        {patient.allergyBanner?.count ? (
                           <span className="inline-flex items-center px-2.5 py-0.5 rounded-full text-xs font-medium bg-yellow-100 text-yellow-800">
                             <AlertCircle className="w-3 h-3 mr-1" />
-                            {patient.allergies}
+                            {allergySummary(patient.allergyBanner)}
                           </span>
                         ) : (
                           <span className="text-sm text-gray-500">None</span>
@@ -15,6 +15,7 @@ import type { Patient } from '../../types';
 import PatientForm from '../../components/forms/PatientForm';
 import LoadingSpinner from '../../components/common/LoadingSpinner';
 import ConfirmModal from '../../components/common/ConfirmModal';
+import AllergyBanner from '../../components/common/AllergyBanner';
 
 // interface ConfirmModalProps {
 //   isOpen: boolean;
@@ -57,8 +58,8 @@ const PatientManagement: React.FC = () => {
   });
 
   const updateMutation = useMutation({
-    mutationFn: ({ id, data }: { id: number; data: Partial<Patient> }) =>
-      patientService.updatePatient(id, data),
+    mutationFn: ({ id, version, data }: { id: number; version: number; data: Partial<Patient> }) =>
+      patientService.updatePatient(id, version, data),
     onSuccess: () => {
       queryClient.invalidateQueries({ queryKey: ['patients'] });
       setEditingPatient(null);
@@ -96,7 +97,7 @@ const PatientManagement: React.FC = () => {
 
   const handleUpdatePatient = (data: Omit<Patient, 'id' | 'createdAt' | 'updatedAt'>) => {
     if (editingPatient) {
-      updateMutation.mutate({ id: editingPatient.id, data });
+      updateMutation.mutate({ id: editingPatient.id, version: editingPatient.version, data });
     }
   };
 
@@ -381,12 +382,7 @@ const PatientManagement: React.FC = () => {
                   <p className="mt-1 text-sm text-gray-900">{viewingPatient.emergencyPhone}</p>
                 </div>
               </div>
-              {viewingPatient.allergies && (
-                <div>
-                  <label className="block text-sm font-medium text-gray-500">Allergies</label>
-                  <p className="mt-1 text-sm text-gray-900">{viewingPatient.allergies}</p>
-                </div>
-              )}
+              <AllergyBanner banner={viewingPatient.allergyBanner} />
             </div>
           </div>
         </div>
@@ -26,19 +26,70 @@ api.interceptors.request.use(
   }
 );
 
+// Login and token refresh answer 401 for wrong credentials; they never
+// trigger a refresh or end the session
+const isAuthRequest = (url?: string) =>
+  !!url && (url.startsWith('/login') || url.startsWith('/auth/refresh'));
+
+// A refresh in progress, shared by all requests that failed with 401 while
+// it runs. Refresh tokens are single use, so only one request may send it.
+let refreshing: Promise<string> | null = null;
+
+// refreshAccessToken exchanges the stored refresh token for a new access
+// token and stores the rotated refresh token
+export const refreshAccessToken = (): Promise<string> => {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem('refreshToken');
+    refreshing = (refreshToken
+      ? axios.post(`${api.defaults.baseURL}/auth/refresh`, { refreshToken })
+      : Promise.reject(new Error('No refresh token'))
+    )
+      .then((response) => {
+        const { token, refreshToken: rotated } = response.data;
+        localStorage.setItem('token', token);
+        localStorage.setItem('refreshToken', rotated);
+        api.defaults.headers.common['Authorization'] = `Bearer ${token}`;
+        return token as string;
+      })
+      .finally(() => {
+        refreshing = null;
+      });
+  }
+  return refreshing;
+};
+
+// clearSession forgets the tokens and user of the current session
+export const clearSession = () => {
+  localStorage.removeItem('token');
+  localStorage.removeItem('refreshToken');
+  localStorage.removeItem('user');
+  delete api.defaults.headers.common['Authorization'];
+};
+
 // Response interceptor
 api.interceptors.response.use(
   (response) => response,
-  (error) => {
+  async (error) => {
     console.error('Response error:', error.response);
 
     if (error.response) {
       const { status, data } = error.response;
+      const request = error.config;
 
-      // Handle 401 Unauthorized
-      if (status === 401) {
-        localStorage.removeItem('token');
-        localStorage.removeItem('user');
+      // Handle 401 Unauthorized: the access token has expired, so get a new
+      // one and retry the request once
+      if (status === 401 && !isAuthRequest(request?.url)) {
+        if (request && !request._retried) {
+          request._retried = true;
+          try {
+            const token = await refreshAccessToken();
+            request.headers.Authorization = `Bearer ${token}`;
+            return api(request);
+          } catch {
+            // The refresh token is missing, expired or revoked
+          }
+        }
+        clearSession();
         window.location.href = '/login';
         toast.error('Your session has expired. Please log in again.');
         return Promise.reject(error);
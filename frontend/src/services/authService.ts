@@ -1,66 +1,117 @@
-import api from './api';
-import type { LoginCredentials, User, ApiResponse, LoginResponse } from '../types';
+import api, { clearSession } from './api';
+import type { LoginCredentials, LoginResponse, LoginResult, MFAEnrollment } from '../types';
+
+// startSession stores the tokens and user of a completed login
+const startSession = (data: LoginResponse): LoginResult => {
+  if (!data || !data.token || !data.user) {
+    console.error('Invalid response structure:', data);
+    return {
+      success: false,
+      data: null,
+      error: 'Invalid response from server'
+    };
+  }
+
+  // Store token and user data
+  localStorage.setItem('token', data.token);
+  localStorage.setItem('refreshToken', data.refreshToken || '');
+  localStorage.setItem('user', JSON.stringify(data.user));
+
+  // Set the token in the API instance for future requests
+  api.defaults.headers.common['Authorization'] = `Bearer ${data.token}`;
+
+  return {
+    success: true,
+    data: data.user,
+    recoveryCodes: data.recoveryCodes,
+    message: 'Login successful'
+  };
+};
+
+// loginError turns a failed login request into a result
+const loginError = (error: any): LoginResult => {
+  console.error('Login error details:', {
+    status: error.response?.status,
+    data: error.response?.data,
+    message: error.message
+  });
+
+  // Handle specific error cases
+  if (error.response?.status === 401) {
+    return {
+      success: false,
+      data: null,
+      error: error.response?.data?.error || 'Invalid credentials'
+    };
+  }
+
+  if (error.message === 'Network Error') {
+    return {
+      success: false,
+      data: null,
+      error: 'Network error. Please check your connection.'
+    };
+  }
+
+  return {
+    success: false,
+    data: null,
+    error: error.response?.data?.error || error.message || 'Login failed'
+  };
+};
 
 export const authService = {
-  login: async (credentials: LoginCredentials): Promise<ApiResponse<User>> => {
+  // login checks the password. Users with two-factor authentication get an
+  // mfa challenge instead of a session, to answer with verifyMFA.
+  login: async (credentials: LoginCredentials): Promise<LoginResult> => {
     try {
-      console.log('Attempting login with credentials:', credentials);
-
       const response = await api.post<LoginResponse>('/login', credentials);
-      console.log('Login response:', response.data);
 
-      if (!response.data || !response.data.token || !response.data.user) {
-        console.error('Invalid response structure:', response.data);
+      if (response.data?.mfaRequired && response.data.mfaToken) {
         return {
-          success: false,
-          data: null as any,
-          error: 'Invalid response from server'
+          success: true,
+          data: null,
+          mfa: {
+            mfaToken: response.data.mfaToken,
+            enrollmentRequired: !!response.data.enrollmentRequired
+          }
         };
       }
 
-      // Store token and user data
-      localStorage.setItem('token', response.data.token);
-      localStorage.setItem('user', JSON.stringify(response.data.user));
-
-      // Set the token in the API instance for future requests
-      api.defaults.headers.common['Authorization'] = `Bearer ${response.data.token}`;
-
-      return {
-        success: true,
-        data: response.data.user,
-        message: 'Login successful'
-      };
+      return startSession(response.data);
     } catch (error: any) {
-      console.error('Login error details:', {
-        status: error.response?.status,
-        data: error.response?.data,
-        message: error.message,
-        error: error,
-        config: error.config
-      });
+      return loginError(error);
+    }
+  },
 
-      // Handle specific error cases
-      if (error.response?.status === 401) {
-        return {
-          success: false,
-          data: null as any,
-          error: error.response?.data?.error || 'Invalid credentials'
-        };
-      }
+  // verifyMFA completes a login with a code from the authenticator app or a
+  // recovery code. The first code after enrollment also returns the
+  // recovery codes.
+  verifyMFA: async (mfaToken: string, code: string, recoveryCode: string): Promise<LoginResult> => {
+    try {
+      const response = await api.post<LoginResponse>('/login/mfa', { mfaToken, code, recoveryCode });
+      return startSession(response.data);
+    } catch (error: any) {
+      return loginError(error);
+    }
+  },
 
-      if (error.message === 'Network Error') {
-        return {
-          success: false,
-          data: null as any,
-          error: 'Network error. Please check your connection.'
-        };
-      }
+  // startMFAEnrollment gets the authenticator secret for users who must set
+  // up two-factor authentication before their first login
+  startMFAEnrollment: async (mfaToken: string): Promise<MFAEnrollment> => {
+    const response = await api.post<MFAEnrollment>('/login/mfa/enroll', { mfaToken });
+    return response.data;
+  },
 
-      return {
-        success: false,
-        data: null as any,
-        error: error.response?.data?.error || error.message || 'Login failed'
-      };
+  // logout revokes the session on the server, then forgets it locally even
+  // if the server cannot be reached
+  logout: async (): Promise<void> => {
+    try {
+      await api.post('/auth/logout');
+    } catch (error) {
+      console.error('Logout error:', error);
+    } finally {
+      clearSession();
     }
   }
-};
\ No newline at end of file
+};
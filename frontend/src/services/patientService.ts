@@ -1,5 +1,5 @@
 import api from './api';
-import type { Patient, PaginatedResponse } from '../types';
+import type { ClinicalNote, Diagnosis, ICD10Code, Patient, PaginatedResponse } from '../types';
 
 interface GetPatientsParams {
   page: number;
@@ -19,8 +19,22 @@ interface UpdatePatientData {
   emergencyPhone?: string;
   bloodGroup?: string;
   allergies?: string;
-  diagnosis?: string;
-  notes?: string;
+}
+
+interface CreateDiagnosisData {
+  code: string;
+  description?: string;
+  encounterId?: number;
+}
+
+interface CreateNoteData {
+  title?: string;
+  template?: string;
+  encounterId?: number;
+  subjective?: string;
+  objective?: string;
+  assessment?: string;
+  plan?: string;
 }
 
 export const patientService = {
@@ -29,9 +43,9 @@ export const patientService = {
     return data;
   },
 
-  getPatient: async (id: number, role: 'doctor' | 'receptionist'): Promise<Patient> => {
-    const { data } = await api.get(`/${role}/patients/${id}`);
-    return data;
+  getPatient: async (id: number, params?: { fields?: string; include?: string }): Promise<Patient> => {
+    const { data } = await api.get(`/patients/${id}`, { params });
+    return data.data;
   },
 
   createPatient: async (patientData: Omit<Patient, 'id' | 'createdAt' | 'updatedAt' | 'createdBy' | 'updatedBy'>): Promise<Patient> => {
@@ -39,12 +53,48 @@ export const patientService = {
     return data;
   },
 
-  updatePatient: async (id: number, patientData: UpdatePatientData, role: 'doctor' | 'receptionist' = 'receptionist'): Promise<Patient> => {
+  // version is the patient version the edit is based on; the server rejects
+  // the update with 412 if someone else changed the record in the meantime
+  updatePatient: async (id: number, version: number, patientData: UpdatePatientData, role: 'doctor' | 'receptionist' = 'receptionist'): Promise<Patient> => {
     const method = role === 'doctor' ? 'patch' : 'put';
-    const { data } = await api[method](`/${role}/patients/${id}`, patientData);
+    const { data } = await api[method](`/${role}/patients/${id}`, patientData, {
+      headers: { 'If-Match': `"${version}"` },
+    });
     return data;
   },
 
+  getDiagnoses: async (patientId: number): Promise<Diagnosis[]> => {
+    const { data } = await api.get(`/patients/${patientId}/diagnoses`);
+    return data.data;
+  },
+
+  createDiagnosis: async (patientId: number, diagnosis: CreateDiagnosisData): Promise<Diagnosis> => {
+    const { data } = await api.post(`/patients/${patientId}/diagnoses`, diagnosis);
+    return data.data;
+  },
+
+  // searchICD10 matches a code prefix or words of the description
+  searchICD10: async (q: string, limit = 10): Promise<ICD10Code[]> => {
+    const { data } = await api.get('/codes/icd10', { params: { q, limit } });
+    return data.data;
+  },
+
+  getNotes: async (patientId: number): Promise<ClinicalNote[]> => {
+    const { data } = await api.get(`/patients/${patientId}/notes`);
+    return data.data;
+  },
+
+  // createNote starts a draft; it stays private to its author until signed
+  createNote: async (patientId: number, note: CreateNoteData): Promise<ClinicalNote> => {
+    const { data } = await api.post(`/patients/${patientId}/notes`, note);
+    return data.data;
+  },
+
+  signNote: async (patientId: number, noteId: number): Promise<ClinicalNote> => {
+    const { data } = await api.post(`/patients/${patientId}/notes/${noteId}/sign`);
+    return data.data;
+  },
+
   deletePatient: async (id: number): Promise<void> => {
     try {
       await api.delete(`/receptionist/patients/${id}`);
@@ -9,6 +9,7 @@ export interface User {
 
 export interface Patient {
   id: number;
+  mrn: string;
   firstName: string;
   lastName: string;
   email: string;
@@ -19,13 +20,89 @@ export interface Patient {
   emergencyContact: string;
   emergencyPhone: string;
   bloodGroup?: string;
+  // allergies is the retired free-text field; recorded allergies are
+  // summarised in allergyBanner
   allergies?: string;
+  allergyBanner?: AllergyBanner;
+  // diagnosis is the retired free-text field; diagnoses are Diagnoses now
   diagnosis?: string;
+  // notes is the retired free-text field; notes are ClinicalNotes now
   notes?: string;
   createdAt: string;
   updatedAt: string;
   createdBy: number;
   updatedBy: number;
+  version: number;
+}
+
+export interface AllergyBannerItem {
+  substance: string;
+  category: 'drug' | 'food' | 'environment' | 'unstructured';
+  severity: '' | 'mild' | 'moderate' | 'severe';
+  reaction: string;
+}
+
+// AllergyBanner summarises a patient's active allergies, most severe first
+export interface AllergyBanner {
+  count: number;
+  highestSeverity: '' | 'mild' | 'moderate' | 'severe';
+  drugAllergies: number;
+  unstructured: number;
+  items: AllergyBannerItem[];
+}
+
+// Diagnosis is an entry of a patient's problem list. Entries converted from
+// the retired diagnosis field have no code until a doctor codes them.
+export interface Diagnosis {
+  id: number;
+  patientId: number;
+  encounterId: number | null;
+  code: string;
+  description: string;
+  status: 'active' | 'resolved';
+  onsetDate: string | null;
+  resolvedDate: string | null;
+  diagnosedBy: number;
+  updatedBy: number;
+  createdAt: string;
+  updatedAt: string;
+  doctor?: User;
+}
+
+export interface ICD10Code {
+  code: string;
+  description: string;
+}
+
+export interface NoteAddendum {
+  id: number;
+  noteId: number;
+  authorId: number;
+  text: string;
+  createdAt: string;
+}
+
+// ClinicalNote is a SOAP note. Legacy notes were converted from the retired
+// notes field and have no author.
+export interface ClinicalNote {
+  id: number;
+  patientId: number;
+  encounterId: number | null;
+  authorId: number | null;
+  template: string;
+  title: string;
+  subjective: string;
+  objective: string;
+  assessment: string;
+  plan: string;
+  status: 'draft' | 'awaiting_cosign' | 'signed' | 'legacy';
+  signedAt: string | null;
+  cosignerId: number | null;
+  cosignedAt: string | null;
+  createdAt: string;
+  updatedAt: string;
+  author?: User;
+  addenda: NoteAddendum[];
 }
 
 export interface MedicalRecord {
@@ -44,9 +121,32 @@ export interface LoginCredentials {
   password: string;
 }
 
+// LoginResponse is either a session or, for users with two-factor
+// authentication, an MFA challenge
 export interface LoginResponse {
-  user: User;
-  token: string;
+  user?: User;
+  token?: string;
+  refreshToken?: string;
+  expiresIn?: number;
+  mfaRequired?: boolean;
+  enrollmentRequired?: boolean;
+  mfaToken?: string;
+  recoveryCodes?: string[];
+}
+
+export interface MFAChallenge {
+  mfaToken: string;
+  enrollmentRequired: boolean;
+}
+
+export interface MFAEnrollment {
+  secret: string;
+  provisioningUri: string;
+}
+
+export interface LoginResult extends ApiResponse<User | null> {
+  mfa?: MFAChallenge;
+  recoveryCodes?: string[];
 }
 
 export interface ApiResponse<T> {